@@ -11,6 +11,7 @@ import (
 	"time"
 
 	api "github.com/MdSadiqMd/Broadcast-API/internal/api/routes"
+	"github.com/MdSadiqMd/Broadcast-API/internal/migrations"
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
 	"github.com/MdSadiqMd/Broadcast-API/internal/scheduler"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
@@ -46,7 +47,11 @@ func main() {
 	}
 
 	go func() {
-		sched := scheduler.NewScheduler(db, *cfg)
+		sched, err := scheduler.NewScheduler(db, *cfg)
+		if err != nil {
+			log.Printf("Failed to create scheduler: %v", err)
+			return
+		}
 		if err := sched.Start(); err != nil {
 			log.Printf("Failed to start scheduler: %v", err)
 			return
@@ -87,19 +92,7 @@ func main() {
 }
 
 func setupDatabase(config config.DatabaseConfig) (*gorm.DB, error) {
-	dsn := config.URL
-	if dsn == "" {
-		dsn = fmt.Sprintf(
-			"postgresql://%s:%s@%s:%d/%s",
-			config.User,
-			config.Password,
-			config.Host,
-			config.Port,
-			config.Name,
-		)
-	}
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(config.DSN()), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +110,16 @@ func setupDatabase(config config.DatabaseConfig) (*gorm.DB, error) {
 }
 
 func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.SetupJoinTable(&models.List{}, "Contacts", &models.ListContact{})
+	if err != nil {
+		return err
+	}
+	err = db.SetupJoinTable(&models.Contact{}, "Lists", &models.ListContact{})
+	if err != nil {
+		return err
+	}
+
+	err = db.AutoMigrate(
 		&models.User{},
 		&models.Campaign{},
 		&models.Contact{},
@@ -125,10 +127,27 @@ func runMigrations(db *gorm.DB) error {
 		&models.CampaignAudience{},
 		&models.JWTClaims{},
 		&models.EmailJob{},
+		&models.TransactionalMessage{},
 		&models.EmailLog{},
+		&models.EmailLogAttachment{},
 		&models.Template{},
 		&models.Message{},
-		&models.Subscriber{},
 		&models.List{},
+		&models.ListContact{},
+		&models.Segment{},
+		&models.AttributeDefinition{},
+		&models.ContactImport{},
+		&models.ContactImportError{},
+		&models.ContactExport{},
+		&models.PreferenceAudit{},
+		&models.Suppression{},
+		&models.Bounce{},
+		&models.WebhookEvent{},
+		&models.RateLimit{},
 	)
+	if err != nil {
+		return err
+	}
+
+	return migrations.Run(db)
 }
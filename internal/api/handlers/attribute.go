@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type AttributeHandler struct {
+	attributeService *services.AttributeService
+	auth             *middleware.Auth
+}
+
+func NewAttributeHandler(attributeService *services.AttributeService, auth *middleware.Auth) *AttributeHandler {
+	return &AttributeHandler{
+		attributeService: attributeService,
+		auth:             auth,
+	}
+}
+
+func (h *AttributeHandler) CreateDefinition(w http.ResponseWriter, r *http.Request) {
+	var definition models.AttributeDefinition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	newDefinition, err := h.attributeService.CreateDefinition(&definition)
+	if err != nil {
+		respondAttributeError(w, err, "failed to create attribute")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newDefinition)
+}
+
+func (h *AttributeHandler) GetAllDefinitions(w http.ResponseWriter, r *http.Request) {
+	definitions, err := h.attributeService.GetAllDefinitions()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch attributes")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, definitions)
+}
+
+func (h *AttributeHandler) GetDefinitionByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := attributeID(w, r)
+	if !ok {
+		return
+	}
+
+	definition, err := h.attributeService.GetDefinitionByID(id)
+	if err != nil {
+		respondAttributeError(w, err, "failed to fetch attribute")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, definition)
+}
+
+func (h *AttributeHandler) UpdateDefinition(w http.ResponseWriter, r *http.Request) {
+	id, ok := attributeID(w, r)
+	if !ok {
+		return
+	}
+
+	var definition models.AttributeDefinition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	updatedDefinition, err := h.attributeService.UpdateDefinition(id, &definition)
+	if err != nil {
+		respondAttributeError(w, err, "failed to update attribute")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, updatedDefinition)
+}
+
+func (h *AttributeHandler) DeleteDefinition(w http.ResponseWriter, r *http.Request) {
+	id, ok := attributeID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.attributeService.DeleteDefinition(id)
+	if err != nil {
+		respondAttributeError(w, err, "failed to delete attribute")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "attribute deleted successfully"})
+}
+
+func attributeID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid attribute ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondAttributeError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrAttributeNotFound):
+		utils.RespondError(w, http.StatusNotFound, "attribute not found")
+	case errors.Is(err, services.ErrInvalidAttribute):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -28,6 +30,8 @@ func NewBroadcastHandler(broadcastService *services.BroadcastService, auth *midd
 type CreateBroadcastRequest struct {
 	Name        string  `json:"name"`
 	AudienceID  uint    `json:"audience_id"`
+	ListIDs     []uint  `json:"list_ids"`
+	SegmentID   *uint   `json:"segment_id"`
 	CampaignID  uint    `json:"campaign_id"`
 	UserID      uint    `json:"user_id"`
 	From        string  `json:"from"`
@@ -35,9 +39,7 @@ type CreateBroadcastRequest struct {
 	ReplyTo     string  `json:"reply_to"`
 	HTML        string  `json:"html"`
 	Text        string  `json:"text"`
-	Status      string  `json:"status"`
 	ScheduledAt *string `json:"scheduled_at,omitempty"`
-	SentAt      *string `json:"sent_at,omitempty"`
 }
 
 func (h *BroadcastHandler) CreateBroadcast(w http.ResponseWriter, r *http.Request) {
@@ -50,6 +52,8 @@ func (h *BroadcastHandler) CreateBroadcast(w http.ResponseWriter, r *http.Reques
 	broadcast := models.Broadcast{
 		Name:       req.Name,
 		AudienceID: req.AudienceID,
+		ListIDs:    req.ListIDs,
+		SegmentID:  req.SegmentID,
 		CampaignID: req.CampaignID,
 		UserID:     req.UserID,
 		From:       req.From,
@@ -57,7 +61,6 @@ func (h *BroadcastHandler) CreateBroadcast(w http.ResponseWriter, r *http.Reques
 		ReplyTo:    req.ReplyTo,
 		HTML:       req.HTML,
 		Text:       req.Text,
-		Status:     req.Status,
 	}
 
 	if req.ScheduledAt != nil && *req.ScheduledAt != "" {
@@ -67,14 +70,11 @@ func (h *BroadcastHandler) CreateBroadcast(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	if req.SentAt != nil && *req.SentAt != "" {
-		t, err := time.Parse(time.RFC3339, *req.SentAt)
-		if err == nil {
-			broadcast.SentAt = &t
-		}
-	}
-
 	newBroadcast, err := h.broadcastService.CreateBroadcast(&broadcast)
+	if errors.Is(err, services.ErrListNotFound) || errors.Is(err, services.ErrSegmentNotFound) {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "failed to create broadcast")
 		return
@@ -115,6 +115,19 @@ func (h *BroadcastHandler) UpdateBroadcast(w http.ResponseWriter, r *http.Reques
 	}
 
 	updatedBroadcast, err := h.broadcastService.UpdateBroadcast(uint(id), &broadcast)
+	if errors.Is(err, services.ErrBroadcastNotFound) {
+		utils.RespondError(w, http.StatusNotFound, "broadcast not found")
+		return
+	}
+	if errors.Is(err, services.ErrBroadcastNotEditable) {
+		utils.RespondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, services.ErrListNotFound) || errors.Is(err, services.ErrSegmentNotFound) ||
+		errors.Is(err, services.ErrInvalidBroadcast) {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "failed to update broadcast")
 		return
@@ -135,20 +148,40 @@ func (h *BroadcastHandler) SendBroadcast(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The body is optional: an empty request sends the broadcast immediately.
 	var payload ScheduledPayload
 	err = json.NewDecoder(r.Body).Decode(&payload)
-	if err != nil {
+	if err != nil && !errors.Is(err, io.EOF) {
 		utils.RespondError(w, http.StatusBadRequest, "invalid request payload: "+err.Error())
 		return
 	}
 
-	_, err = h.broadcastService.SendBroadcast(strconv.Itoa(id), payload.ScheduledAt)
+	var scheduledAt *time.Time
+	if payload.ScheduledAt != "" {
+		t, err := time.Parse(time.RFC3339, payload.ScheduledAt)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "invalid scheduled_at, expected RFC3339")
+			return
+		}
+		scheduledAt = &t
+	}
+
+	broadcast, err := h.broadcastService.SendBroadcast(uint(id), scheduledAt)
 	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "failed to send broadcast: "+err.Error())
+		switch {
+		case errors.Is(err, services.ErrBroadcastNotFound):
+			utils.RespondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInvalidBroadcast):
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrBroadcastNotSendable):
+			utils.RespondError(w, http.StatusConflict, err.Error())
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, "failed to send broadcast: "+err.Error())
+		}
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "broadcast sent successfully"})
+	utils.RespondJSON(w, http.StatusOK, broadcast)
 }
 
 func (h *BroadcastHandler) ListBroadcasts(w http.ResponseWriter, r *http.Request) {
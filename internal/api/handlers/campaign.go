@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -32,6 +33,10 @@ func (h *CampaignHandler) CreateCampaign(w http.ResponseWriter, r *http.Request)
 	}
 
 	newCampaign, err := h.campaignService.CreateCampaign(&campaign)
+	if errors.Is(err, services.ErrListNotFound) || errors.Is(err, services.ErrSegmentNotFound) {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "failed to create campaign")
 		return
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -36,6 +37,10 @@ func (h *ContactHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newContact, err := h.contactService.CreateContact(&contact)
+	if errors.Is(err, services.ErrInvalidAttribute) {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "failed to create contact")
 		return
@@ -93,6 +98,10 @@ func (h *ContactHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 	}
 
 	updatedContact, err := h.contactService.UpdateContact(uint(id), &contact)
+	if errors.Is(err, services.ErrInvalidAttribute) {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "failed to update contact")
 		return
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// exportWriteWindow is how long each streamed batch may take to write
+// before the connection is considered stalled.
+const exportWriteWindow = 30 * time.Second
+
+type ContactExportHandler struct {
+	exportService *services.ContactExportService
+	auth          *middleware.Auth
+}
+
+func NewContactExportHandler(exportService *services.ContactExportService, auth *middleware.Auth) *ContactExportHandler {
+	return &ContactExportHandler{
+		exportService: exportService,
+		auth:          auth,
+	}
+}
+
+type CreateExportRequest struct {
+	Format string               `json:"format"`
+	Filter models.ContactFilter `json:"filter"`
+}
+
+// ExportContacts streams the matching contacts in the response. The write
+// deadline is pushed forward before every batch, so the export is bounded by
+// its progress rather than the server-wide write timeout.
+func (h *ContactExportHandler) ExportContacts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format, err := services.NormalizeExportFormat(query.Get("format"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := contactFilterFromQuery(query)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == models.ContactExportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="contacts.%s"`, format))
+
+	rc := http.NewResponseController(w)
+	_, err = h.exportService.WriteContacts(w, format, filter, func(int64) error {
+		err := rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		err = rc.Flush()
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, services.ErrInvalidExport) {
+		w.Header().Del("Content-Disposition")
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		// Headers are already sent; the truncated body is all we can signal.
+		log.Printf("Contact export failed: %v", err)
+	}
+}
+
+func (h *ContactExportHandler) CreateExport(w http.ResponseWriter, r *http.Request) {
+	var req CreateExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	var createdBy uint
+	if user, ok := utils.GetUserFromContext(r.Context()); ok {
+		createdBy = user.UserID
+	}
+
+	contactExport, err := h.exportService.CreateExport(req.Format, req.Filter, createdBy)
+	if err != nil {
+		respondExportError(w, err, "failed to create export")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, contactExport)
+}
+
+func (h *ContactExportHandler) ListExports(w http.ResponseWriter, r *http.Request) {
+	exports, err := h.exportService.ListExports()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch exports")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, exports)
+}
+
+func (h *ContactExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid export ID")
+		return
+	}
+
+	contactExport, err := h.exportService.GetExport(uint(id))
+	if err != nil {
+		respondExportError(w, err, "failed to fetch export")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, contactExport)
+}
+
+func (h *ContactExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid export ID")
+		return
+	}
+
+	contactExport, file, err := h.exportService.OpenExport(uint(id))
+	if err != nil {
+		respondExportError(w, err, "failed to open export")
+		return
+	}
+	defer file.Close()
+
+	if err = http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Failed to clear download deadline: %v", err)
+	}
+
+	name := filepath.Base(contactExport.FilePath)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	http.ServeContent(w, r, name, contactExport.UpdatedAt, file)
+}
+
+func contactFilterFromQuery(query url.Values) (models.ContactFilter, error) {
+	var filter models.ContactFilter
+	var err error
+
+	if filter.ListID, err = parseOptionalID(query.Get("list_id")); err != nil {
+		return filter, errors.New("invalid list_id")
+	}
+	if filter.CampaignID, err = parseOptionalID(query.Get("campaign_id")); err != nil {
+		return filter, errors.New("invalid campaign_id")
+	}
+	if value := query.Get("unsubscribed"); value != "" {
+		unsubscribed, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("invalid unsubscribed")
+		}
+		filter.Unsubscribed = &unsubscribed
+	}
+	filter.Status = query.Get("status")
+	filter.Email = query.Get("email")
+	return filter, nil
+}
+
+func respondExportError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrExportNotFound):
+		utils.RespondError(w, http.StatusNotFound, "export not found")
+	case errors.Is(err, services.ErrInvalidExport):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrExportNotReady):
+		utils.RespondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrExportExpired):
+		utils.RespondError(w, http.StatusGone, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxImportFieldSize = 64 << 10
+
+type ContactImportHandler struct {
+	importService *services.ContactImportService
+	auth          *middleware.Auth
+	config        config.ImportConfig
+}
+
+func NewContactImportHandler(importService *services.ContactImportService, auth *middleware.Auth, cfg config.ImportConfig) *ContactImportHandler {
+	return &ContactImportHandler{
+		importService: importService,
+		auth:          auth,
+		config:        cfg,
+	}
+}
+
+// ImportContacts accepts a multipart upload with a "file" part and optional
+// "format", "mapping" (JSON object), "list_id" and "campaign_id" fields. The
+// file is streamed to disk and imported in the background.
+func (h *ContactImportHandler) ImportContacts(w http.ResponseWriter, r *http.Request) {
+	// Large uploads outlive the server-wide read and write timeouts.
+	if h.config.UploadTimeout > 0 {
+		deadline := time.Now().Add(h.config.UploadTimeout)
+		rc := http.NewResponseController(w)
+		err := rc.SetReadDeadline(deadline)
+		if err == nil {
+			err = rc.SetWriteDeadline(deadline)
+		}
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("Failed to extend upload deadline: %v", err)
+		}
+	}
+	if h.config.MaxFileSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.config.MaxFileSize+1<<20)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "expected a multipart/form-data upload")
+		return
+	}
+
+	var req services.ContactImportRequest
+	if user, ok := utils.GetUserFromContext(r.Context()); ok {
+		req.CreatedBy = user.UserID
+	}
+
+	status, message := h.readImportParts(reader, &req)
+	if status != 0 {
+		if req.FilePath != "" {
+			os.Remove(req.FilePath)
+		}
+		utils.RespondError(w, status, message)
+		return
+	}
+	if req.FilePath == "" {
+		utils.RespondError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	contactImport, err := h.importService.CreateImport(req)
+	if err != nil {
+		respondImportError(w, err, "failed to create import")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, contactImport)
+}
+
+func (h *ContactImportHandler) readImportParts(reader *multipart.Reader, req *services.ContactImportRequest) (int, string) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return 0, ""
+		}
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return http.StatusRequestEntityTooLarge, "import file is too large"
+			}
+			return http.StatusBadRequest, "invalid multipart body"
+		}
+
+		if part.FormName() == "file" {
+			if req.FilePath != "" {
+				return http.StatusBadRequest, "only one file can be imported at a time"
+			}
+			req.Filename = part.FileName()
+			req.FilePath, err = h.importService.StoreUpload(part)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.Is(err, services.ErrImportTooLarge) || errors.As(err, &maxBytesErr) {
+					return http.StatusRequestEntityTooLarge, "import file is too large"
+				}
+				return http.StatusInternalServerError, "failed to store import file"
+			}
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxImportFieldSize))
+		if err != nil {
+			return http.StatusBadRequest, "invalid multipart body"
+		}
+		field := strings.TrimSpace(string(value))
+
+		switch part.FormName() {
+		case "format":
+			req.Format = field
+		case "mapping":
+			if field == "" {
+				continue
+			}
+			if err := json.Unmarshal(value, &req.Mapping); err != nil {
+				return http.StatusBadRequest, "mapping must be a JSON object"
+			}
+		case "list_id":
+			if req.ListID, err = parseOptionalID(field); err != nil {
+				return http.StatusBadRequest, "invalid list_id"
+			}
+		case "campaign_id":
+			if req.CampaignID, err = parseOptionalID(field); err != nil {
+				return http.StatusBadRequest, "invalid campaign_id"
+			}
+		}
+	}
+}
+
+func (h *ContactImportHandler) ListImports(w http.ResponseWriter, r *http.Request) {
+	imports, err := h.importService.ListImports()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch imports")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, imports)
+}
+
+func (h *ContactImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid import ID")
+		return
+	}
+
+	contactImport, err := h.importService.GetImport(uint(id))
+	if err != nil {
+		respondImportError(w, err, "failed to fetch import")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, contactImport)
+}
+
+func (h *ContactImportHandler) GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid import ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	importErrors, err := h.importService.GetImportErrors(uint(id), page, perPage)
+	if err != nil {
+		respondImportError(w, err, "failed to fetch import errors")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, importErrors)
+}
+
+func respondImportError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrImportNotFound):
+		utils.RespondError(w, http.StatusNotFound, "import not found")
+	case errors.Is(err, services.ErrInvalidImport), errors.Is(err, services.ErrListNotFound):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+)
+
+type DeliveryHandler struct {
+	deliveryService *services.DeliveryService
+	auth            *middleware.Auth
+}
+
+func NewDeliveryHandler(deliveryService *services.DeliveryService, auth *middleware.Auth) *DeliveryHandler {
+	return &DeliveryHandler{
+		deliveryService: deliveryService,
+		auth:            auth,
+	}
+}
+
+// RecordBounce reports a bounce for a message, by job_id or message_id, or
+// for an address given as email.
+func (h *DeliveryHandler) RecordBounce(w http.ResponseWriter, r *http.Request) {
+	var event services.BounceEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	event.Source = models.BounceSourceAPI
+
+	bounce, err := h.deliveryService.RecordBounce(event)
+	if err != nil {
+		respondDeliveryError(w, err, "failed to record bounce")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, bounce)
+}
+
+func (h *DeliveryHandler) RecordComplaint(w http.ResponseWriter, r *http.Request) {
+	var event services.BounceEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	event.Source = models.BounceSourceAPI
+
+	complaint, err := h.deliveryService.RecordComplaint(event)
+	if err != nil {
+		respondDeliveryError(w, err, "failed to record complaint")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, complaint)
+}
+
+func (h *DeliveryHandler) GetBounces(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+	bounces, err := h.deliveryService.GetBounces(query.Get("type"), query.Get("email"), page, perPage)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch bounces")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, bounces)
+}
+
+func respondDeliveryError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidBounce):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
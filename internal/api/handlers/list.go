@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type ListHandler struct {
+	listService *services.ListService
+	auth        *middleware.Auth
+}
+
+func NewListHandler(listService *services.ListService, auth *middleware.Auth) *ListHandler {
+	return &ListHandler{
+		listService: listService,
+		auth:        auth,
+	}
+}
+
+type ListMembersRequest struct {
+	ContactIDs []uint `json:"contact_ids"`
+}
+
+func (h *ListHandler) CreateList(w http.ResponseWriter, r *http.Request) {
+	var list models.List
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	newList, err := h.listService.CreateList(&list)
+	if err != nil {
+		respondListError(w, err, "failed to create list")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newList)
+}
+
+func (h *ListHandler) GetAllLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.listService.GetAllLists()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch lists")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, lists)
+}
+
+func (h *ListHandler) GetListByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := listID(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.listService.GetListByID(id)
+	if err != nil {
+		respondListError(w, err, "failed to fetch list")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+func (h *ListHandler) UpdateList(w http.ResponseWriter, r *http.Request) {
+	id, ok := listID(w, r)
+	if !ok {
+		return
+	}
+
+	var list models.List
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	updatedList, err := h.listService.UpdateList(id, &list)
+	if err != nil {
+		respondListError(w, err, "failed to update list")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, updatedList)
+}
+
+func (h *ListHandler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	id, ok := listID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.listService.DeleteList(id)
+	if err != nil {
+		respondListError(w, err, "failed to delete list")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "list deleted successfully"})
+}
+
+func (h *ListHandler) GetListContacts(w http.ResponseWriter, r *http.Request) {
+	id, ok := listID(w, r)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	contacts, err := h.listService.GetListContacts(id, page, perPage)
+	if err != nil {
+		respondListError(w, err, "failed to fetch list contacts")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, contacts)
+}
+
+func (h *ListHandler) AddContacts(w http.ResponseWriter, r *http.Request) {
+	id, ok := listID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ListMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ContactIDs) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "contact_ids are required")
+		return
+	}
+
+	added, err := h.listService.AddContacts(id, req.ContactIDs)
+	if err != nil {
+		respondListError(w, err, "failed to add contacts to list")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"requested": len(req.ContactIDs),
+		"added":     added,
+	})
+}
+
+func (h *ListHandler) RemoveContacts(w http.ResponseWriter, r *http.Request) {
+	id, ok := listID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ListMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ContactIDs) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "contact_ids are required")
+		return
+	}
+
+	removed, err := h.listService.RemoveContacts(id, req.ContactIDs)
+	if err != nil {
+		respondListError(w, err, "failed to remove contacts from list")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"requested": len(req.ContactIDs),
+		"removed":   removed,
+	})
+}
+
+func listID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid list ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondListError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrListNotFound):
+		utils.RespondError(w, http.StatusNotFound, "list not found")
+	case errors.Is(err, services.ErrInvalidList):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
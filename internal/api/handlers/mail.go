@@ -2,25 +2,45 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
 	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
 	"github.com/go-chi/chi/v5"
 )
 
+const (
+	defaultDeliveryWait = 20 * time.Second
+	// maxDeliveryWait keeps waiting requests inside the server's write timeout.
+	maxDeliveryWait = 25 * time.Second
+)
+
 type MailHandler struct {
-	mailService *services.MailService
-	auth        *middleware.Auth
+	mailService    *services.MailService
+	queueService   *services.QueueService
+	auth           *middleware.Auth
+	maxRequestSize int64
 }
 
-func NewMailHandler(mailService *services.MailService, auth *middleware.Auth) *MailHandler {
+func NewMailHandler(mailService *services.MailService, queueService *services.QueueService, auth *middleware.Auth, attachments config.AttachmentConfig) *MailHandler {
+	// Base64 inflates attachments by a third; leave headroom for the rest of the payload.
+	maxRequestSize := int64(1 << 20)
+	if attachments.MaxTotalSize > 0 {
+		maxRequestSize += attachments.MaxTotalSize / 3 * 4
+	}
+
 	return &MailHandler{
-		mailService: mailService,
-		auth:        auth,
+		mailService:    mailService,
+		queueService:   queueService,
+		auth:           auth,
+		maxRequestSize: maxRequestSize,
 	}
 }
 
@@ -48,15 +68,25 @@ func (h *MailHandler) SendTestEmail(w http.ResponseWriter, r *http.Request) {
 	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "test email sent successfully"})
 }
 
+// SendTransactionalEmail queues a transactional email ahead of bulk mail and
+// responds 202 with its job. With "wait": true it waits for the email to be
+// sent, for up to wait_timeout seconds: 200 once sent, 409 if the recipient
+// was suppressed, 502 if sending failed for good, and still 202 if it has not
+// been sent by then.
 func (h *MailHandler) SendTransactionalEmail(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email        string                 `json:"email"`
-		Name         string                 `json:"name"`
-		Subject      string                 `json:"subject"`
-		TemplateName string                 `json:"template_name"`
-		Data         map[string]interface{} `json:"data"`
+		Email        string                     `json:"email"`
+		Name         string                     `json:"name"`
+		Subject      string                     `json:"subject"`
+		TemplateName string                     `json:"template_name"`
+		Data         map[string]interface{}     `json:"data"`
+		Attachments  []services.AttachmentInput `json:"attachments"`
+		Inline       []services.AttachmentInput `json:"inline"`
+		Wait         bool                       `json:"wait"`
+		WaitTimeout  int                        `json:"wait_timeout"`
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxRequestSize)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
 		return
@@ -67,13 +97,53 @@ func (h *MailHandler) SendTransactionalEmail(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err := h.mailService.SendTransactionalEmail(req.Email, req.Name, req.Subject, req.TemplateName, req.Data)
+	job, err := h.mailService.QueueTransactionalEmail(services.TransactionalEmail{
+		ToEmail:      req.Email,
+		ToName:       req.Name,
+		Subject:      req.Subject,
+		TemplateName: req.TemplateName,
+		Data:         req.Data,
+		Attachments:  req.Attachments,
+		Inline:       req.Inline,
+	})
+	if errors.Is(err, services.ErrInvalidAttachment) {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, services.ErrRecipientSuppressed) {
+		utils.RespondError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "failed to send transactional email: "+err.Error())
+		utils.RespondError(w, http.StatusInternalServerError, "failed to queue transactional email: "+err.Error())
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "transactional email sent successfully"})
+	if !req.Wait {
+		utils.RespondJSON(w, http.StatusAccepted, newJobResult(job))
+		return
+	}
+
+	timeout := defaultDeliveryWait
+	if req.WaitTimeout > 0 {
+		timeout = min(time.Duration(req.WaitTimeout)*time.Second, maxDeliveryWait)
+	}
+	job, err = h.queueService.WaitForJob(r.Context(), job.ID, timeout)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to check email job")
+		return
+	}
+
+	code := http.StatusBadGateway
+	switch job.Status {
+	case models.EmailJobStatusQueued, models.EmailJobStatusSending:
+		code = http.StatusAccepted
+	case models.EmailJobStatusSent, models.EmailJobStatusOpened, models.EmailJobStatusClicked:
+		code = http.StatusOK
+	case models.EmailJobStatusRejected:
+		code = http.StatusConflict
+	}
+	utils.RespondJSON(w, code, newJobResult(job))
 }
 
 func (h *MailHandler) ProcessEmailJob(w http.ResponseWriter, r *http.Request) {
@@ -84,9 +154,35 @@ func (h *MailHandler) ProcessEmailJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job := &models.EmailJob{ID: uint(id)}
-	err = h.mailService.ProcessJob(job)
+	// Claim the job first, so it is never sent while a worker has it.
+	owner := services.QueueOwner("api")
+	job, err := h.queueService.ClaimJob(uint(id), owner)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			utils.RespondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrJobNotClaimable):
+			utils.RespondError(w, http.StatusConflict, err.Error())
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, "failed to claim email job")
+		}
+		return
+	}
+
+	err = h.mailService.ProcessJob(owner, job)
+	var limited *services.RateLimitedError
+	if errors.As(err, &limited) {
+		if deferErr := h.queueService.DeferJob(owner, job, limited); deferErr != nil {
+			log.Printf("Failed to defer job %d: %v", job.ID, deferErr)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(limited.Until).Seconds())+1))
+		utils.RespondError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		if _, failErr := h.queueService.FailJob(owner, job, err); failErr != nil {
+			log.Printf("Failed to mark job %d failed: %v", job.ID, failErr)
+		}
 		utils.RespondError(w, http.StatusInternalServerError, "failed to process email job: "+err.Error())
 		return
 	}
@@ -111,6 +207,16 @@ func (h *MailHandler) ProcessCampaignEmail(w http.ResponseWriter, r *http.Reques
 	}
 
 	err := h.mailService.ProcessCampaignJob(req.CampaignID, &req.Contact)
+	var limited *services.RateLimitedError
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(limited.Until).Seconds())+1))
+		utils.RespondError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if isUnmailable(err) || errors.Is(err, services.ErrRecipientSuppressed) {
+		utils.RespondError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "failed to process campaign email: "+err.Error())
 		return
@@ -137,22 +243,61 @@ func (h *MailHandler) BulkSendCampaign(w http.ResponseWriter, r *http.Request) {
 
 	successCount := 0
 	failureCount := 0
+	skippedCount := 0
+	rejectedCount := 0
+	rateLimitedCount := 0
 
 	for _, contact := range req.Contacts {
 		err := h.mailService.ProcessCampaignJob(req.CampaignID, &contact)
-		if err != nil {
+		var limited *services.RateLimitedError
+		switch {
+		case errors.As(err, &limited):
+			rateLimitedCount++
+		case errors.Is(err, services.ErrRecipientSuppressed):
+			rejectedCount++
+		case isUnmailable(err):
+			skippedCount++
+		case err != nil:
 			failureCount++
-		} else {
+		default:
 			successCount++
 		}
 	}
 
 	result := map[string]interface{}{
-		"message":       "bulk send complete",
-		"success_count": successCount,
-		"failure_count": failureCount,
-		"total":         len(req.Contacts),
+		"message":            "bulk send complete",
+		"success_count":      successCount,
+		"failure_count":      failureCount,
+		"skipped_count":      skippedCount,
+		"rejected_count":     rejectedCount,
+		"rate_limited_count": rateLimitedCount,
+		"total":              len(req.Contacts),
 	}
 
 	utils.RespondJSON(w, http.StatusOK, result)
 }
+
+// isUnmailable reports whether a send was refused because of the contact's
+// subscription state rather than a delivery problem.
+func isUnmailable(err error) bool {
+	return errors.Is(err, services.ErrContactUnsubscribed) ||
+		errors.Is(err, services.ErrContactPaused) ||
+		errors.Is(err, services.ErrContactUnconfirmed)
+}
+
+// jobResult reports the state of a queued email.
+type jobResult struct {
+	JobID         uint   `json:"job_id"`
+	Status        string `json:"status"`
+	StatusMessage string `json:"status_message,omitempty"`
+	MessageID     string `json:"message_id,omitempty"`
+}
+
+func newJobResult(job *models.EmailJob) jobResult {
+	return jobResult{
+		JobID:         job.ID,
+		Status:        job.Status,
+		StatusMessage: job.StatusMessage,
+		MessageID:     job.MessageID,
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+)
+
+// parseOptionalID parses an optional ID from a query or form field; an
+// empty value is nil.
+func parseOptionalID(value string) (*uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return nil, errors.New("invalid ID")
+	}
+	result := uint(id)
+	return &result, nil
+}
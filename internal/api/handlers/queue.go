@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type QueueHandler struct {
+	queueService *services.QueueService
+	auth         *middleware.Auth
+}
+
+func NewQueueHandler(queueService *services.QueueService, auth *middleware.Auth) *QueueHandler {
+	return &QueueHandler{
+		queueService: queueService,
+		auth:         auth,
+	}
+}
+
+func (h *QueueHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	job, err := h.queueService.GetJob(uint(id))
+	if err != nil {
+		respondQueueError(w, err, "failed to fetch email job")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, newJobResult(job))
+}
+
+func (h *QueueHandler) GetDeadJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+	var filter models.DeadJobFilter
+	var err error
+	if filter.CampaignID, err = parseOptionalID(query.Get("campaign_id")); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid campaign_id")
+		return
+	}
+	if filter.BroadcastID, err = parseOptionalID(query.Get("broadcast_id")); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid broadcast_id")
+		return
+	}
+
+	jobs, err := h.queueService.GetDeadJobs(filter, page, perPage)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch dead jobs")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, jobs)
+}
+
+// RequeueDeadJobs returns dead jobs to the queue: {"ids": [...]} or
+// {"all": true}, optionally with campaign_id or broadcast_id.
+func (h *QueueHandler) RequeueDeadJobs(w http.ResponseWriter, r *http.Request) {
+	var filter models.DeadJobFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	requeued, err := h.queueService.RequeueDeadJobs(filter)
+	if err != nil {
+		respondQueueError(w, err, "failed to requeue dead jobs")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]int64{"requeued": requeued})
+}
+
+// DiscardDeadJobs deletes dead jobs, selected as for RequeueDeadJobs.
+func (h *QueueHandler) DiscardDeadJobs(w http.ResponseWriter, r *http.Request) {
+	var filter models.DeadJobFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	discarded, err := h.queueService.DiscardDeadJobs(filter)
+	if err != nil {
+		respondQueueError(w, err, "failed to discard dead jobs")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]int64{"discarded": discarded})
+}
+
+func respondQueueError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		utils.RespondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidDeadJobSet):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type RateLimitHandler struct {
+	rateLimitService *services.RateLimitService
+	auth             *middleware.Auth
+}
+
+func NewRateLimitHandler(rateLimitService *services.RateLimitService, auth *middleware.Auth) *RateLimitHandler {
+	return &RateLimitHandler{
+		rateLimitService: rateLimitService,
+		auth:             auth,
+	}
+}
+
+func (h *RateLimitHandler) CreateRateLimit(w http.ResponseWriter, r *http.Request) {
+	var limit models.RateLimit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	newLimit, err := h.rateLimitService.CreateLimit(&limit)
+	if err != nil {
+		respondRateLimitError(w, err, "failed to create rate limit")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newLimit)
+}
+
+func (h *RateLimitHandler) GetRateLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.rateLimitService.GetLimits(r.URL.Query().Get("scope"))
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch rate limits")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, limits)
+}
+
+func (h *RateLimitHandler) GetRateLimitByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := rateLimitID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := h.rateLimitService.GetLimitByID(id)
+	if err != nil {
+		respondRateLimitError(w, err, "failed to fetch rate limit")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, limit)
+}
+
+// UpdateRateLimit changes a limit's rate and burst; it applies to the next
+// message sent by any worker.
+func (h *RateLimitHandler) UpdateRateLimit(w http.ResponseWriter, r *http.Request) {
+	id, ok := rateLimitID(w, r)
+	if !ok {
+		return
+	}
+
+	var limit models.RateLimit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	updatedLimit, err := h.rateLimitService.UpdateLimit(id, &limit)
+	if err != nil {
+		respondRateLimitError(w, err, "failed to update rate limit")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, updatedLimit)
+}
+
+func (h *RateLimitHandler) DeleteRateLimit(w http.ResponseWriter, r *http.Request) {
+	id, ok := rateLimitID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.rateLimitService.DeleteLimit(id)
+	if err != nil {
+		respondRateLimitError(w, err, "failed to delete rate limit")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "rate limit deleted successfully"})
+}
+
+func rateLimitID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid rate limit ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondRateLimitError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrRateLimitNotFound):
+		utils.RespondError(w, http.StatusNotFound, "rate limit not found")
+	case errors.Is(err, services.ErrInvalidRateLimit):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
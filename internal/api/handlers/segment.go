@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type SegmentHandler struct {
+	segmentService *services.SegmentService
+	auth           *middleware.Auth
+}
+
+func NewSegmentHandler(segmentService *services.SegmentService, auth *middleware.Auth) *SegmentHandler {
+	return &SegmentHandler{
+		segmentService: segmentService,
+		auth:           auth,
+	}
+}
+
+type SegmentPreviewRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+func (h *SegmentHandler) CreateSegment(w http.ResponseWriter, r *http.Request) {
+	var seg models.Segment
+	if err := json.NewDecoder(r.Body).Decode(&seg); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	newSegment, err := h.segmentService.CreateSegment(&seg)
+	if err != nil {
+		respondSegmentError(w, err, "failed to create segment")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newSegment)
+}
+
+func (h *SegmentHandler) GetAllSegments(w http.ResponseWriter, r *http.Request) {
+	segments, err := h.segmentService.GetAllSegments()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch segments")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, segments)
+}
+
+func (h *SegmentHandler) GetSegmentByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	seg, err := h.segmentService.GetSegmentByID(id)
+	if err != nil {
+		respondSegmentError(w, err, "failed to fetch segment")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, seg)
+}
+
+func (h *SegmentHandler) UpdateSegment(w http.ResponseWriter, r *http.Request) {
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	var seg models.Segment
+	if err := json.NewDecoder(r.Body).Decode(&seg); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	updatedSegment, err := h.segmentService.UpdateSegment(id, &seg)
+	if err != nil {
+		respondSegmentError(w, err, "failed to update segment")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, updatedSegment)
+}
+
+func (h *SegmentHandler) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.segmentService.DeleteSegment(id)
+	if err != nil {
+		respondSegmentError(w, err, "failed to delete segment")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "segment deleted successfully"})
+}
+
+// PreviewQuery evaluates an unsaved query, so a segment can be tried out
+// before it is created.
+func (h *SegmentHandler) PreviewQuery(w http.ResponseWriter, r *http.Request) {
+	var req SegmentPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	preview, err := h.segmentService.PreviewQuery(req.Query, req.Limit)
+	if err != nil {
+		respondSegmentError(w, err, "failed to preview segment")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, preview)
+}
+
+func (h *SegmentHandler) PreviewSegment(w http.ResponseWriter, r *http.Request) {
+	id, ok := segmentID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	preview, err := h.segmentService.PreviewSegment(id, limit)
+	if err != nil {
+		respondSegmentError(w, err, "failed to preview segment")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, preview)
+}
+
+func segmentID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid segment ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondSegmentError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSegmentNotFound):
+		utils.RespondError(w, http.StatusNotFound, "segment not found")
+	case errors.Is(err, services.ErrInvalidSegment):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPublicRequestSize bounds the bodies accepted on public routes.
+const maxPublicRequestSize = 64 << 10
+
+// SubscriptionHandler serves the public pages behind the links in mail sent
+// to contacts. Requests are authorized by the signed token in the URL.
+type SubscriptionHandler struct {
+	subscriptionService *services.SubscriptionService
+}
+
+func NewSubscriptionHandler(subscriptionService *services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		subscriptionService: subscriptionService,
+	}
+}
+
+var subscriptionPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+button { padding: .6rem 1.2rem; font-size: 1rem; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post"><button type="submit">{{.Confirm}}</button></form>{{end}}
+</body>
+</html>
+`))
+
+var signupPage = template.Must(template.New("signup").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Subscribe</title>
+<style>
+body { font-family: sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+label { display: block; margin: .6rem 0; }
+input[type=email], input[type=text] { width: 100%; padding: .4rem; box-sizing: border-box; }
+button { padding: .6rem 1.2rem; font-size: 1rem; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>Subscribe</h1>
+{{if .Lists}}<form method="post">
+<label>Email <input type="email" name="email" required></label>
+<label>First name <input type="text" name="first_name"></label>
+<label>Last name <input type="text" name="last_name"></label>
+{{range .Lists}}<label><input type="checkbox" name="list_ids" value="{{.ID}}"{{if index $.Selected .ID}} checked{{end}}> {{.Name}}</label>
+{{end}}<button type="submit">Subscribe</button>
+</form>{{else}}<p>There are no lists to subscribe to.</p>{{end}}
+</body>
+</html>
+`))
+
+// subscriptionPageData fills the simple pages. Confirm is the label of the
+// button that submits the page, if any.
+type subscriptionPageData struct {
+	Title   string
+	Message string
+	Confirm string
+}
+
+// UnsubscribePage asks the contact to confirm. Unsubscribing on GET would let
+// link scanners and prefetching unsubscribe contacts who never clicked.
+func (h *SubscriptionHandler) UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	contact, err := h.subscriptionService.GetContactByToken(chi.URLParam(r, "token"))
+	if err != nil {
+		renderSubscriptionError(w, err)
+		return
+	}
+
+	if contact.UnSubscribe {
+		renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+			Title:   "Unsubscribed",
+			Message: contact.Email + " is already unsubscribed.",
+		})
+		return
+	}
+	renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+		Title:   "Unsubscribe",
+		Message: "Stop sending emails to " + contact.Email + "?",
+		Confirm: "Unsubscribe",
+	})
+}
+
+// Unsubscribe handles the confirmation form, RFC 8058 one-click requests
+// (a POST with List-Unsubscribe=One-Click) and API calls alike. Browsers get
+// a page back, everything else JSON.
+func (h *SubscriptionHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	contact, err := h.subscriptionService.Unsubscribe(chi.URLParam(r, "token"), requestInfo(r))
+	if !acceptsHTML(r) {
+		switch {
+		case errors.Is(err, services.ErrInvalidToken):
+			utils.RespondError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			utils.RespondError(w, http.StatusInternalServerError, "failed to unsubscribe")
+		default:
+			utils.RespondJSON(w, http.StatusOK, map[string]string{
+				"message": "unsubscribed successfully",
+				"email":   contact.Email,
+			})
+		}
+		return
+	}
+
+	if err != nil {
+		renderSubscriptionError(w, err)
+		return
+	}
+	renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+		Title:   "Unsubscribed",
+		Message: contact.Email + " will no longer receive these emails.",
+	})
+}
+
+// SignupPage renders a signup form for the public lists. list_ids in the
+// query string preselects lists.
+func (h *SubscriptionHandler) SignupPage(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.subscriptionService.GetPublicLists()
+	if err != nil {
+		renderSubscriptionError(w, err)
+		return
+	}
+
+	selected := map[uint]bool{}
+	for _, id := range r.URL.Query()["list_ids"] {
+		if n, err := strconv.Atoi(id); err == nil && n > 0 {
+			selected[uint(n)] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = signupPage.Execute(w, map[string]interface{}{"Lists": lists, "Selected": selected})
+	if err != nil {
+		log.Printf("Error rendering signup page: %v", err)
+	}
+}
+
+// Signup takes a JSON body from the API or a form post from a signup form.
+func (h *SubscriptionHandler) Signup(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPublicRequestSize)
+	req, err := signupRequest(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	confirm, err := h.subscriptionService.Signup(req, requestInfo(r))
+	message := "subscribed successfully"
+	if confirm {
+		message = "check your inbox to confirm your subscription"
+	}
+	if !acceptsHTML(r) {
+		switch {
+		case errors.Is(err, services.ErrInvalidSignup):
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+		case err != nil:
+			log.Printf("Error handling signup: %v", err)
+			utils.RespondError(w, http.StatusInternalServerError, "failed to subscribe")
+		default:
+			utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+				"message":               message,
+				"confirmation_required": confirm,
+			})
+		}
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvalidSignup):
+		renderSubscriptionPage(w, http.StatusBadRequest, subscriptionPageData{
+			Title:   "Could not subscribe",
+			Message: strings.TrimPrefix(err.Error(), services.ErrInvalidSignup.Error()+": "),
+		})
+	case err != nil:
+		renderSubscriptionError(w, err)
+	case confirm:
+		renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+			Title:   "Almost done",
+			Message: "We sent you an email. Follow the link in it to confirm your subscription.",
+		})
+	default:
+		renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+			Title:   "Subscribed",
+			Message: "You are subscribed.",
+		})
+	}
+}
+
+// ConfirmPage asks the contact to confirm, for the same reason as
+// UnsubscribePage.
+func (h *SubscriptionHandler) ConfirmPage(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.subscriptionService.GetContactByConfirmToken(chi.URLParam(r, "token")); err != nil {
+		renderSubscriptionError(w, err)
+		return
+	}
+
+	renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+		Title:   "Confirm subscription",
+		Message: "Confirm that you want to receive these emails.",
+		Confirm: "Confirm",
+	})
+}
+
+func (h *SubscriptionHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	contact, err := h.subscriptionService.Confirm(chi.URLParam(r, "token"), requestInfo(r))
+	if !acceptsHTML(r) {
+		switch {
+		case errors.Is(err, services.ErrInvalidToken):
+			utils.RespondError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			utils.RespondError(w, http.StatusInternalServerError, "failed to confirm subscription")
+		default:
+			utils.RespondJSON(w, http.StatusOK, map[string]string{
+				"message": "subscription confirmed",
+				"email":   contact.Email,
+			})
+		}
+		return
+	}
+
+	if err != nil {
+		renderSubscriptionError(w, err)
+		return
+	}
+	renderSubscriptionPage(w, http.StatusOK, subscriptionPageData{
+		Title:   "Subscription confirmed",
+		Message: contact.Email + " is now subscribed.",
+	})
+}
+
+func signupRequest(r *http.Request) (services.SignupRequest, error) {
+	var req services.SignupRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		return req, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return req, err
+	}
+	req.Email = r.PostForm.Get("email")
+	req.FirstName = r.PostForm.Get("first_name")
+	req.LastName = r.PostForm.Get("last_name")
+	for _, value := range r.PostForm["list_ids"] {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return req, errors.New("invalid list ID")
+		}
+		req.ListIDs = append(req.ListIDs, uint(id))
+	}
+	return req, nil
+}
+
+// GetPreferences returns the contact's profile and lists for the preference
+// center.
+func (h *SubscriptionHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	preferences, err := h.subscriptionService.GetPreferences(chi.URLParam(r, "token"))
+	if err != nil {
+		respondPreferencesError(w, err, "failed to fetch preferences")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, preferences)
+}
+
+func (h *SubscriptionHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var update services.PreferenceUpdate
+	r.Body = http.MaxBytesReader(w, r.Body, maxPublicRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	preferences, err := h.subscriptionService.UpdatePreferences(chi.URLParam(r, "token"), update, requestInfo(r))
+	if err != nil {
+		respondPreferencesError(w, err, "failed to update preferences")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, preferences)
+}
+
+// GetPreferenceAudit lists the changes a contact made through public links.
+func (h *SubscriptionHandler) GetPreferenceAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid contact ID")
+		return
+	}
+
+	audits, err := h.subscriptionService.GetPreferenceAudit(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch preference audit")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, audits)
+}
+
+func requestInfo(r *http.Request) services.RequestInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return services.RequestInfo{
+		IPAddress: ip,
+		UserAgent: r.UserAgent(),
+	}
+}
+
+func respondPreferencesError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidToken):
+		utils.RespondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidPreferences), errors.Is(err, services.ErrInvalidAttribute):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
+
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func renderSubscriptionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrInvalidToken) {
+		renderSubscriptionPage(w, http.StatusNotFound, subscriptionPageData{
+			Title:   "Link not valid",
+			Message: "This link is invalid or has expired.",
+		})
+		return
+	}
+	log.Printf("Error handling subscription request: %v", err)
+	renderSubscriptionPage(w, http.StatusInternalServerError, subscriptionPageData{
+		Title:   "Something went wrong",
+		Message: "Your request could not be completed. Please try again later.",
+	})
+}
+
+func renderSubscriptionPage(w http.ResponseWriter, status int, data subscriptionPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	if err := subscriptionPage.Execute(w, data); err != nil {
+		log.Printf("Error rendering subscription page: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxSuppressionImportSize bounds the body of a bulk suppression import.
+const maxSuppressionImportSize = 10 << 20
+
+type SuppressionHandler struct {
+	suppressionService *services.SuppressionService
+	auth               *middleware.Auth
+}
+
+func NewSuppressionHandler(suppressionService *services.SuppressionService, auth *middleware.Auth) *SuppressionHandler {
+	return &SuppressionHandler{
+		suppressionService: suppressionService,
+		auth:               auth,
+	}
+}
+
+func (h *SuppressionHandler) CreateSuppression(w http.ResponseWriter, r *http.Request) {
+	var suppression models.Suppression
+	if err := json.NewDecoder(r.Body).Decode(&suppression); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	suppression.Source = ""
+
+	newSuppression, err := h.suppressionService.CreateSuppression(&suppression)
+	if err != nil {
+		respondSuppressionError(w, err, "failed to create suppression")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newSuppression)
+}
+
+func (h *SuppressionHandler) GetSuppressions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+	suppressions, err := h.suppressionService.GetSuppressions(query.Get("type"), query.Get("search"), page, perPage)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch suppressions")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, suppressions)
+}
+
+func (h *SuppressionHandler) GetSuppressionByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := suppressionID(w, r)
+	if !ok {
+		return
+	}
+
+	suppression, err := h.suppressionService.GetSuppressionByID(id)
+	if err != nil {
+		respondSuppressionError(w, err, "failed to fetch suppression")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, suppression)
+}
+
+func (h *SuppressionHandler) UpdateSuppression(w http.ResponseWriter, r *http.Request) {
+	id, ok := suppressionID(w, r)
+	if !ok {
+		return
+	}
+
+	var suppression models.Suppression
+	if err := json.NewDecoder(r.Body).Decode(&suppression); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	updatedSuppression, err := h.suppressionService.UpdateSuppression(id, &suppression)
+	if err != nil {
+		respondSuppressionError(w, err, "failed to update suppression")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, updatedSuppression)
+}
+
+func (h *SuppressionHandler) DeleteSuppression(w http.ResponseWriter, r *http.Request) {
+	id, ok := suppressionID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.suppressionService.DeleteSuppression(id)
+	if err != nil {
+		respondSuppressionError(w, err, "failed to delete suppression")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "suppression deleted successfully"})
+}
+
+// ImportSuppressions adds suppressions in bulk. A text/csv body has one
+// address or domain per row, optionally followed by a reason and details;
+// the reason query parameter applies to rows without one. Any other body is
+// read as JSON: {"suppressions": [{"value": ..., "type": ..., "reason": ...}]}.
+func (h *SuppressionHandler) ImportSuppressions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSuppressionImportSize)
+
+	var entries []models.Suppression
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		var err error
+		entries, err = services.ParseSuppressionCSV(r.Body, r.URL.Query().Get("reason"))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				utils.RespondError(w, http.StatusRequestEntityTooLarge, "import is too large")
+				return
+			}
+			respondSuppressionError(w, err, "failed to read import")
+			return
+		}
+	} else {
+		var req struct {
+			Suppressions []models.Suppression `json:"suppressions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "invalid request payload")
+			return
+		}
+		entries = req.Suppressions
+	}
+	if len(entries) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "at least one suppression is required")
+		return
+	}
+	for i := range entries {
+		entries[i].Source = ""
+	}
+
+	result, err := h.suppressionService.ImportSuppressions(entries)
+	if err != nil {
+		respondSuppressionError(w, err, "failed to import suppressions")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, result)
+}
+
+func suppressionID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "invalid suppression ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondSuppressionError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSuppressionNotFound):
+		utils.RespondError(w, http.StatusNotFound, "suppression not found")
+	case errors.Is(err, services.ErrInvalidSuppression):
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/api/middleware"
+	"github.com/MdSadiqMd/Broadcast-API/internal/services"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxWebhookSize bounds a webhook body; providers batch up to a few
+// thousand events per request.
+const maxWebhookSize = 5 << 20
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	auth           *middleware.Auth
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, auth *middleware.Auth) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		auth:           auth,
+	}
+}
+
+// ReceiveWebhook takes delivery events from a sending provider. It is a
+// public route; requests are authenticated by the provider's signature.
+func (h *WebhookHandler) ReceiveWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, "webhook payload is too large")
+		return
+	}
+
+	result, err := h.webhookService.HandleWebhook(chi.URLParam(r, "provider"), r.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookProviderNotFound):
+			utils.RespondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInvalidWebhookSignature):
+			utils.RespondError(w, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, services.ErrInvalidWebhookPayload):
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, "failed to process webhook")
+		}
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, result)
+}
+
+func (h *WebhookHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+	events, err := h.webhookService.GetEvents(query.Get("provider"), query.Get("status"), page, perPage)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "failed to fetch webhook events")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, events)
+}
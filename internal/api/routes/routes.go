@@ -9,6 +9,7 @@ import (
 	"github.com/MdSadiqMd/Broadcast-API/internal/services"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/webhook"
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 )
@@ -18,22 +19,35 @@ func Setup(r *chi.Mux, db *gorm.DB, jwtSecret string) {
 	compaignService := services.NewCampaignService(db)
 	contactService := services.NewContactService(db)
 	broadcastService := services.NewBroadcastService(db)
+	listService := services.NewListService(db)
+	segmentService := services.NewSegmentService(db)
+	attributeService := services.NewAttributeService(db)
+	suppressionService := services.NewSuppressionService(db)
 
 	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
 	}
-	smtpClient := email.NewSMTPClient(email.SMTPConfig{
-		Host:     cfg.SMTP.Host,
-		Port:     cfg.SMTP.Port,
-		Username: cfg.SMTP.Username,
-		Password: cfg.SMTP.Password,
-		FromName: cfg.SMTP.FromName,
-		FromAddr: cfg.SMTP.FromAddr,
-		UseTLS:   false,
-	})
+	mailer, err := email.NewMailer(*cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	webhookProviders, err := webhook.NewProviders(*cfg)
+	if err != nil {
+		panic(err)
+	}
 
-	mailService := services.NewMailService(db, smtpClient)
+	mailService := services.NewMailService(db, mailer, *cfg)
+	subscriptionService := services.NewSubscriptionService(db, mailService, *cfg)
+	deliveryService := services.NewDeliveryService(db, *cfg)
+	queueService := services.NewQueueService(db, cfg.Queue)
+	rateLimitService := services.NewRateLimitService(db, cfg.Queue)
+	webhookService := services.NewWebhookService(db, deliveryService, webhookProviders)
+	contactImportService := services.NewContactImportService(db, cfg.Imports)
+	contactImportService.ResumeImports()
+	contactExportService := services.NewContactExportService(db, cfg.Exports)
+	contactExportService.ResumeExports()
 	auth := appMiddleware.NewAuth(appMiddleware.AuthConfig{
 		JWTSecret:     jwtSecret,
 		TokenDuration: 24 * time.Hour,
@@ -44,7 +58,18 @@ func Setup(r *chi.Mux, db *gorm.DB, jwtSecret string) {
 	compaignHandler := handlers.NewCampaignHandler(compaignService, auth)
 	contactHandler := handlers.NewContactHandler(contactService, auth)
 	broadcastHandler := handlers.NewBroadcastHandler(broadcastService, auth)
-	mailHandler := handlers.NewMailHandler(mailService, auth)
+	listHandler := handlers.NewListHandler(listService, auth)
+	segmentHandler := handlers.NewSegmentHandler(segmentService, auth)
+	attributeHandler := handlers.NewAttributeHandler(attributeService, auth)
+	contactImportHandler := handlers.NewContactImportHandler(contactImportService, auth, cfg.Imports)
+	contactExportHandler := handlers.NewContactExportHandler(contactExportService, auth)
+	mailHandler := handlers.NewMailHandler(mailService, queueService, auth, cfg.Attachments)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService)
+	suppressionHandler := handlers.NewSuppressionHandler(suppressionService, auth)
+	deliveryHandler := handlers.NewDeliveryHandler(deliveryService, auth)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, auth)
+	queueHandler := handlers.NewQueueHandler(queueService, auth)
+	rateLimitHandler := handlers.NewRateLimitHandler(rateLimitService, auth)
 
 	r.Use(auth.Middleware())
 
@@ -52,6 +77,20 @@ func Setup(r *chi.Mux, db *gorm.DB, jwtSecret string) {
 		r.Post("/login", authHandler.Login)
 		r.Post("/register", authHandler.Register)
 
+		// Public routes; links sent to contacts are authorized by their signed
+		// token, and provider webhooks by their signature
+		r.Route("/public", func(r chi.Router) {
+			r.Get("/unsubscribe/{token}", subscriptionHandler.UnsubscribePage)
+			r.Post("/unsubscribe/{token}", subscriptionHandler.Unsubscribe)
+			r.Get("/preferences/{token}", subscriptionHandler.GetPreferences)
+			r.Put("/preferences/{token}", subscriptionHandler.UpdatePreferences)
+			r.Get("/subscribe", subscriptionHandler.SignupPage)
+			r.Post("/subscribe", subscriptionHandler.Signup)
+			r.Get("/confirm/{token}", subscriptionHandler.ConfirmPage)
+			r.Post("/confirm/{token}", subscriptionHandler.Confirm)
+			r.Post("/webhooks/{provider}", webhookHandler.ReceiveWebhook)
+		})
+
 		// Protected Routes
 		r.Group(func(r chi.Router) {
 			r.Use(auth.Middleware())
@@ -65,6 +104,38 @@ func Setup(r *chi.Mux, db *gorm.DB, jwtSecret string) {
 			r.Get("/contact/{id}", contactHandler.GetContactByID)
 			r.Put("/contact/{id}", contactHandler.UpdateContact)
 			r.Delete("/contact/{id}", contactHandler.DeleteContact)
+			r.Get("/contact/{id}/preferences/audit", subscriptionHandler.GetPreferenceAudit)
+			r.Post("/contacts/import", contactImportHandler.ImportContacts)
+			r.Get("/contacts/imports", contactImportHandler.ListImports)
+			r.Get("/contacts/import/{id}", contactImportHandler.GetImport)
+			r.Get("/contacts/import/{id}/errors", contactImportHandler.GetImportErrors)
+			r.Post("/contacts/attribute", attributeHandler.CreateDefinition)
+			r.Get("/contacts/attributes", attributeHandler.GetAllDefinitions)
+			r.Get("/contacts/attribute/{id}", attributeHandler.GetDefinitionByID)
+			r.Put("/contacts/attribute/{id}", attributeHandler.UpdateDefinition)
+			r.Delete("/contacts/attribute/{id}", attributeHandler.DeleteDefinition)
+			r.Get("/contacts/export", contactExportHandler.ExportContacts)
+			r.Post("/contacts/exports", contactExportHandler.CreateExport)
+			r.Get("/contacts/exports", contactExportHandler.ListExports)
+			r.Get("/contacts/export/{id}", contactExportHandler.GetExport)
+			r.Get("/contacts/export/{id}/download", contactExportHandler.DownloadExport)
+
+			r.Post("/list", listHandler.CreateList)
+			r.Get("/lists", listHandler.GetAllLists)
+			r.Get("/list/{id}", listHandler.GetListByID)
+			r.Put("/list/{id}", listHandler.UpdateList)
+			r.Delete("/list/{id}", listHandler.DeleteList)
+			r.Get("/list/{id}/contacts", listHandler.GetListContacts)
+			r.Post("/list/{id}/contacts", listHandler.AddContacts)
+			r.Delete("/list/{id}/contacts", listHandler.RemoveContacts)
+
+			r.Post("/segment", segmentHandler.CreateSegment)
+			r.Get("/segments", segmentHandler.GetAllSegments)
+			r.Post("/segments/preview", segmentHandler.PreviewQuery)
+			r.Get("/segment/{id}", segmentHandler.GetSegmentByID)
+			r.Put("/segment/{id}", segmentHandler.UpdateSegment)
+			r.Delete("/segment/{id}", segmentHandler.DeleteSegment)
+			r.Get("/segment/{id}/preview", segmentHandler.PreviewSegment)
 
 			r.Post("/broadcast", broadcastHandler.CreateBroadcast)
 			r.Get("/broadcast/{id}", broadcastHandler.GetBroadcastByID)
@@ -75,9 +146,30 @@ func Setup(r *chi.Mux, db *gorm.DB, jwtSecret string) {
 
 			r.Post("/mail/test", mailHandler.SendTestEmail)
 			r.Post("/mail/transactional", mailHandler.SendTransactionalEmail)
+			r.Get("/mail/job/{id}", queueHandler.GetJob)
 			r.Post("/mail/job/{id}/process", mailHandler.ProcessEmailJob)
 			r.Post("/mail/campaign/send", mailHandler.ProcessCampaignEmail)
 			r.Post("/mail/campaign/bulk", mailHandler.BulkSendCampaign)
+			r.Get("/mail/jobs/dead", queueHandler.GetDeadJobs)
+			r.Post("/mail/jobs/dead/requeue", queueHandler.RequeueDeadJobs)
+			r.Post("/mail/jobs/dead/discard", queueHandler.DiscardDeadJobs)
+			r.Post("/mail/rate-limit", rateLimitHandler.CreateRateLimit)
+			r.Get("/mail/rate-limits", rateLimitHandler.GetRateLimits)
+			r.Get("/mail/rate-limit/{id}", rateLimitHandler.GetRateLimitByID)
+			r.Put("/mail/rate-limit/{id}", rateLimitHandler.UpdateRateLimit)
+			r.Delete("/mail/rate-limit/{id}", rateLimitHandler.DeleteRateLimit)
+
+			r.Post("/suppression", suppressionHandler.CreateSuppression)
+			r.Get("/suppressions", suppressionHandler.GetSuppressions)
+			r.Post("/suppressions/import", suppressionHandler.ImportSuppressions)
+			r.Get("/suppression/{id}", suppressionHandler.GetSuppressionByID)
+			r.Put("/suppression/{id}", suppressionHandler.UpdateSuppression)
+			r.Delete("/suppression/{id}", suppressionHandler.DeleteSuppression)
+
+			r.Post("/bounces", deliveryHandler.RecordBounce)
+			r.Get("/bounces", deliveryHandler.GetBounces)
+			r.Post("/complaints", deliveryHandler.RecordComplaint)
+			r.Get("/webhooks/events", webhookHandler.GetEvents)
 
 			// Admin Routes
 			r.Group(func(r chi.Router) {
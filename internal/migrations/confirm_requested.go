@@ -0,0 +1,13 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// backfillConfirmRequested dates the pending confirmation of contacts that
+// signed up before confirm_requested_at existed from their updated_at, which
+// their links were dated from.
+func backfillConfirmRequested(tx *gorm.DB) error {
+	return tx.Exec(`UPDATE contacts SET confirm_requested_at = updated_at
+		WHERE status = 'unconfirmed' AND confirm_requested_at IS NULL`).Error
+}
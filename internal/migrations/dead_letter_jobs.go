@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// deadLetterFailedJobs moves jobs that failed for good before the dead-letter
+// queue existed into it, so they can be requeued or discarded like any
+// other. Running it again finds nothing to move.
+func deadLetterFailedJobs(tx *gorm.DB) error {
+	return tx.Exec(`
+		UPDATE email_jobs SET status = 'dead', locked_by = '', locked_until = NULL
+		WHERE status = 'failed'
+	`).Error
+}
@@ -0,0 +1,12 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// dropStatusCreatedIndex drops the index jobs were claimed by before they
+// had a priority; idx_email_jobs_claim, which AutoMigrate creates, replaces
+// it.
+func dropStatusCreatedIndex(tx *gorm.DB) error {
+	return tx.Exec(`DROP INDEX IF EXISTS idx_email_jobs_status_created`).Error
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// mergeSubscribers folds the legacy subscribers table into contacts. Rows are
+// matched by email; contacts that already exist keep their own fields and
+// only pick up attributes they did not have.
+func mergeSubscribers(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasTable("subscribers") {
+		return nil
+	}
+
+	err := tx.Exec(`
+		INSERT INTO contacts (email, first_name, last_name, status, attributes, un_subscribe, created_at, updated_at, deleted_at)
+		SELECT
+			s.email,
+			split_part(btrim(s.name), ' ', 1),
+			btrim(substr(btrim(s.name), length(split_part(btrim(s.name), ' ', 1)) + 1)),
+			COALESCE(NULLIF(s.status, ''), 'enabled'),
+			CASE WHEN jsonb_typeof(s.metadata) = 'object' THEN s.metadata ELSE '{}'::jsonb END,
+			false,
+			s.created_at,
+			s.updated_at,
+			s.deleted_at
+		FROM subscribers s
+		ON CONFLICT (email) DO UPDATE SET
+			attributes = EXCLUDED.attributes || COALESCE(contacts.attributes, '{}'::jsonb),
+			updated_at = now()
+	`).Error
+	if err != nil {
+		return err
+	}
+
+	if migrator.HasTable("list_subscribers") {
+		err = tx.Exec(`
+			INSERT INTO list_contacts (list_id, contact_id, created_at)
+			SELECT ls.list_id, c.id, now()
+			FROM list_subscribers ls
+			JOIN subscribers s ON s.id = ls.subscriber_id
+			JOIN contacts c ON c.email = s.email
+			ON CONFLICT DO NOTHING
+		`).Error
+		if err != nil {
+			return err
+		}
+		if err = migrator.DropTable("list_subscribers"); err != nil {
+			return err
+		}
+	}
+
+	// The scheduler used to store contact IDs in email_jobs.subscriber_id,
+	// so the column is carried over as-is wherever it names a contact.
+	if migrator.HasColumn("email_jobs", "subscriber_id") {
+		err = tx.Exec(`
+			UPDATE email_jobs SET contact_id = subscriber_id
+			WHERE contact_id IS NULL
+				AND subscriber_id IN (SELECT id FROM contacts)
+		`).Error
+		if err != nil {
+			return err
+		}
+		if err = migrator.DropColumn("email_jobs", "subscriber_id"); err != nil {
+			return err
+		}
+	}
+
+	return migrator.DropTable("subscribers")
+}
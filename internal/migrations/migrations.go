@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type migration struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// Data and schema changes that AutoMigrate cannot express. Each step checks
+// the current schema itself, so Run is safe to call on every start.
+var migrations = []migration{
+	{name: "merge subscribers into contacts", run: mergeSubscribers},
+	{name: "move failed email jobs to the dead-letter queue", run: deadLetterFailedJobs},
+	{name: "notify workers of queued email jobs", run: notifyQueuedJobs},
+	{name: "claim email jobs by priority", run: dropStatusCreatedIndex},
+	{name: "date pending confirmations", run: backfillConfirmRequested},
+}
+
+func Run(db *gorm.DB) error {
+	for _, m := range migrations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return m.run(tx)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %q failed: %w", m.name, err)
+		}
+	}
+	return nil
+}
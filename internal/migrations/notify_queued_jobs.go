@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+// notifyQueuedJobs installs a trigger that notifies workers whenever a job
+// is queued and due, however it was queued and by whichever replica.
+// Notifications are sent on commit, and Postgres collapses those sent in
+// one transaction, so queueing a whole campaign wakes the workers once.
+func notifyQueuedJobs(tx *gorm.DB) error {
+	err := tx.Exec(fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION notify_email_job_queued() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('%s', '');
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql
+	`, models.EmailJobQueuedChannel)).Error
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Exec(`DROP TRIGGER IF EXISTS email_jobs_queued ON email_jobs`).Error; err != nil {
+		return err
+	}
+	return tx.Exec(fmt.Sprintf(`
+		CREATE TRIGGER email_jobs_queued
+		AFTER INSERT OR UPDATE OF status, next_attempt_at ON email_jobs
+		FOR EACH ROW
+		WHEN (NEW.status = '%s' AND (NEW.next_attempt_at IS NULL OR NEW.next_attempt_at <= now()))
+		EXECUTE FUNCTION notify_email_job_queued()
+	`, models.EmailJobStatusQueued)).Error
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeDate    = "date"
+)
+
+// AttributeDefinition declares a contact attribute. Attributes without a
+// definition are stored as given; defined ones are converted to their type,
+// filled with Default when missing and rejected when Required and missing.
+type AttributeDefinition struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Key         string    `gorm:"uniqueIndex;size:100" json:"key"`
+	Type        string    `gorm:"size:20" json:"type"`
+	Required    bool      `json:"required"`
+	Default     JSONValue `gorm:"column:default_value;type:jsonb" json:"default"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
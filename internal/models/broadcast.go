@@ -6,23 +6,35 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	BroadcastStatusDraft     = "draft"
+	BroadcastStatusScheduled = "scheduled"
+	BroadcastStatusSending   = "sending"
+	BroadcastStatusSent      = "sent"
+)
+
 type Broadcast struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	Name        string         `json:"name"`
-	AudienceID  uint           `json:"audience_id" gorm:"index"`
-	CampaignID  uint           `json:"campaign_id" gorm:"index"`
-	UserID      uint           `json:"user_id" gorm:"index"`
-	From        string         `json:"from"`
-	Subject     string         `json:"subject"`
-	ReplyTo     string         `json:"reply_to"`
-	HTML        string         `json:"html"`
-	Text        string         `json:"text"`
-	Status      string         `gorm:"default:draft" json:"status"`
-	SentAt      *time.Time     `json:"sent_at"`
-	Campaign    *Campaign      `gorm:"foreignKey:CampaignID" json:"campaign"`
-	User        *User          `gorm:"foreignKey:UserID" json:"user"`
-	ScheduledAt *time.Time     `json:"scheduled_at,omitempty"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primarykey" json:"id"`
+	Name          string         `json:"name"`
+	AudienceID    uint           `json:"audience_id" gorm:"index"`
+	ListIDs       []uint         `gorm:"-" json:"list_ids"`
+	Lists         []List         `gorm:"many2many:broadcast_audience_lists;" json:"lists,omitempty"`
+	SegmentID     *uint          `gorm:"index" json:"segment_id"`
+	Segment       *Segment       `gorm:"foreignKey:SegmentID" json:"segment,omitempty"`
+	CampaignID    uint           `json:"campaign_id" gorm:"index"`
+	UserID        uint           `json:"user_id" gorm:"index"`
+	From          string         `json:"from"`
+	Subject       string         `json:"subject"`
+	ReplyTo       string         `json:"reply_to"`
+	HTML          string         `json:"html"`
+	Text          string         `json:"text"`
+	Status        string         `gorm:"default:draft" json:"status"`
+	StatusMessage string         `gorm:"size:255" json:"status_message"`
+	SentAt        *time.Time     `json:"sent_at"`
+	Campaign      *Campaign      `gorm:"foreignKey:CampaignID" json:"campaign"`
+	User          *User          `gorm:"foreignKey:UserID" json:"user"`
+	ScheduledAt   *time.Time     `json:"scheduled_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
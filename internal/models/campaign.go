@@ -16,6 +16,10 @@ type Campaign struct {
 	QueuedAt      *time.Time     `json:"queued_at"`
 	CompletedAt   *time.Time     `json:"completed_at"`
 	Contacts      []*Contact     `gorm:"many2many:campaign_audiences;" json:"contacts"`
+	ListIDs       []uint         `gorm:"-" json:"list_ids"`
+	Lists         []List         `gorm:"many2many:campaign_audience_lists;" json:"lists,omitempty"`
+	SegmentID     *uint          `gorm:"index" json:"segment_id"`
+	Segment       *Segment       `gorm:"foreignKey:SegmentID" json:"segment,omitempty"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ContactExportStatusPending   = "pending"
+	ContactExportStatusRunning   = "running"
+	ContactExportStatusCompleted = "completed"
+	ContactExportStatusFailed    = "failed"
+
+	ContactExportFormatCSV    = "csv"
+	ContactExportFormatNDJSON = "ndjson"
+)
+
+// ContactFilter narrows a set of contacts; zero fields do not filter.
+type ContactFilter struct {
+	ListID       *uint  `json:"list_id,omitempty"`
+	CampaignID   *uint  `json:"campaign_id,omitempty"`
+	Status       string `gorm:"size:50" json:"status,omitempty"`
+	Unsubscribed *bool  `json:"unsubscribed,omitempty"`
+	Email        string `gorm:"size:255" json:"email,omitempty"`
+}
+
+type ContactExport struct {
+	ID            uint          `gorm:"primarykey" json:"id"`
+	Format        string        `gorm:"size:10" json:"format"`
+	Filter        ContactFilter `gorm:"embedded;embeddedPrefix:filter_" json:"filter"`
+	Status        string        `gorm:"size:50;default:pending;index" json:"status"`
+	StatusMessage string        `gorm:"size:255" json:"status_message"`
+	Exported      int64         `json:"exported"`
+	Size          int64         `json:"size"`
+	FilePath      string        `gorm:"size:500" json:"-"`
+	CreatedBy     uint          `json:"created_by"`
+	StartedAt     *time.Time    `json:"started_at"`
+	CompletedAt   *time.Time    `json:"completed_at"`
+	ExpiresAt     *time.Time    `json:"expires_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
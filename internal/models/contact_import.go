@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ContactImportStatusPending   = "pending"
+	ContactImportStatusRunning   = "running"
+	ContactImportStatusCompleted = "completed"
+	ContactImportStatusFailed    = "failed"
+
+	ContactImportFormatCSV   = "csv"
+	ContactImportFormatJSONL = "jsonl"
+)
+
+type ContactImport struct {
+	ID            uint                 `gorm:"primarykey" json:"id"`
+	Filename      string               `gorm:"size:255" json:"filename"`
+	Format        string               `gorm:"size:10" json:"format"`
+	FilePath      string               `gorm:"size:500" json:"-"`
+	Mapping       JSONMap              `gorm:"type:jsonb;default:'{}'" json:"mapping"`
+	ListID        *uint                `json:"list_id"`
+	CampaignID    *uint                `json:"campaign_id"`
+	Status        string               `gorm:"size:50;default:pending;index" json:"status"`
+	StatusMessage string               `gorm:"size:255" json:"status_message"`
+	Processed     int64                `json:"processed"`
+	Created       int64                `json:"created"`
+	Updated       int64                `json:"updated"`
+	Rejected      int64                `json:"rejected"`
+	CreatedBy     uint                 `json:"created_by"`
+	StartedAt     *time.Time           `json:"started_at"`
+	CompletedAt   *time.Time           `json:"completed_at"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	Errors        []ContactImportError `gorm:"foreignKey:ImportID" json:"errors,omitempty"`
+}
+
+type ContactImportError struct {
+	ID       uint   `gorm:"primarykey" json:"id"`
+	ImportID uint   `gorm:"index" json:"import_id"`
+	Row      int64  `json:"row"`
+	Email    string `gorm:"size:255" json:"email"`
+	Reason   string `gorm:"size:500" json:"reason"`
+}
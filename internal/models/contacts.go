@@ -1,19 +1,55 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	ContactStatusEnabled     = "enabled"
+	ContactStatusBlocklisted = "blocklisted"
+	// ContactStatusUnconfirmed marks a contact that signed up to double
+	// opt-in lists and has not confirmed yet.
+	ContactStatusUnconfirmed = "unconfirmed"
+)
+
+const (
+	ListContactStatusSubscribed   = "subscribed"
+	ListContactStatusUnsubscribed = "unsubscribed"
+	ListContactStatusUnconfirmed  = "unconfirmed"
+)
+
+// Contact is a recipient. ConfirmRequestedAt is when the latest confirmation
+// link was sent, which an unconfirmed contact expires from.
 type Contact struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	FirstName   string         `json:"first_name"`
-	LastName    string         `json:"last_name"`
-	Email       string         `gorm:"uniqueIndex;size:255" json:"email"`
-	UnSubscribe bool           `json:"unsubscribe"`
-	Campaigns   []*Campaign    `gorm:"many2many:campaign_audiences;" json:"campaigns"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primarykey" json:"id"`
+	FirstName          string         `json:"first_name"`
+	LastName           string         `json:"last_name"`
+	Email              string         `gorm:"uniqueIndex;size:255" json:"email"`
+	Status             string         `gorm:"size:50;default:enabled" json:"status"`
+	Attributes         JSONMap        `gorm:"type:jsonb;default:'{}'" json:"attributes"`
+	UnSubscribe        bool           `json:"unsubscribe"`
+	PausedUntil        *time.Time     `json:"paused_until"`
+	ConfirmRequestedAt *time.Time     `gorm:"index" json:"confirm_requested_at,omitempty"`
+	Campaigns          []*Campaign    `gorm:"many2many:campaign_audiences;" json:"campaigns"`
+	Lists              []List         `gorm:"many2many:list_contacts;" json:"lists,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (c Contact) Name() string {
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// ListContact is a list membership. Opting out of a list keeps the row as
+// unsubscribed, so adding the contact to the list again does not override it.
+type ListContact struct {
+	ListID    uint      `gorm:"primaryKey" json:"list_id"`
+	ContactID uint      `gorm:"primaryKey" json:"contact_id"`
+	Status    string    `gorm:"size:50;default:subscribed;not null" json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -10,27 +10,80 @@ const (
 	EmailJobStatusQueued   = "queued"
 	EmailJobStatusSending  = "sending"
 	EmailJobStatusSent     = "sent"
-	EmailJobStatusFailed   = "failed"
+	EmailJobStatusDead     = "dead"
 	EmailJobStatusBounced  = "bounced"
 	EmailJobStatusRejected = "rejected"
 	EmailJobStatusOpened   = "opened"
 	EmailJobStatusClicked  = "clicked"
+	EmailJobStatusSkipped  = "skipped"
 )
 
+// Jobs are claimed in order of priority, highest first. Transactional mail
+// is queued above bulk mail so that it never waits behind a campaign.
+const (
+	EmailJobPriorityBulk          = 0
+	EmailJobPriorityTransactional = 10
+)
+
+// EmailJobQueuedChannel is the Postgres notification channel signalled
+// whenever a job becomes due in the queue.
+const EmailJobQueuedChannel = "email_jobs_queued"
+
+// EmailJob is one message to send. A worker claims a queued job by setting
+// it sending with a lease, LockedBy and LockedUntil, which it renews while
+// the job is in hand; jobs whose lease runs out are returned to the queue.
+// A job that failed and will be retried waits in the queue until
+// NextAttemptAt. Jobs that failed permanently or ran out of retries are
+// dead: they stay in the dead-letter queue until requeued or discarded.
+// Transactional jobs have no campaign or broadcast; their message is kept in
+// Transactional.
 type EmailJob struct {
-	ID            uint           `gorm:"primarykey" json:"id"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
-	CampaignID    uint           `json:"campaign_id"`
-	Campaign      Campaign       `gorm:"foreignkey:CampaignID" json:"campaign"`
-	SubscriberID  uint           `json:"subscriber_id"`
-	Subscriber    Subscriber     `gorm:"foreignkey:SubscriberID" json:"subscriber"`
-	Status        string         `gorm:"size:50;default:queued" json:"status"`
-	StatusMessage string         `gorm:"size:255" json:"status_message"`
-	Attempts      int            `gorm:"default:0" json:"attempts"`
-	SentAt        *time.Time     `json:"sent_at"`
-	OpenedAt      *time.Time     `json:"opened_at"`
-	ClickedAt     *time.Time     `json:"clicked_at"`
-	MessageID     string         `gorm:"size:255" json:"message_id"`
+	ID            uint                  `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time             `gorm:"index:idx_email_jobs_claim,priority:3" json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt        `gorm:"index" json:"-"`
+	CampaignID    *uint                 `json:"campaign_id"`
+	Campaign      Campaign              `gorm:"foreignkey:CampaignID" json:"campaign"`
+	BroadcastID   *uint                 `gorm:"index" json:"broadcast_id"`
+	Broadcast     *Broadcast            `gorm:"foreignkey:BroadcastID" json:"broadcast,omitempty"`
+	ContactID     *uint                 `gorm:"index" json:"contact_id"`
+	Contact       *Contact              `gorm:"foreignkey:ContactID" json:"contact,omitempty"`
+	Status        string                `gorm:"size:50;default:queued;index:idx_email_jobs_claim,priority:1" json:"status"`
+	Priority      int                   `gorm:"not null;default:0;index:idx_email_jobs_claim,priority:2,sort:desc" json:"priority"`
+	StatusMessage string                `gorm:"size:255" json:"status_message"`
+	Attempts      int                   `gorm:"default:0" json:"attempts"`
+	SentAt        *time.Time            `json:"sent_at"`
+	OpenedAt      *time.Time            `json:"opened_at"`
+	ClickedAt     *time.Time            `json:"clicked_at"`
+	MessageID     string                `gorm:"size:255;index" json:"message_id"`
+	LockedBy      string                `gorm:"size:255" json:"locked_by,omitempty"`
+	LockedUntil   *time.Time            `gorm:"index" json:"locked_until,omitempty"`
+	NextAttemptAt *time.Time            `gorm:"index" json:"next_attempt_at,omitempty"`
+	Transactional *TransactionalMessage `gorm:"foreignkey:EmailJobID" json:"transactional,omitempty"`
+}
+
+// TransactionalMessage is a transactional email waiting in the queue. It is
+// rendered when queued, so template errors are reported to the caller;
+// attachments are loaded again when it is sent.
+type TransactionalMessage struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	EmailJobID  uint      `gorm:"uniqueIndex" json:"email_job_id"`
+	ToEmail     string    `gorm:"size:255" json:"to_email"`
+	ToName      string    `gorm:"size:255" json:"to_name"`
+	Subject     string    `gorm:"type:text" json:"subject"`
+	Template    string    `gorm:"size:255" json:"template"`
+	HTML        string    `gorm:"type:text" json:"-"`
+	Text        string    `gorm:"type:text" json:"-"`
+	Attachments JSONValue `gorm:"type:jsonb" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeadJobFilter selects jobs in the dead-letter queue: those with the given
+// IDs, or with All every one, limited to a campaign or broadcast when one
+// is given.
+type DeadJobFilter struct {
+	IDs         []uint `json:"ids"`
+	All         bool   `json:"all"`
+	CampaignID  *uint  `json:"campaign_id"`
+	BroadcastID *uint  `json:"broadcast_id"`
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONMap stores free-form key/value data in a jsonb column.
+type JSONMap map[string]interface{}
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *JSONMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = JSONMap{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", value)
+	}
+
+	result := JSONMap{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return err
+		}
+	}
+	*m = result
+	return nil
+}
+
+// JSONValue stores a single JSON value of any type in a jsonb column. A nil
+// Data is stored as SQL NULL.
+type JSONValue struct {
+	Data interface{}
+}
+
+func (v JSONValue) Value() (driver.Value, error) {
+	if v.Data == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v.Data)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (v *JSONValue) Scan(value interface{}) error {
+	var data []byte
+	switch raw := value.(type) {
+	case nil:
+		v.Data = nil
+		return nil
+	case []byte:
+		data = raw
+	case string:
+		data = []byte(raw)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONValue", value)
+	}
+	return json.Unmarshal(data, &v.Data)
+}
+
+func (v JSONValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Data)
+}
+
+func (v *JSONValue) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &v.Data)
+}
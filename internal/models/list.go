@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ListOptInSingle = "single"
+	ListOptInDouble = "double"
+)
+
+type List struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	Name         string         `gorm:"size:255" json:"name"`
+	Description  string         `gorm:"type:text" json:"description"`
+	Public       bool           `gorm:"default:false" json:"public"`
+	OptIn        string         `gorm:"size:20;default:single" json:"opt_in"`
+	ContactCount int64          `gorm:"->;-:migration" json:"contact_count"`
+	Contacts     []Contact      `gorm:"many2many:list_contacts;" json:"contacts,omitempty"`
+}
+
+func ListIDs(lists []List) []uint {
+	ids := make([]uint, len(lists))
+	for i, list := range lists {
+		ids[i] = list.ID
+	}
+	return ids
+}
+
+// ListMembership is a list as seen from the preference center. Status is
+// empty when the contact is not a member.
+type ListMembership struct {
+	ID          uint
+	Name        string
+	Description string
+	Public      bool
+	Status      string
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	PreferenceActionUnsubscribed     = "unsubscribed"
+	PreferenceActionResubscribed     = "resubscribed"
+	PreferenceActionListSubscribed   = "list_subscribed"
+	PreferenceActionListUnsubscribed = "list_unsubscribed"
+	PreferenceActionProfileUpdated   = "profile_updated"
+	PreferenceActionPaused           = "paused"
+	PreferenceActionResumed          = "resumed"
+	PreferenceActionSignup           = "signup"
+	PreferenceActionConfirmed        = "confirmed"
+)
+
+const (
+	PreferenceSourceUnsubscribeLink  = "unsubscribe_link"
+	PreferenceSourcePreferenceCenter = "preference_center"
+	PreferenceSourceSignupForm       = "signup_form"
+	PreferenceSourceConfirmLink      = "confirm_link"
+	PreferenceSourceComplaint        = "complaint"
+	PreferenceSourceWebhook          = "webhook"
+)
+
+// PreferenceAudit records a change a contact made to their own subscription
+// through a public link, or an unsubscribe caused by a spam complaint or
+// reported by a provider webhook.
+type PreferenceAudit struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ContactID uint      `gorm:"index" json:"contact_id"`
+	Action    string    `gorm:"size:50" json:"action"`
+	Source    string    `gorm:"size:50" json:"source"`
+	Changes   JSONMap   `gorm:"type:jsonb;default:'{}'" json:"changes"`
+	IPAddress string    `gorm:"size:64" json:"ip_address"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
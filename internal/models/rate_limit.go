@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	RateLimitScopeGlobal = "global"
+	RateLimitScopeDomain = "domain"
+	RateLimitScopeSender = "sender"
+)
+
+// RateLimit is a token bucket shared by every worker in every replica. Key
+// is empty for the global limit, the recipient domain for a domain limit and
+// the from address for a sender limit, in lower case. The bucket refills at
+// Rate tokens a minute up to Burst; Tokens is its level at RefilledAt.
+type RateLimit struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Scope      string    `gorm:"size:20;not null;uniqueIndex:idx_rate_limits_scope_key" json:"scope"`
+	Key        string    `gorm:"size:255;not null;uniqueIndex:idx_rate_limits_scope_key" json:"key"`
+	Rate       int       `gorm:"not null" json:"rate"`
+	Burst      int       `gorm:"not null" json:"burst"`
+	Tokens     float64   `gorm:"not null" json:"tokens"`
+	RefilledAt time.Time `json:"refilled_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
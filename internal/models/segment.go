@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Segment is a saved contact query, written in the language of
+// internal/segment and evaluated whenever it is used.
+type Segment struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	Name        string         `gorm:"size:255" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Query       string         `gorm:"type:text" json:"query"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+const (
+	SuppressionTypeEmail  = "email"
+	SuppressionTypeDomain = "domain"
+)
+
+const (
+	SuppressionReasonHardBounce = "hard_bounce"
+	SuppressionReasonComplaint  = "complaint"
+	SuppressionReasonManual     = "manual"
+)
+
+const (
+	SuppressionSourceAPI       = "api"
+	SuppressionSourceImport    = "import"
+	SuppressionSourceBounce    = "bounce"
+	SuppressionSourceComplaint = "complaint"
+)
+
+// Suppression blocks all mail to an address, or to every address at a
+// domain. Value is stored in lower case.
+type Suppression struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Type      string    `gorm:"size:20;uniqueIndex:idx_suppressions_type_value" json:"type"`
+	Value     string    `gorm:"size:255;uniqueIndex:idx_suppressions_type_value" json:"value"`
+	Reason    string    `gorm:"size:50" json:"reason"`
+	Source    string    `gorm:"size:50" json:"source"`
+	Details   string    `gorm:"size:1000" json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	BounceTypeHard      = "hard"
+	BounceTypeSoft      = "soft"
+	BounceTypeComplaint = "complaint"
+)
+
+const (
+	BounceSourceAPI     = "api"
+	BounceSourceMailbox = "mailbox"
+)
+
+// Bounce records a bounce or spam complaint reported for a message.
+type Bounce struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	EmailJobID *uint     `gorm:"index" json:"email_job_id"`
+	ContactID  *uint     `gorm:"index" json:"contact_id"`
+	Email      string    `gorm:"size:255;index" json:"email"`
+	Type       string    `gorm:"size:20" json:"type"`
+	Source     string    `gorm:"size:50" json:"source"`
+	Status     string    `gorm:"size:20" json:"status"`
+	Diagnostic string    `gorm:"size:1000" json:"diagnostic"`
+	MessageID  string    `gorm:"size:255" json:"message_id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
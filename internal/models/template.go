@@ -13,15 +13,29 @@ type Template struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 	Name      string         `gorm:"uniqueIndex;size:255" json:"name"`
 	Content   string         `gorm:"type:text" json:"content"`
+	Text      string         `gorm:"type:text" json:"text"`
 	Type      string         `gorm:"size:50;default:html" json:"type"`
 }
 
 type EmailLog struct {
-	ID       uint      `gorm:"primarykey" json:"id"`
-	Email    string    `gorm:"size:255;index" json:"email"`
-	Subject  string    `gorm:"size:255" json:"subject"`
-	Template string    `gorm:"size:255" json:"template"`
-	Type     string    `gorm:"size:50" json:"type"`
-	SentAt   time.Time `json:"sent_at"`
-	Status   string    `gorm:"size:50" json:"status"`
+	ID          uint                 `gorm:"primarykey" json:"id"`
+	Email       string               `gorm:"size:255;index" json:"email"`
+	Subject     string               `gorm:"size:255" json:"subject"`
+	Template    string               `gorm:"size:255" json:"template"`
+	Type        string               `gorm:"size:50" json:"type"`
+	SentAt      time.Time            `json:"sent_at"`
+	Status      string               `gorm:"size:50" json:"status"`
+	Attachments []EmailLogAttachment `gorm:"foreignKey:EmailLogID" json:"attachments,omitempty"`
+}
+
+type EmailLogAttachment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	EmailLogID  uint      `gorm:"index" json:"email_log_id"`
+	Filename    string    `gorm:"size:255" json:"filename"`
+	ContentType string    `gorm:"size:255" json:"content_type"`
+	ContentID   string    `gorm:"size:255" json:"content_id,omitempty"`
+	Size        int64     `json:"size"`
+	Inline      bool      `json:"inline"`
+	Source      string    `gorm:"size:50" json:"source"`
+	CreatedAt   time.Time `json:"created_at"`
 }
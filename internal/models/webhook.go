@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	WebhookEventStatusUnmatched = "unmatched"
+	WebhookEventStatusUnknown   = "unknown"
+	WebhookEventStatusFailed    = "failed"
+)
+
+// WebhookEvent keeps a provider webhook event that could not be applied:
+// one of a type we do not handle, one whose message we cannot find, or one
+// that failed to process. Payload is the event as the provider sent it.
+type WebhookEvent struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	Provider     string    `gorm:"size:50;index" json:"provider"`
+	EventType    string    `gorm:"size:50" json:"event_type"`
+	ProviderType string    `gorm:"size:100" json:"provider_type"`
+	MessageID    string    `gorm:"size:255" json:"message_id"`
+	Email        string    `gorm:"size:255" json:"email"`
+	EmailJobID   *uint     `gorm:"index" json:"email_job_id"`
+	Status       string    `gorm:"size:20;index" json:"status"`
+	Error        string    `gorm:"size:1000" json:"error"`
+	Payload      JSONMap   `gorm:"type:jsonb;default:'{}'" json:"payload"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type AttributeRepository struct {
+	db *gorm.DB
+}
+
+func NewAttributeRepository(db *gorm.DB) *AttributeRepository {
+	return &AttributeRepository{
+		db: db,
+	}
+}
+
+func (r *AttributeRepository) CreateDefinition(definition *models.AttributeDefinition) (models.AttributeDefinition, error) {
+	err := r.db.Create(definition).Error
+	return *definition, err
+}
+
+func (r *AttributeRepository) GetAllDefinitions() ([]models.AttributeDefinition, error) {
+	var definitions []models.AttributeDefinition
+	err := r.db.Order("key").Find(&definitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *AttributeRepository) GetDefinitionByID(id uint) (*models.AttributeDefinition, error) {
+	var definition models.AttributeDefinition
+	err := r.db.Find(&definition, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+func (r *AttributeRepository) GetDefinitionByKey(key string) (*models.AttributeDefinition, error) {
+	var definition models.AttributeDefinition
+	err := r.db.Where("key = ?", key).Find(&definition).Error
+	if err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+func (r *AttributeRepository) UpdateDefinition(definition *models.AttributeDefinition) (models.AttributeDefinition, error) {
+	err := r.db.Model(definition).
+		Select("type", "required", "default_value", "description", "updated_at").
+		Updates(definition).Error
+	return *definition, err
+}
+
+func (r *AttributeRepository) DeleteDefinition(id uint) error {
+	return r.db.Delete(&models.AttributeDefinition{}, id).Error
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type BounceRepository struct {
+	db *gorm.DB
+}
+
+func NewBounceRepository(db *gorm.DB) *BounceRepository {
+	return &BounceRepository{
+		db: db,
+	}
+}
+
+func (r *BounceRepository) CreateBounce(bounce *models.Bounce) error {
+	return r.db.Create(bounce).Error
+}
+
+// GetBounces pages through bounces, newest first, optionally of one type
+// and for one address.
+func (r *BounceRepository) GetBounces(bounceType, email string, offset, limit int) ([]models.Bounce, int64, error) {
+	query := r.db.Model(&models.Bounce{})
+	if bounceType != "" {
+		query = query.Where("type = ?", bounceType)
+	}
+	if email != "" {
+		query = query.Where("LOWER(email) = ?", strings.ToLower(email))
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var bounces []models.Bounce
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&bounces).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return bounces, total, nil
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
 	"gorm.io/gorm"
 )
@@ -22,29 +24,81 @@ func (r *BroadcastRepository) CreateBroadcast(broadcast *models.Broadcast) (mode
 
 func (r *BroadcastRepository) GetBroadcastByID(id uint) (*models.Broadcast, error) {
 	var broadcast models.Broadcast
-	err := r.db.Find(&broadcast, id).Error
+	err := r.db.Preload("Lists").Find(&broadcast, id).Error
 	if err != nil {
 		return nil, err
 	}
+	broadcast.ListIDs = models.ListIDs(broadcast.Lists)
 	return &broadcast, nil
 }
 
-func (r *BroadcastRepository) UpdateBroadcast(broadcast *models.Broadcast) (*models.Broadcast, error) {
-	err := r.db.Save(broadcast).Error
-	return broadcast, err
+// UpdateBroadcast saves the content, audience and schedule of a broadcast
+// that has not started sending, and reports whether it had not. Clearing the
+// schedule only applies to a draft, since a scheduled broadcast without a
+// time would never be picked up. Its status is left to UpdateStatusFrom and
+// UpdateStatus.
+func (r *BroadcastRepository) UpdateBroadcast(broadcast *models.Broadcast) (bool, error) {
+	statuses := []string{models.BroadcastStatusDraft, models.BroadcastStatusScheduled}
+	if broadcast.ScheduledAt == nil {
+		statuses = statuses[:1]
+	}
+	result := r.db.Model(broadcast).
+		Where("status IN ?", statuses).
+		Select("name", "audience_id", "segment_id", "campaign_id", "user_id", "from", "subject",
+			"reply_to", "html", "text", "scheduled_at", "updated_at").
+		Updates(broadcast)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *BroadcastRepository) ReplaceLists(broadcast *models.Broadcast, lists []models.List) error {
+	err := r.db.Model(broadcast).Association("Lists").Replace(lists)
+	if err != nil {
+		return err
+	}
+	broadcast.Lists = lists
+	broadcast.ListIDs = models.ListIDs(lists)
+	return nil
+}
+
+// UpdateStatusFrom updates a broadcast whose status is one of from, and
+// reports whether it was.
+func (r *BroadcastRepository) UpdateStatusFrom(id uint, from []string, fields map[string]interface{}) (bool, error) {
+	fields["updated_at"] = time.Now()
+	result := r.db.Model(&models.Broadcast{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(fields)
+	return result.RowsAffected == 1, result.Error
 }
 
-func (r *BroadcastRepository) SendBroadcast(broadcast_id, scheduled_at string) (id string, error error) {
-	err := r.db.Table("broadcasts").Where("id = ?", broadcast_id).Update("scheduled_at", scheduled_at).Error
-	return broadcast_id, err
+func (r *BroadcastRepository) UpdateStatus(id uint, fields map[string]interface{}) error {
+	fields["updated_at"] = time.Now()
+	return r.db.Model(&models.Broadcast{}).Where("id = ?", id).Updates(fields).Error
+}
+
+func (r *BroadcastRepository) GetDueBroadcasts() ([]models.Broadcast, error) {
+	var broadcasts []models.Broadcast
+	err := r.db.Preload("Lists").Where("status = ? AND scheduled_at <= ?",
+		models.BroadcastStatusScheduled,
+		time.Now()).
+		Find(&broadcasts).Error
+	return broadcasts, err
+}
+
+func (r *BroadcastRepository) GetBroadcastsByStatus(status string) ([]models.Broadcast, error) {
+	var broadcasts []models.Broadcast
+	err := r.db.Where("status = ?", status).Find(&broadcasts).Error
+	return broadcasts, err
 }
 
 func (r *BroadcastRepository) ListBroadcasts() ([]models.Broadcast, error) {
 	var broadcasts []models.Broadcast
-	err := r.db.Find(&broadcasts).Error
+	err := r.db.Preload("Lists").Find(&broadcasts).Error
 	if err != nil {
 		return nil, err
 	}
+	for i := range broadcasts {
+		broadcasts[i].ListIDs = models.ListIDs(broadcasts[i].Lists)
+	}
 	return broadcasts, nil
 }
 
@@ -24,19 +24,23 @@ func (r *CampaignRepository) Create(campaign *models.Campaign) (models.Campaign,
 
 func (r *CampaignRepository) GetAllCampaigns() ([]models.Campaign, error) {
 	var campaigns []models.Campaign
-	err := r.db.Find(&campaigns).Error
+	err := r.db.Preload("Lists").Find(&campaigns).Error
 	if err != nil {
 		return nil, err
 	}
+	for i := range campaigns {
+		campaigns[i].ListIDs = models.ListIDs(campaigns[i].Lists)
+	}
 	return campaigns, nil
 }
 
 func (r *CampaignRepository) GetCampaignByID(id uint) (*models.Campaign, error) {
 	var campaign models.Campaign
-	err := r.db.Find(&campaign, id).Error
+	err := r.db.Preload("Lists").Find(&campaign, id).Error
 	if err != nil {
 		return nil, err
 	}
+	campaign.ListIDs = models.ListIDs(campaign.Lists)
 	return &campaign, nil
 }
 
@@ -47,9 +51,20 @@ func (r *CampaignRepository) DeleteCampaign(id uint) error {
 func (r *CampaignRepository) GetScheduledCampaigns() ([]models.Campaign, error) {
 	var campaigns []models.Campaign
 	now := time.Now()
-	err := r.db.Where("status = ? AND scheduled_at <= ?",
+	err := r.db.Preload("Lists").Where("status = ? AND scheduled_at <= ?",
 		models.CampaignStatusScheduled,
 		now).
 		Find(&campaigns).Error
 	return campaigns, err
 }
+
+func (r *CampaignRepository) AddContacts(campaignID uint, contactIDs []uint) error {
+	if len(contactIDs) == 0 {
+		return nil
+	}
+	return r.db.Exec(`
+		INSERT INTO campaign_audiences (campaign_id, contact_id)
+		SELECT ?, id FROM contacts WHERE id IN ?
+		ON CONFLICT DO NOTHING`,
+		campaignID, contactIDs).Error
+}
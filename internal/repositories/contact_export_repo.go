@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type ContactExportRepository struct {
+	db *gorm.DB
+}
+
+func NewContactExportRepository(db *gorm.DB) *ContactExportRepository {
+	return &ContactExportRepository{
+		db: db,
+	}
+}
+
+func (r *ContactExportRepository) CreateExport(contactExport *models.ContactExport) error {
+	return r.db.Create(contactExport).Error
+}
+
+func (r *ContactExportRepository) GetExportByID(id uint) (*models.ContactExport, error) {
+	var contactExport models.ContactExport
+	err := r.db.Find(&contactExport, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contactExport, nil
+}
+
+func (r *ContactExportRepository) ListExports() ([]models.ContactExport, error) {
+	var exports []models.ContactExport
+	err := r.db.Order("id DESC").Find(&exports).Error
+	return exports, err
+}
+
+func (r *ContactExportRepository) GetExportsByStatus(statuses []string) ([]models.ContactExport, error) {
+	var exports []models.ContactExport
+	err := r.db.Where("status IN ?", statuses).Order("id").Find(&exports).Error
+	return exports, err
+}
+
+func (r *ContactExportRepository) GetExpiredExports() ([]models.ContactExport, error) {
+	var exports []models.ContactExport
+	err := r.db.Where("expires_at <= ? AND file_path <> ''", time.Now()).Find(&exports).Error
+	return exports, err
+}
+
+func (r *ContactExportRepository) UpdateExport(id uint, fields map[string]interface{}) error {
+	fields["updated_at"] = time.Now()
+	return r.db.Model(&models.ContactExport{}).Where("id = ?", id).Updates(fields).Error
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type ContactImportRepository struct {
+	db *gorm.DB
+}
+
+func NewContactImportRepository(db *gorm.DB) *ContactImportRepository {
+	return &ContactImportRepository{
+		db: db,
+	}
+}
+
+func (r *ContactImportRepository) CreateImport(contactImport *models.ContactImport) error {
+	return r.db.Create(contactImport).Error
+}
+
+func (r *ContactImportRepository) GetImportByID(id uint) (*models.ContactImport, error) {
+	var contactImport models.ContactImport
+	err := r.db.Find(&contactImport, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contactImport, nil
+}
+
+func (r *ContactImportRepository) ListImports() ([]models.ContactImport, error) {
+	var imports []models.ContactImport
+	err := r.db.Order("id DESC").Find(&imports).Error
+	return imports, err
+}
+
+func (r *ContactImportRepository) GetImportsByStatus(statuses []string) ([]models.ContactImport, error) {
+	var imports []models.ContactImport
+	err := r.db.Where("status IN ?", statuses).Order("id").Find(&imports).Error
+	return imports, err
+}
+
+func (r *ContactImportRepository) UpdateImport(id uint, fields map[string]interface{}) error {
+	fields["updated_at"] = time.Now()
+	return r.db.Model(&models.ContactImport{}).Where("id = ?", id).Updates(fields).Error
+}
+
+func (r *ContactImportRepository) AddErrors(errors []models.ContactImportError) error {
+	if len(errors) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(&errors, 500).Error
+}
+
+func (r *ContactImportRepository) DeleteErrors(importID uint) error {
+	return r.db.Where("import_id = ?", importID).Delete(&models.ContactImportError{}).Error
+}
+
+func (r *ContactImportRepository) GetErrors(importID uint, offset, limit int) ([]models.ContactImportError, int64, error) {
+	query := r.db.Model(&models.ContactImportError{}).
+		Where("import_id = ?", importID).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var errors []models.ContactImportError
+	err := query.Order("row").Offset(offset).Limit(limit).Find(&errors).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return errors, total, nil
+}
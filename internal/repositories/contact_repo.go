@@ -1,8 +1,13 @@
 package repositories
 
 import (
+	"strings"
+	"time"
+
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/segment"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ContactRepository struct {
@@ -20,6 +25,52 @@ func (r *ContactRepository) CreateContact(contact *models.Contact) (models.Conta
 	return *contact, err
 }
 
+// GetExistingEmails reports which of the given addresses already belong to a
+// contact, including soft-deleted ones that an upsert would restore.
+func (r *ContactRepository) GetExistingEmails(emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool, len(emails))
+	if len(emails) == 0 {
+		return existing, nil
+	}
+
+	var found []string
+	err := r.db.Unscoped().Model(&models.Contact{}).
+		Where("email IN ?", emails).
+		Pluck("email", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range found {
+		existing[email] = true
+	}
+	return existing, nil
+}
+
+// UpsertContacts inserts contacts or updates the existing ones matched by
+// email. Empty names keep the stored value, attributes are merged and the
+// status is only overwritten when updateStatus is set. IDs are filled in.
+func (r *ContactRepository) UpsertContacts(contacts []models.Contact, updateStatus bool) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"first_name": gorm.Expr("COALESCE(NULLIF(EXCLUDED.first_name, ''), contacts.first_name)"),
+		"last_name":  gorm.Expr("COALESCE(NULLIF(EXCLUDED.last_name, ''), contacts.last_name)"),
+		"attributes": gorm.Expr("COALESCE(contacts.attributes, '{}'::jsonb) || EXCLUDED.attributes"),
+		"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+		"deleted_at": nil,
+	}
+	if updateStatus {
+		updates["status"] = gorm.Expr("EXCLUDED.status")
+	}
+
+	return r.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(&contacts).Error
+}
+
 func (r *ContactRepository) GetAllContacts(campaignID uint) ([]models.Contact, error) {
 	var contacts []models.Contact
 	result := r.db.Joins("JOIN campaign_audiences ON campaign_audiences.contact_id = contacts.id").
@@ -33,6 +84,134 @@ func (r *ContactRepository) GetAllContacts(campaignID uint) ([]models.Contact, e
 	return contacts, nil
 }
 
+// mailableContacts limits a query to contacts that have not unsubscribed,
+// been blocklisted or paused their mail.
+func mailableContacts(db *gorm.DB) *gorm.DB {
+	return db.Where("contacts.un_subscribe = ? AND contacts.status = ?", false, models.ContactStatusEnabled).
+		Where("(contacts.paused_until IS NULL OR contacts.paused_until <= ?)", time.Now())
+}
+
+// GetAudienceContacts resolves an audience made of a campaign's contacts,
+// the members of lists and/or the contacts matching a segment into the
+// distinct contacts that can be mailed.
+func (r *ContactRepository) GetAudienceContacts(campaignID uint, listIDs []uint, seg *segment.Query) ([]models.Contact, error) {
+	var contacts []models.Contact
+
+	var conditions []string
+	var args []interface{}
+	if campaignID != 0 {
+		conditions = append(conditions, "contacts.id IN (?)")
+		args = append(args, r.db.Table("campaign_audiences").
+			Select("contact_id").
+			Where("campaign_id = ?", campaignID))
+	}
+	if len(listIDs) > 0 {
+		conditions = append(conditions, "contacts.id IN (?)")
+		args = append(args, r.db.Table("list_contacts").
+			Select("contact_id").
+			Where("list_id IN ? AND status = ?", listIDs, models.ListContactStatusSubscribed))
+	}
+	if seg != nil {
+		conditions = append(conditions, seg.SQL)
+		args = append(args, seg.Args...)
+	}
+	if len(conditions) == 0 {
+		return contacts, nil
+	}
+
+	result := r.db.Scopes(mailableContacts).
+		Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Order("contacts.id").
+		Find(&contacts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return contacts, nil
+}
+
+// PreviewSegment counts the contacts matching a segment, in total and those
+// that can be mailed, and returns up to limit of them.
+func (r *ContactRepository) PreviewSegment(seg *segment.Query, limit int) ([]models.Contact, int64, int64, error) {
+	query := r.db.Model(&models.Contact{}).Where(seg.SQL, seg.Args...).Session(&gorm.Session{})
+
+	var matched, subscribed int64
+	if err := query.Count(&matched).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	err := query.Scopes(mailableContacts).Count(&subscribed).Error
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var contacts []models.Contact
+	if err = query.Order("contacts.id").Limit(limit).Find(&contacts).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	return contacts, matched, subscribed, nil
+}
+
+func (r *ContactRepository) filterContacts(filter models.ContactFilter) *gorm.DB {
+	query := r.db.Model(&models.Contact{})
+	if filter.ListID != nil {
+		query = query.Where("contacts.id IN (?)", r.db.Table("list_contacts").
+			Select("contact_id").
+			Where("list_id = ?", *filter.ListID))
+	}
+	if filter.CampaignID != nil {
+		query = query.Where("contacts.id IN (?)", r.db.Table("campaign_audiences").
+			Select("contact_id").
+			Where("campaign_id = ?", *filter.CampaignID))
+	}
+	if filter.Status != "" {
+		query = query.Where("contacts.status = ?", filter.Status)
+	}
+	if filter.Unsubscribed != nil {
+		query = query.Where("contacts.un_subscribe = ?", *filter.Unsubscribed)
+	}
+	if filter.Email != "" {
+		query = query.Where("contacts.email ILIKE ?", "%"+escapeLike(filter.Email)+"%")
+	}
+	return query
+}
+
+// StreamContacts walks the contacts matching filter in ID order, batchSize
+// at a time with their lists loaded, so callers never hold the full set.
+func (r *ContactRepository) StreamContacts(filter models.ContactFilter, batchSize int, fn func([]models.Contact) error) error {
+	var lastID uint
+	for {
+		var contacts []models.Contact
+		err := r.filterContacts(filter).
+			Preload("Lists").
+			Where("contacts.id > ?", lastID).
+			Order("contacts.id").
+			Limit(batchSize).
+			Find(&contacts).Error
+		if err != nil {
+			return err
+		}
+		if len(contacts) == 0 {
+			return nil
+		}
+
+		if err = fn(contacts); err != nil {
+			return err
+		}
+		if len(contacts) < batchSize {
+			return nil
+		}
+		lastID = contacts[len(contacts)-1].ID
+	}
+}
+
+func (r *ContactRepository) GetAttributeKeys(filter models.ContactFilter) ([]string, error) {
+	var keys []string
+	err := r.filterContacts(filter).
+		Distinct("jsonb_object_keys(contacts.attributes)").
+		Pluck("jsonb_object_keys(contacts.attributes)", &keys).Error
+	return keys, err
+}
+
 func (r *ContactRepository) GetContactByID(id uint) (*models.Contact, error) {
 	var contact models.Contact
 	err := r.db.Find(&contact, id).Error
@@ -42,6 +221,88 @@ func (r *ContactRepository) GetContactByID(id uint) (*models.Contact, error) {
 	return &contact, nil
 }
 
+func (r *ContactRepository) GetContactByEmail(email string) (*models.Contact, error) {
+	var contact models.Contact
+	err := r.db.Where("LOWER(email) = LOWER(?)", email).Limit(1).Find(&contact).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// Unsubscribe sets the unsubscribe flag without touching the rest of the
+// contact, so it cannot race with an edit made through the API.
+func (r *ContactRepository) Unsubscribe(id uint) error {
+	return r.db.Model(&models.Contact{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"un_subscribe": true,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// ConfirmContact enables an unconfirmed contact. Confirming is a fresh
+// subscription, so it also clears an earlier unsubscribe.
+func (r *ContactRepository) ConfirmContact(id uint) error {
+	return r.db.Model(&models.Contact{}).
+		Where("id = ? AND status IN ?", id, []string{models.ContactStatusEnabled, models.ContactStatusUnconfirmed}).
+		Updates(map[string]interface{}{
+			"status":       models.ContactStatusEnabled,
+			"un_subscribe": false,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// RequestConfirmation records when a confirmation link was sent to the
+// contact, restarting the expiry of an unconfirmed contact.
+func (r *ContactRepository) RequestConfirmation(id uint, at time.Time) error {
+	return r.db.Model(&models.Contact{}).
+		Where("id = ?", id).
+		UpdateColumn("confirm_requested_at", at).Error
+}
+
+// DeleteUnconfirmed permanently removes contacts that were last sent a
+// confirmation link before the given time and never confirmed, with their
+// memberships and signup audit, so the address can sign up again. The
+// confirmation emails sent to them are kept without their contact. Every
+// step includes soft-deleted rows, so that they cannot block the delete.
+func (r *ContactRepository) DeleteUnconfirmed(before time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		expired := func() *gorm.DB {
+			return tx.Model(&models.Contact{}).
+				Select("id").
+				Where("status = ? AND COALESCE(confirm_requested_at, created_at) < ?", models.ContactStatusUnconfirmed, before)
+		}
+		if err := tx.Where("contact_id IN (?)", expired()).Delete(&models.ListContact{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("contact_id IN (?)", expired()).Delete(&models.PreferenceAudit{}).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.EmailJob{}).
+			Where("contact_id IN (?)", expired()).
+			Update("contact_id", nil).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id IN (?)", expired()).Delete(&models.Contact{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// UpdatePreferences saves the fields a contact can change from the
+// preference center.
+func (r *ContactRepository) UpdatePreferences(contact *models.Contact) error {
+	contact.UpdatedAt = time.Now()
+	return r.db.Model(contact).
+		Select("first_name", "last_name", "attributes", "un_subscribe", "paused_until", "updated_at").
+		Updates(contact).Error
+}
+
 func (r *ContactRepository) UpdateContact(contact *models.Contact) (models.Contact, error) {
 	err := r.db.Save(contact).Error
 	return *contact, err
@@ -50,3 +311,7 @@ func (r *ContactRepository) UpdateContact(contact *models.Contact) (models.Conta
 func (r *ContactRepository) DeleteContact(id uint) error {
 	return r.db.Delete(&models.Contact{}, id).Error
 }
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_URL and returns a
+// transaction that is rolled back when the test ends.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	if err = db.SetupJoinTable(&models.Contact{}, "Lists", &models.ListContact{}); err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Campaign{},
+		&models.Contact{},
+		&models.Broadcast{},
+		&models.EmailJob{},
+		&models.TransactionalMessage{},
+		&models.List{},
+		&models.ListContact{},
+		&models.PreferenceAudit{},
+	)
+	if err != nil {
+		t.Fatalf("migrating the test database: %v", err)
+	}
+
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// createUnconfirmed creates an unconfirmed contact that was sent a
+// confirmation link at requestedAt, with a membership, a signup audit and
+// the confirmation email.
+func createUnconfirmed(t *testing.T, db *gorm.DB, address string, requestedAt time.Time) (models.Contact, models.EmailJob) {
+	t.Helper()
+	list := models.List{Name: "Newsletter " + address, Public: true, OptIn: models.ListOptInDouble}
+	if err := db.Create(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	contact := models.Contact{Email: address, Status: models.ContactStatusUnconfirmed, ConfirmRequestedAt: &requestedAt}
+	if err := db.Omit(clause.Associations).Create(&contact).Error; err != nil {
+		t.Fatal(err)
+	}
+	membership := models.ListContact{ListID: list.ID, ContactID: contact.ID, Status: models.ListContactStatusUnconfirmed}
+	if err := db.Create(&membership).Error; err != nil {
+		t.Fatal(err)
+	}
+	audit := models.PreferenceAudit{ContactID: contact.ID, Action: "signup", Source: "form"}
+	if err := db.Create(&audit).Error; err != nil {
+		t.Fatal(err)
+	}
+	job := models.EmailJob{ContactID: &contact.ID, Status: models.EmailJobStatusSent}
+	if err := db.Omit(clause.Associations).Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return contact, job
+}
+
+func TestDeleteUnconfirmed(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewContactRepository(db)
+
+	expiredAt := time.Now().Add(-72 * time.Hour)
+	expired, expiredJob := createUnconfirmed(t, db, "expired-signup@example.com", expiredAt)
+	// Edits do not restart the expiry.
+	if err := db.Model(&expired).UpdateColumn("updated_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	// A soft-deleted contact still holds its rows, and must not block the
+	// delete.
+	deleted, deletedJob := createUnconfirmed(t, db, "deleted-signup@example.com", expiredAt)
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := createUnconfirmed(t, db, "pending-signup@example.com", time.Now())
+
+	count, err := repo.DeleteUnconfirmed(time.Now().Add(-48 * time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteUnconfirmed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("deleted %d contacts, want 2", count)
+	}
+
+	for _, contact := range []models.Contact{expired, deleted} {
+		var contacts, memberships, audits int64
+		db.Unscoped().Model(&models.Contact{}).Where("id = ?", contact.ID).Count(&contacts)
+		db.Model(&models.ListContact{}).Where("contact_id = ?", contact.ID).Count(&memberships)
+		db.Model(&models.PreferenceAudit{}).Where("contact_id = ?", contact.ID).Count(&audits)
+		if contacts != 0 || memberships != 0 || audits != 0 {
+			t.Errorf("%s: %d contacts, %d memberships and %d audits left, want none", contact.Email, contacts, memberships, audits)
+		}
+	}
+	for _, job := range []models.EmailJob{expiredJob, deletedJob} {
+		var kept models.EmailJob
+		if err = db.First(&kept, job.ID).Error; err != nil {
+			t.Fatalf("confirmation email %d was deleted: %v", job.ID, err)
+		}
+		if kept.ContactID != nil {
+			t.Errorf("confirmation email %d still refers to contact %d", job.ID, *kept.ContactID)
+		}
+	}
+
+	var kept int64
+	db.Model(&models.Contact{}).Where("id = ?", pending.ID).Count(&kept)
+	if kept != 1 {
+		t.Error("a contact whose link is still valid was deleted")
+	}
+}
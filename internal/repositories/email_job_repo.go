@@ -0,0 +1,355 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailJobRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailJobRepository(db *gorm.DB) *EmailJobRepository {
+	return &EmailJobRepository{
+		db: db,
+	}
+}
+
+func (r *EmailJobRepository) CreateJobs(jobs []models.EmailJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(&jobs, 1000).Error
+}
+
+func (r *EmailJobRepository) CountBroadcastJobsByStatus(broadcastID uint) (map[string]int64, error) {
+	return r.countJobsByStatus("broadcast_id = ?", broadcastID)
+}
+
+func (r *EmailJobRepository) countJobsByStatus(query string, args ...interface{}) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&models.EmailJob{}).
+		Where(query, args...).
+		Select("status, count(*) as count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *EmailJobRepository) CountCampaignJobsByStatus(campaignID uint) (map[string]int64, error) {
+	return r.countJobsByStatus("campaign_id = ?", campaignID)
+}
+
+// GetJobByMessageID finds the job that sent a message. ID is 0 when there is
+// none.
+func (r *EmailJobRepository) GetJobByMessageID(messageID string) (*models.EmailJob, error) {
+	var job models.EmailJob
+	err := r.db.Where("message_id = ?", messageID).Limit(1).Find(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *EmailJobRepository) GetJobByID(id uint) (*models.EmailJob, error) {
+	var job models.EmailJob
+	err := r.db.Find(&job, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *EmailJobRepository) UpdateJobStatus(id uint, status, message string) error {
+	return r.db.Model(&models.EmailJob{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"status_message": message,
+			"updated_at":     time.Now(),
+		}).Error
+}
+
+// MarkOpened records the first open of a sent message. The status only moves
+// forward: clicked, bounced and rejected jobs keep theirs.
+func (r *EmailJobRepository) MarkOpened(id uint, at time.Time) error {
+	return r.db.Model(&models.EmailJob{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"opened_at": gorm.Expr("COALESCE(opened_at, ?)", at),
+			"status": gorm.Expr("CASE WHEN status IN ? THEN ? ELSE status END",
+				[]string{models.EmailJobStatusSending, models.EmailJobStatusSent}, models.EmailJobStatusOpened),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// MarkClicked records the first click in a sent message, which implies it
+// was opened.
+func (r *EmailJobRepository) MarkClicked(id uint, at time.Time) error {
+	return r.db.Model(&models.EmailJob{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"opened_at":  gorm.Expr("COALESCE(opened_at, ?)", at),
+			"clicked_at": gorm.Expr("COALESCE(clicked_at, ?)", at),
+			"status": gorm.Expr("CASE WHEN status IN ? THEN ? ELSE status END",
+				[]string{models.EmailJobStatusSending, models.EmailJobStatusSent, models.EmailJobStatusOpened}, models.EmailJobStatusClicked),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// ClaimJobs sets up to limit of the queued jobs due sending with at least
+// minPriority, highest priority then oldest first, leased to owner until the
+// given time. Rows locked by another claim in progress are skipped rather
+// than waited for, so concurrent workers never take the same job.
+func (r *EmailJobRepository) ClaimJobs(owner string, limit, minPriority int, until time.Time) ([]models.EmailJob, error) {
+	var jobs []models.EmailJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND priority >= ?", models.EmailJobStatusQueued, minPriority).
+			Where("next_attempt_at IS NULL OR next_attempt_at <= ?", time.Now()).
+			Order("priority DESC, created_at ASC, id ASC").
+			Limit(limit).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		return claim(tx, jobs, owner, until)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimJob claims one job by ID, if it is queued or dead and not locked by
+// another claim. ID is 0 when it cannot be claimed.
+func (r *EmailJobRepository) ClaimJob(id uint, owner string, until time.Time) (*models.EmailJob, error) {
+	var job models.EmailJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND status IN ?", id, []string{models.EmailJobStatusQueued, models.EmailJobStatusDead}).
+			Limit(1).
+			Find(&job).Error
+		if err != nil || job.ID == 0 {
+			return err
+		}
+		jobs := []models.EmailJob{job}
+		if err = claim(tx, jobs, owner, until); err != nil {
+			return err
+		}
+		job = jobs[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func claim(tx *gorm.DB, jobs []models.EmailJob, owner string, until time.Time) error {
+	ids := make([]uint, len(jobs))
+	for i := range jobs {
+		ids[i] = jobs[i].ID
+	}
+	now := time.Now()
+	err := tx.Model(&models.EmailJob{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":          models.EmailJobStatusSending,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"locked_by":       owner,
+			"locked_until":    until,
+			"next_attempt_at": nil,
+			"updated_at":      now,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		jobs[i].Status = models.EmailJobStatusSending
+		jobs[i].Attempts++
+		jobs[i].LockedBy = owner
+		jobs[i].LockedUntil = &until
+		jobs[i].NextAttemptAt = nil
+		jobs[i].UpdatedAt = now
+	}
+	return nil
+}
+
+// RenewLeases extends the leases owner still holds on the given jobs and
+// returns how many it holds.
+func (r *EmailJobRepository) RenewLeases(owner string, ids []uint, until time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(&models.EmailJob{}).
+		Where("id IN ? AND status = ? AND locked_by = ?", ids, models.EmailJobStatusSending, owner).
+		Update("locked_until", until)
+	return result.RowsAffected, result.Error
+}
+
+// ReleaseJobs returns jobs owner claimed but did not start to the queue,
+// undoing the attempt the claim counted.
+func (r *EmailJobRepository) ReleaseJobs(owner string, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.EmailJob{}).
+		Where("id IN ? AND status = ? AND locked_by = ?", ids, models.EmailJobStatusSending, owner).
+		Updates(map[string]interface{}{
+			"status":       models.EmailJobStatusQueued,
+			"attempts":     gorm.Expr("GREATEST(attempts - 1, 0)"),
+			"locked_by":    "",
+			"locked_until": nil,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// RetryJob returns a job owner holds to the queue, to be claimed again from
+// the given time.
+func (r *EmailJobRepository) RetryJob(owner string, id uint, message string, next time.Time) error {
+	return r.releaseJob(owner, id, map[string]interface{}{
+		"status":          models.EmailJobStatusQueued,
+		"status_message":  message,
+		"next_attempt_at": next,
+	})
+}
+
+// DeferJob returns a job owner holds to the queue until the given time
+// without counting the attempt, as it was never sent.
+func (r *EmailJobRepository) DeferJob(owner string, id uint, message string, until time.Time) error {
+	return r.releaseJob(owner, id, map[string]interface{}{
+		"status":          models.EmailJobStatusQueued,
+		"status_message":  message,
+		"attempts":        gorm.Expr("GREATEST(attempts - 1, 0)"),
+		"next_attempt_at": until,
+	})
+}
+
+// DeadLetterJob moves a job owner holds to the dead-letter queue.
+func (r *EmailJobRepository) DeadLetterJob(owner string, id uint, message string) error {
+	return r.releaseJob(owner, id, map[string]interface{}{
+		"status":         models.EmailJobStatusDead,
+		"status_message": message,
+	})
+}
+
+// FinishJob records the outcome of a job owner holds and releases it, and
+// reports whether owner still held it.
+func (r *EmailJobRepository) FinishJob(owner string, id uint, fields map[string]interface{}) (bool, error) {
+	fields["locked_by"] = ""
+	fields["locked_until"] = nil
+	fields["updated_at"] = time.Now()
+	result := r.db.Model(&models.EmailJob{}).
+		Where("id = ? AND status = ? AND locked_by = ?", id, models.EmailJobStatusSending, owner).
+		Updates(fields)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *EmailJobRepository) releaseJob(owner string, id uint, fields map[string]interface{}) error {
+	_, err := r.FinishJob(owner, id, fields)
+	return err
+}
+
+// RequeueExpiredJobs returns sending jobs whose lease has run out to the
+// queue, or dead-letters them once they have had maxAttempts. Jobs set
+// sending without a lease count as expired when not updated for the lease
+// timeout. It returns the numbers requeued and dead-lettered.
+func (r *EmailJobRepository) RequeueExpiredJobs(lease time.Duration, maxAttempts int) (int64, int64, error) {
+	now := time.Now()
+	expired := r.db.Model(&models.EmailJob{}).
+		Where("status = ?", models.EmailJobStatusSending).
+		Where("locked_until < ? OR (locked_until IS NULL AND updated_at < ?)", now, now.Add(-lease)).
+		Session(&gorm.Session{})
+
+	dead := expired.Where("attempts >= ?", maxAttempts).Updates(map[string]interface{}{
+		"status":         models.EmailJobStatusDead,
+		"status_message": "worker stopped while sending; no attempts left",
+		"locked_by":      "",
+		"locked_until":   nil,
+		"updated_at":     now,
+	})
+	if dead.Error != nil {
+		return 0, 0, dead.Error
+	}
+
+	requeued := expired.Where("attempts < ?", maxAttempts).Updates(map[string]interface{}{
+		"status":         models.EmailJobStatusQueued,
+		"status_message": "worker stopped while sending; requeued",
+		"locked_by":      "",
+		"locked_until":   nil,
+		"updated_at":     now,
+	})
+	if requeued.Error != nil {
+		return 0, dead.RowsAffected, requeued.Error
+	}
+	return requeued.RowsAffected, dead.RowsAffected, nil
+}
+
+// deadJobs selects dead jobs, by ID or, with no IDs, all of those matching
+// the filter.
+func (r *EmailJobRepository) deadJobs(filter models.DeadJobFilter) *gorm.DB {
+	query := r.db.Model(&models.EmailJob{}).Where("status = ?", models.EmailJobStatusDead)
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if filter.CampaignID != nil {
+		query = query.Where("campaign_id = ?", *filter.CampaignID)
+	}
+	if filter.BroadcastID != nil {
+		query = query.Where("broadcast_id = ?", *filter.BroadcastID)
+	}
+	return query
+}
+
+// GetDeadJobs pages through the dead-letter queue, most recently failed
+// first.
+func (r *EmailJobRepository) GetDeadJobs(filter models.DeadJobFilter, offset, limit int) ([]models.EmailJob, int64, error) {
+	query := r.deadJobs(filter).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []models.EmailJob
+	err := query.Preload("Contact").Order("updated_at DESC, id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// RequeueDeadJobs returns dead jobs to the queue with their attempts reset,
+// and returns how many were requeued.
+func (r *EmailJobRepository) RequeueDeadJobs(filter models.DeadJobFilter) (int64, error) {
+	result := r.deadJobs(filter).Updates(map[string]interface{}{
+		"status":          models.EmailJobStatusQueued,
+		"status_message":  "requeued from the dead-letter queue",
+		"attempts":        0,
+		"next_attempt_at": nil,
+		"updated_at":      time.Now(),
+	})
+	return result.RowsAffected, result.Error
+}
+
+// DiscardDeadJobs deletes dead jobs and returns how many were deleted.
+func (r *EmailJobRepository) DiscardDeadJobs(filter models.DeadJobFilter) (int64, error) {
+	result := r.deadJobs(filter).Delete(&models.EmailJob{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+const listContactCount = "(SELECT COUNT(*) FROM list_contacts WHERE list_contacts.list_id = lists.id " +
+	"AND list_contacts.status = 'subscribed') AS contact_count"
+
+type ListRepository struct {
+	db *gorm.DB
+}
+
+func NewListRepository(db *gorm.DB) *ListRepository {
+	return &ListRepository{
+		db: db,
+	}
+}
+
+func (r *ListRepository) CreateList(list *models.List) (models.List, error) {
+	err := r.db.Create(list).Error
+	return *list, err
+}
+
+func (r *ListRepository) GetAllLists() ([]models.List, error) {
+	var lists []models.List
+	err := r.db.Select("lists.*, " + listContactCount).Order("lists.id").Find(&lists).Error
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *ListRepository) GetListByID(id uint) (*models.List, error) {
+	var list models.List
+	err := r.db.Select("lists.*, "+listContactCount).Find(&list, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *ListRepository) GetListsByIDs(ids []uint) ([]models.List, error) {
+	var lists []models.List
+	if len(ids) == 0 {
+		return lists, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&lists).Error
+	return lists, err
+}
+
+func (r *ListRepository) GetPublicLists() ([]models.List, error) {
+	var lists []models.List
+	err := r.db.Where("public = ?", true).Order("name").Find(&lists).Error
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *ListRepository) UpdateList(list *models.List) (models.List, error) {
+	err := r.db.Model(list).Select("name", "description", "public", "opt_in", "updated_at").Updates(list).Error
+	return *list, err
+}
+
+func (r *ListRepository) DeleteList(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("list_id = ?", id).Delete(&models.ListContact{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&models.List{}, id).Error
+	})
+}
+
+// AddContacts links the given contacts to a list, skipping IDs that do not
+// exist or are already members, and returns how many were added. Contacts
+// that opted out of the list stay opted out.
+func (r *ListRepository) AddContacts(listID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	result := r.db.Exec(`
+		INSERT INTO list_contacts (list_id, contact_id, created_at, updated_at)
+		SELECT ?, id, ?, ? FROM contacts WHERE id IN ? AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING`,
+		listID, now, now, contactIDs)
+	return result.RowsAffected, result.Error
+}
+
+func (r *ListRepository) RemoveContacts(listID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	result := r.db.Where("list_id = ? AND contact_id IN ?", listID, contactIDs).
+		Delete(&models.ListContact{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *ListRepository) GetListContacts(listID uint, offset, limit int) ([]models.Contact, int64, error) {
+	query := r.db.Model(&models.Contact{}).
+		Joins("JOIN list_contacts ON list_contacts.contact_id = contacts.id").
+		Where("list_contacts.list_id = ?", listID).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var contacts []models.Contact
+	err := query.Order("contacts.id").Offset(offset).Limit(limit).Find(&contacts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return contacts, total, nil
+}
+
+// GetContactMemberships returns the public lists and the lists the contact
+// is a member of, with the contact's status on each.
+func (r *ListRepository) GetContactMemberships(contactID uint) ([]models.ListMembership, error) {
+	var memberships []models.ListMembership
+	err := r.db.Model(&models.List{}).
+		Select("lists.id, lists.name, lists.description, lists.public, COALESCE(list_contacts.status, '') AS status").
+		Joins("LEFT JOIN list_contacts ON list_contacts.list_id = lists.id AND list_contacts.contact_id = ?", contactID).
+		Where("(lists.public = ? OR list_contacts.contact_id IS NOT NULL)", true).
+		Order("lists.id").
+		Scan(&memberships).Error
+	return memberships, err
+}
+
+// SetContactStatus subscribes or unsubscribes a contact on a list, adding
+// the membership when there is none.
+func (r *ListRepository) SetContactStatus(listID, contactID uint, status string) error {
+	now := time.Now()
+	return r.db.Exec(`
+		INSERT INTO list_contacts (list_id, contact_id, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (list_id, contact_id) DO UPDATE SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at`,
+		listID, contactID, status, now, now).Error
+}
+
+// ConfirmContact subscribes the contact to the lists awaiting confirmation
+// and returns their IDs.
+func (r *ListRepository) ConfirmContact(contactID uint) ([]uint, error) {
+	var listIDs []uint
+	err := r.db.Raw(`
+		UPDATE list_contacts SET status = ?, updated_at = ?
+		WHERE contact_id = ? AND status = ?
+		RETURNING list_id`,
+		models.ListContactStatusSubscribed, time.Now(), contactID, models.ListContactStatusUnconfirmed).
+		Scan(&listIDs).Error
+	return listIDs, err
+}
+
+// DeleteUnconfirmed removes memberships that have waited for confirmation
+// since before the given time.
+func (r *ListRepository) DeleteUnconfirmed(before time.Time) (int64, error) {
+	result := r.db.Where("status = ? AND updated_at < ?", models.ListContactStatusUnconfirmed, before).
+		Delete(&models.ListContact{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type PreferenceAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferenceAuditRepository(db *gorm.DB) *PreferenceAuditRepository {
+	return &PreferenceAuditRepository{
+		db: db,
+	}
+}
+
+func (r *PreferenceAuditRepository) CreateAudits(audits []models.PreferenceAudit) error {
+	if len(audits) == 0 {
+		return nil
+	}
+	return r.db.Create(&audits).Error
+}
+
+func (r *PreferenceAuditRepository) GetAuditsByContact(contactID uint) ([]models.PreferenceAudit, error) {
+	var audits []models.PreferenceAudit
+	err := r.db.Where("contact_id = ?", contactID).Order("created_at DESC, id DESC").Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RateLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) *RateLimitRepository {
+	return &RateLimitRepository{
+		db: db,
+	}
+}
+
+func (r *RateLimitRepository) CreateLimit(limit *models.RateLimit) (models.RateLimit, error) {
+	err := r.db.Create(limit).Error
+	return *limit, err
+}
+
+// AddLimits inserts limits, keeping existing ones for the same scope and
+// key, and returns how many were added.
+func (r *RateLimitRepository) AddLimits(limits []models.RateLimit) (int64, error) {
+	if len(limits) == 0 {
+		return 0, nil
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&limits)
+	return result.RowsAffected, result.Error
+}
+
+// GetLimits lists limits, optionally of one scope.
+func (r *RateLimitRepository) GetLimits(scope string) ([]models.RateLimit, error) {
+	query := r.db.Model(&models.RateLimit{})
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	var limits []models.RateLimit
+	err := query.Order("scope ASC, key ASC").Find(&limits).Error
+	return limits, err
+}
+
+func (r *RateLimitRepository) GetLimitByID(id uint) (*models.RateLimit, error) {
+	var limit models.RateLimit
+	err := r.db.Find(&limit, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// LockLimit locks and returns a limit for the rest of the transaction.
+func (r *RateLimitRepository) LockLimit(id uint) (*models.RateLimit, error) {
+	var limit models.RateLimit
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&limit, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func (r *RateLimitRepository) GetLimit(scope, key string) (*models.RateLimit, error) {
+	var limit models.RateLimit
+	err := r.db.Where("scope = ? AND key = ?", scope, key).Limit(1).Find(&limit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func (r *RateLimitRepository) UpdateLimit(limit *models.RateLimit) (models.RateLimit, error) {
+	err := r.db.Model(limit).Select("rate", "burst", "tokens", "refilled_at", "updated_at").Updates(limit).Error
+	return *limit, err
+}
+
+func (r *RateLimitRepository) DeleteLimit(id uint) error {
+	return r.db.Delete(&models.RateLimit{}, id).Error
+}
+
+// LockLimits locks and returns the limits for domain and sender, and the
+// global limit if global is set, for the rest of the transaction. Rows are
+// locked in ID order so that concurrent senders cannot deadlock.
+func (r *RateLimitRepository) LockLimits(global bool, domain, sender string) ([]models.RateLimit, error) {
+	query := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND key = ?", models.RateLimitScopeDomain, domain).
+		Or("scope = ? AND key = ?", models.RateLimitScopeSender, sender)
+	if global {
+		query = query.Or("scope = ? AND key = ''", models.RateLimitScopeGlobal)
+	}
+
+	var limits []models.RateLimit
+	err := query.Order("id ASC").Find(&limits).Error
+	return limits, err
+}
+
+// SaveTokens stores the level of each bucket.
+func (r *RateLimitRepository) SaveTokens(limits []models.RateLimit) error {
+	for _, limit := range limits {
+		err := r.db.Model(&models.RateLimit{}).
+			Where("id = ?", limit.ID).
+			UpdateColumns(map[string]interface{}{
+				"tokens":      limit.Tokens,
+				"refilled_at": limit.RefilledAt,
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Now returns the database's clock, which every replica shares, so buckets
+// refill at the same rate whichever replica takes from them.
+func (r *RateLimitRepository) Now() (time.Time, error) {
+	var now time.Time
+	err := r.db.Raw("SELECT clock_timestamp()").Scan(&now).Error
+	return now, err
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type SegmentRepository struct {
+	db *gorm.DB
+}
+
+func NewSegmentRepository(db *gorm.DB) *SegmentRepository {
+	return &SegmentRepository{
+		db: db,
+	}
+}
+
+func (r *SegmentRepository) CreateSegment(segment *models.Segment) (models.Segment, error) {
+	err := r.db.Create(segment).Error
+	return *segment, err
+}
+
+func (r *SegmentRepository) GetAllSegments() ([]models.Segment, error) {
+	var segments []models.Segment
+	err := r.db.Order("id").Find(&segments).Error
+	if err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+func (r *SegmentRepository) GetSegmentByID(id uint) (*models.Segment, error) {
+	var segment models.Segment
+	err := r.db.Find(&segment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &segment, nil
+}
+
+func (r *SegmentRepository) UpdateSegment(segment *models.Segment) (models.Segment, error) {
+	err := r.db.Model(segment).Select("name", "description", "query", "updated_at").Updates(segment).Error
+	return *segment, err
+}
+
+func (r *SegmentRepository) DeleteSegment(id uint) error {
+	return r.db.Delete(&models.Segment{}, id).Error
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SuppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewSuppressionRepository(db *gorm.DB) *SuppressionRepository {
+	return &SuppressionRepository{
+		db: db,
+	}
+}
+
+func (r *SuppressionRepository) CreateSuppression(suppression *models.Suppression) (models.Suppression, error) {
+	err := r.db.Create(suppression).Error
+	return *suppression, err
+}
+
+// AddSuppressions inserts suppressions, keeping existing entries for the same
+// address or domain, and returns how many were added.
+func (r *SuppressionRepository) AddSuppressions(suppressions []models.Suppression) (int64, error) {
+	if len(suppressions) == 0 {
+		return 0, nil
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&suppressions, 1000)
+	return result.RowsAffected, result.Error
+}
+
+// GetSuppressions pages through suppressions, optionally of one type and
+// with values containing search.
+func (r *SuppressionRepository) GetSuppressions(suppressionType, search string, offset, limit int) ([]models.Suppression, int64, error) {
+	query := r.db.Model(&models.Suppression{})
+	if suppressionType != "" {
+		query = query.Where("type = ?", suppressionType)
+	}
+	if search != "" {
+		query = query.Where("value LIKE ?", "%"+escapeLike(strings.ToLower(search))+"%")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var suppressions []models.Suppression
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&suppressions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return suppressions, total, nil
+}
+
+func (r *SuppressionRepository) GetSuppressionByID(id uint) (*models.Suppression, error) {
+	var suppression models.Suppression
+	err := r.db.Find(&suppression, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+func (r *SuppressionRepository) GetSuppression(suppressionType, value string) (*models.Suppression, error) {
+	var suppression models.Suppression
+	err := r.db.Where("type = ? AND value = ?", suppressionType, value).Limit(1).Find(&suppression).Error
+	if err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+func (r *SuppressionRepository) UpdateSuppression(suppression *models.Suppression) (models.Suppression, error) {
+	err := r.db.Model(suppression).Select("reason", "details", "updated_at").Updates(suppression).Error
+	return *suppression, err
+}
+
+func (r *SuppressionRepository) DeleteSuppression(id uint) error {
+	return r.db.Delete(&models.Suppression{}, id).Error
+}
+
+// FindMatch returns the suppression covering an address, preferring one for
+// the address itself over one for its domain. ID is 0 when there is none.
+func (r *SuppressionRepository) FindMatch(email string) (*models.Suppression, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	domain := email[strings.LastIndexByte(email, '@')+1:]
+
+	var suppression models.Suppression
+	err := r.db.Where("(type = ? AND value = ?) OR (type = ? AND value = ?)",
+		models.SuppressionTypeEmail, email, models.SuppressionTypeDomain, domain).
+		Order("type DESC").
+		Limit(1).
+		Find(&suppression).Error
+	if err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"gorm.io/gorm"
+)
+
+type WebhookEventRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEventRepository(db *gorm.DB) *WebhookEventRepository {
+	return &WebhookEventRepository{
+		db: db,
+	}
+}
+
+func (r *WebhookEventRepository) CreateEvents(events []models.WebhookEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(&events, 500).Error
+}
+
+// GetEvents pages through stored events, newest first, optionally from one
+// provider and with one status.
+func (r *WebhookEventRepository) GetEvents(provider, status string, offset, limit int) ([]models.WebhookEvent, int64, error) {
+	query := r.db.Model(&models.WebhookEvent{})
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.WebhookEvent
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
@@ -3,12 +3,15 @@ package scheduler
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
 	"github.com/MdSadiqMd/Broadcast-API/internal/services"
 	"github.com/MdSadiqMd/Broadcast-API/internal/workers"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/bounce"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
 	"github.com/go-co-op/gocron"
@@ -16,41 +19,44 @@ import (
 )
 
 type Scheduler struct {
-	db          *gorm.DB
-	config      config.Config
-	cron        *gocron.Scheduler
-	workers     []*workers.MailWorker
-	smtpClient  *email.SMTPClient
-	mailService *services.MailService
-	mutex       sync.Mutex
+	db           *gorm.DB
+	config       config.Config
+	cron         *gocron.Scheduler
+	workers      []*workers.MailWorker
+	mailer       email.Mailer
+	mailService  *services.MailService
+	queueService *services.QueueService
+	listener     *workers.QueueListener
+	mutex        sync.Mutex
 }
 
-func NewScheduler(db *gorm.DB, config config.Config) *Scheduler {
-	smtpClient := email.NewSMTPClient(email.SMTPConfig{
-		Host:     config.SMTP.Host,
-		Port:     config.SMTP.Port,
-		Username: config.SMTP.Username,
-		Password: config.SMTP.Password,
-		FromName: config.SMTP.FromName,
-		FromAddr: config.SMTP.FromAddr,
-		UseTLS:   true,
-	})
+func NewScheduler(db *gorm.DB, config config.Config) (*Scheduler, error) {
+	mailer, err := email.NewMailer(config)
+	if err != nil {
+		return nil, err
+	}
 
-	mailService := services.NewMailService(db, smtpClient)
+	mailService := services.NewMailService(db, mailer, config)
 
 	return &Scheduler{
-		db:          db,
-		config:      config,
-		cron:        gocron.NewScheduler(time.UTC),
-		workers:     make([]*workers.MailWorker, 0),
-		smtpClient:  smtpClient,
-		mailService: mailService,
-	}
+		db:           db,
+		config:       config,
+		cron:         gocron.NewScheduler(time.UTC),
+		workers:      make([]*workers.MailWorker, 0),
+		mailer:       mailer,
+		mailService:  mailService,
+		queueService: services.NewQueueService(db, config.Queue),
+		listener:     workers.NewQueueListener(config.Database.DSN()),
+	}, nil
 }
 
 func (s *Scheduler) Start() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	if err := services.NewRateLimitService(s.db, s.config.Queue).SeedLimits(); err != nil {
+		return fmt.Errorf("error creating rate limits: %w", err)
+	}
+
 	_, err := s.cron.Every(1).Minute().Do(func() {
 		s.processCampaigns()
 	})
@@ -58,6 +64,20 @@ func (s *Scheduler) Start() error {
 		return err
 	}
 
+	_, err = s.cron.Every(1).Minute().Do(func() {
+		s.processBroadcasts()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.cron.Every(1).Minute().Do(func() {
+		s.requeueExpiredJobs()
+	})
+	if err != nil {
+		return err
+	}
+
 	_, err = s.cron.Every(15).Minutes().Do(func() {
 		s.processBouncedEmails()
 	})
@@ -72,7 +92,22 @@ func (s *Scheduler) Start() error {
 		return err
 	}
 
+	_, err = s.cron.Every(1).Hour().Do(func() {
+		s.purgeExpiredExports()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.cron.Every(1).Hour().Do(func() {
+		s.expireUnconfirmedSignups()
+	})
+	if err != nil {
+		return err
+	}
+
 	s.cron.StartAsync()
+	s.listener.Start()
 	s.startWorkers()
 	log.Println("Scheduler started successfully")
 	return nil
@@ -86,28 +121,38 @@ func (s *Scheduler) Stop() {
 	for _, worker := range s.workers {
 		worker.Stop()
 	}
+	s.listener.Stop()
+
+	if err := s.mailer.Close(); err != nil {
+		log.Printf("Error closing mailer: %v\n", err)
+	}
 
 	log.Println("Scheduler stopped")
 }
 
+// startWorkers replaces the workers. The caller holds s.mutex.
 func (s *Scheduler) startWorkers() {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
 	for _, worker := range s.workers {
 		worker.Stop()
 	}
 
+	// Bulk workers take transactional jobs first too; the transactional
+	// workers are kept free of bulk mail, so a campaign never holds them up.
 	workerCount := s.config.Queue.WorkerCount
-	rateLimit := s.config.Queue.RateLimit
-	s.workers = make([]*workers.MailWorker, workerCount)
+	transactionalCount := s.config.Queue.TransactionalWorkerCount
+	s.workers = make([]*workers.MailWorker, workerCount+transactionalCount)
 
-	for i := 0; i < workerCount; i++ {
-		worker := workers.NewMailWorker(s.db, s.smtpClient, i+1, rateLimit/workerCount)
+	for i := range s.workers {
+		minPriority := models.EmailJobPriorityBulk
+		if i >= workerCount {
+			minPriority = models.EmailJobPriorityTransactional
+		}
+		worker := workers.NewMailWorker(s.db, s.mailService, s.queueService, s.listener.Subscribe(), i+1, minPriority)
 		s.workers[i] = worker
 		worker.Start()
 	}
 
-	log.Printf("Started %d workers with rate limit of %d emails/minute\n", workerCount, rateLimit)
+	log.Printf("Started %d workers and %d transactional workers\n", workerCount, transactionalCount)
 }
 
 func (s *Scheduler) processCampaigns() {
@@ -130,6 +175,8 @@ func (s *Scheduler) processCampaigns() {
 		return
 	}
 
+	contactRepo := repositories.NewContactRepository(s.db)
+	segmentService := services.NewSegmentService(s.db)
 	for _, campaign := range campaigns {
 		log.Printf("Processing campaign: %s (ID: %d)\n", campaign.Name, campaign.ID)
 		err = s.db.Model(&campaign).Update("status", models.CampaignStatusProcessing).Error
@@ -138,13 +185,22 @@ func (s *Scheduler) processCampaigns() {
 			continue
 		}
 
-		var contacts []*models.Contact
-		err = s.db.Model(&campaign).Association("Contacts").Find(&contacts)
+		seg, err := segmentService.CompileAudience(campaign.SegmentID)
+		if err != nil {
+			log.Printf("Error compiling segment for campaign: %v\n", err)
+			s.db.Model(&campaign).Updates(map[string]interface{}{
+				"status":         models.CampaignStatusError,
+				"status_message": statusMessage(err),
+			})
+			continue
+		}
+
+		contacts, err := contactRepo.GetAudienceContacts(campaign.ID, models.ListIDs(campaign.Lists), seg)
 		if err != nil {
 			log.Printf("Error getting contacts for campaign: %v\n", err)
 			s.db.Model(&campaign).Updates(map[string]interface{}{
 				"status":         models.CampaignStatusError,
-				"status_message": err.Error(),
+				"status_message": statusMessage(err),
 			})
 			continue
 		}
@@ -158,38 +214,36 @@ func (s *Scheduler) processCampaigns() {
 			continue
 		}
 
-		batchSize := 1000
-		totalContacts := len(contacts)
-		jobsCreated := 0
-
-		for i := 0; i < totalContacts; i += batchSize {
-			end := i + batchSize
-			if end > totalContacts {
-				end = totalContacts
-			}
-
-			batch := contacts[i:end]
-			err = s.createEmailJobs(campaign.ID, batch)
-			if err != nil {
-				log.Printf("Error creating email jobs: %v\n", err)
-				continue
-			}
-
-			jobsCreated += len(batch)
+		if err = s.createEmailJobs(campaign.ID, contacts); err != nil {
+			log.Printf("Error creating email jobs: %v\n", err)
+			s.db.Model(&campaign).Updates(map[string]interface{}{
+				"status":         models.CampaignStatusError,
+				"status_message": statusMessage(err),
+			})
+			continue
 		}
 
 		now := time.Now()
 		s.db.Model(&campaign).Updates(map[string]interface{}{
 			"status":         models.CampaignStatusQueued,
 			"queued_at":      now,
-			"status_message": fmt.Sprintf("Queued %d emails", jobsCreated),
+			"status_message": fmt.Sprintf("Queued %d emails", len(contacts)),
 		})
 
-		log.Printf("Queued %d emails for campaign: %s\n", jobsCreated, campaign.Name)
+		log.Printf("Queued %d emails for campaign: %s\n", len(contacts), campaign.Name)
 	}
 }
 
-func (s *Scheduler) createEmailJobs(campaignID uint, contacts []*models.Contact) error {
+func (s *Scheduler) processBroadcasts() {
+	log.Println("Processing scheduled broadcasts...")
+	broadcastService := services.NewBroadcastService(s.db)
+	broadcastService.QueueDueBroadcasts()
+	broadcastService.CompleteBroadcasts()
+}
+
+// createEmailJobs queues a job per contact, in batches but in a single
+// transaction, so that a campaign is queued whole or not at all.
+func (s *Scheduler) createEmailJobs(campaignID uint, contacts []models.Contact) error {
 	if len(contacts) == 0 {
 		return nil
 	}
@@ -198,31 +252,86 @@ func (s *Scheduler) createEmailJobs(campaignID uint, contacts []*models.Contact)
 		now := time.Now()
 		jobs := make([]models.EmailJob, len(contacts))
 
-		for i, contact := range contacts {
+		for i := range contacts {
 			jobs[i] = models.EmailJob{
-				CampaignID:   campaignID,
-				SubscriberID: contact.ID,
-				Status:       models.EmailJobStatusQueued,
-				CreatedAt:    now,
-				UpdatedAt:    now,
+				CampaignID: &campaignID,
+				ContactID:  &contacts[i].ID,
+				Status:     models.EmailJobStatusQueued,
+				CreatedAt:  now,
+				UpdatedAt:  now,
 			}
 		}
 
-		return tx.Create(&jobs).Error
+		return tx.CreateInBatches(&jobs, 1000).Error
 	})
 }
 
+// statusMessage fits an error into a 255-character status_message column.
+func statusMessage(err error) string {
+	message := err.Error()
+	if len(message) <= 255 {
+		return message
+	}
+	return strings.ToValidUTF8(message[:255], "")
+}
+
+// requeueExpiredJobs returns jobs claimed by a worker or replica that died
+// while sending to the queue.
+func (s *Scheduler) requeueExpiredJobs() {
+	requeued, failed, err := s.queueService.RequeueExpiredJobs()
+	if err != nil {
+		log.Printf("Error requeueing expired jobs: %v\n", err)
+		return
+	}
+	if requeued > 0 || failed > 0 {
+		log.Printf("Requeued %d and failed %d jobs with expired leases\n", requeued, failed)
+	}
+}
+
 func (s *Scheduler) processBouncedEmails() {
+	if !s.config.Bounce.Enabled {
+		return
+	}
 	log.Println("Processing bounced emails...")
-	// TODO: should add logic for checking a mailbox via IMAP/POP3 or an API
+
+	mailbox, err := bounce.NewMailbox(s.config)
+	if err != nil {
+		log.Printf("Error configuring bounce mailbox: %v\n", err)
+		return
+	}
+	defer func() {
+		if err := mailbox.Close(); err != nil {
+			log.Printf("Error closing bounce mailbox: %v\n", err)
+		}
+	}()
+
+	recorded, err := services.NewDeliveryService(s.db, s.config).ProcessMailbox(mailbox, s.config.Bounce.BatchSize)
+	if err != nil {
+		log.Printf("Error processing bounce mailbox: %v\n", err)
+	}
+	if recorded > 0 {
+		log.Printf("Recorded %d bounces from the bounce mailbox\n", recorded)
+	}
+}
+
+func (s *Scheduler) purgeExpiredExports() {
+	log.Println("Purging expired contact exports...")
+	services.NewContactExportService(s.db, s.config.Exports).PurgeExpiredExports()
+}
+
+func (s *Scheduler) expireUnconfirmedSignups() {
+	log.Println("Expiring unconfirmed signups...")
+	services.NewSubscriptionService(s.db, s.mailService, s.config).ExpireUnconfirmed()
 }
 
+// aggregateStats completes campaigns once none of their jobs are waiting in
+// the queue, retrying or being sent.
 func (s *Scheduler) aggregateStats() {
 	log.Println("Aggregating email statistics...")
 	var campaigns []models.Campaign
 	err := s.db.Where("status IN ?", []string{
+		models.CampaignStatusQueued,
 		models.CampaignStatusRunning,
-		models.CampaignStatusCompleted,
 	}).Find(&campaigns).Error
 
 	if err != nil {
@@ -230,38 +339,29 @@ func (s *Scheduler) aggregateStats() {
 		return
 	}
 
+	jobRepo := repositories.NewEmailJobRepository(s.db)
 	for _, campaign := range campaigns {
-		var stats = map[string]int{
-			"queued":  0,
-			"sending": 0,
-			"sent":    0,
-			"failed":  0,
-		}
-
-		err := s.db.Model(&models.EmailJob{}).
-			Where("campaign_id = ?", campaign.ID).
-			Select("status, count(*) as count").
-			Group("status").
-			Scan(&[]struct {
-				Status string
-				Count  int
-			}{}).Error
-
+		stats, err := jobRepo.CountCampaignJobsByStatus(campaign.ID)
 		if err != nil {
 			log.Printf("Error getting stats for campaign %d: %v\n", campaign.ID, err)
 			continue
 		}
 
-		total := stats["queued"] + stats["sending"] + stats["sent"] + stats["failed"]
-		sent := stats["sent"]
-		failed := stats["failed"]
+		var total int64
+		for _, count := range stats {
+			total += count
+		}
+		pending := stats[models.EmailJobStatusQueued] + stats[models.EmailJobStatusSending]
+		sent := stats[models.EmailJobStatusSent] + stats[models.EmailJobStatusOpened] + stats[models.EmailJobStatusClicked]
+		failed := stats[models.EmailJobStatusDead] + stats[models.EmailJobStatusRejected] + stats[models.EmailJobStatusBounced]
 
-		if total > 0 && (sent+failed == total) {
+		if total > 0 && pending == 0 {
 			now := time.Now()
 			s.db.Model(&campaign).Updates(map[string]interface{}{
-				"status":         models.CampaignStatusCompleted,
-				"completed_at":   now,
-				"status_message": fmt.Sprintf("Completed: %d sent, %d failed", sent, failed),
+				"status":       models.CampaignStatusCompleted,
+				"completed_at": now,
+				"status_message": fmt.Sprintf("Completed: %d sent, %d failed, %d skipped",
+					sent, failed, stats[models.EmailJobStatusSkipped]),
 			})
 			log.Printf("Campaign %d marked as completed\n", campaign.ID)
 		}
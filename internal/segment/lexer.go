@@ -0,0 +1,170 @@
+package segment
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOperator
+	tokPlus
+	tokMinus
+	tokColon
+	tokComma
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return fmt.Sprintf("string %q", t.value)
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
+
+// keyword reports whether the token is the given case-insensitive keyword.
+func (t token) keyword(word string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.value, word)
+}
+
+// durationUnits are the suffixes accepted after a number in relative times
+// such as now-30d.
+const durationUnits = "smhdw"
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", start})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", start})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokComma, ",", start})
+			i++
+		case r == ':':
+			tokens = append(tokens, token{tokColon, ":", start})
+			i++
+		case r == '+':
+			tokens = append(tokens, token{tokPlus, "+", start})
+			i++
+		case r == '-':
+			tokens = append(tokens, token{tokMinus, "-", start})
+			i++
+		case r == '=':
+			tokens = append(tokens, token{tokOperator, "=", start})
+			i++
+		case r == '!' || r == '<' || r == '>':
+			op := string(r)
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				op += "="
+				i++
+			} else if r == '<' && i < len(runes) && runes[i] == '>' {
+				op = "!="
+				i++
+			}
+			if op == "!" {
+				return nil, syntaxError(start, "unexpected \"!\", did you mean \"!=\"?")
+			}
+			tokens = append(tokens, token{tokOperator, op, start})
+		case r == '"' || r == '\'':
+			value, end, err := lexString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokString, value, start})
+			i = end
+		case unicode.IsDigit(r):
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			} else if i < len(runes) && strings.ContainsRune(durationUnits, runes[i]) &&
+				(i+1 == len(runes) || !isIdentRune(runes[i+1])) {
+				i++
+				tokens = append(tokens, token{tokDuration, string(runes[start:i]), start})
+				continue
+			}
+			if i < len(runes) && isIdentRune(runes[i]) {
+				return nil, syntaxError(start, "invalid number %q", string(runes[start:i+1]))
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && isIdentRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i]), start})
+		default:
+			return nil, syntaxError(start, "unexpected character %q", r)
+		}
+	}
+
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+// lexString reads a quoted string starting at runes[start] and returns its
+// unescaped value and the index just past the closing quote.
+func lexString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var value strings.Builder
+
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case quote:
+			return value.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(runes) {
+				return "", 0, syntaxError(start, "unterminated string")
+			}
+			switch runes[i] {
+			case 'n':
+				value.WriteRune('\n')
+			case 't':
+				value.WriteRune('\t')
+			default:
+				value.WriteRune(runes[i])
+			}
+		default:
+			value.WriteRune(runes[i])
+		}
+	}
+	return "", 0, syntaxError(start, "unterminated string")
+}
@@ -0,0 +1,372 @@
+package segment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLength bounds the size of a segment query.
+	MaxLength = 4096
+	// maxDepth bounds the nesting of parentheses and NOT, so a hostile query
+	// cannot exhaust the stack while parsing or the planner while running.
+	maxDepth = 32
+	// maxConditions bounds the number of comparisons in one query.
+	maxConditions = 200
+)
+
+// SyntaxError describes why a segment query could not be parsed. Pos is the
+// 1-based character offset of the problem.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+type expr interface{}
+
+type logicalExpr struct {
+	op          string
+	left, right expr
+}
+
+type notExpr struct {
+	expr expr
+}
+
+// comparison tests a contact field. op is one of = != < <= > >= contains in
+// "is null" or "is not null"; values holds one value, or several for in.
+type comparison struct {
+	field  field
+	op     string
+	values []value
+	pos    int
+}
+
+// membership matches contacts in a list or campaign, by ID or by name.
+type membership struct {
+	kind string
+	id   uint
+	name string
+}
+
+type field struct {
+	name string
+	// path holds the keys after "attributes." for attribute fields.
+	path []string
+}
+
+func (f field) String() string {
+	if f.name == "attributes" {
+		return "attributes." + strings.Join(f.path, ".")
+	}
+	return f.name
+}
+
+type valueKind int
+
+const (
+	valueString valueKind = iota
+	valueNumber
+	valueBool
+	valueTime
+)
+
+type value struct {
+	kind valueKind
+	text string
+	b    bool
+	t    time.Time
+	pos  int
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	depth      int
+	conditions int
+	now        time.Time
+}
+
+func parse(input string, now time.Time) (expr, error) {
+	if len([]rune(input)) > MaxLength {
+		return nil, syntaxError(MaxLength, "query is longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokEOF {
+		return nil, syntaxError(0, "query is empty")
+	}
+
+	p := &parser{tokens: tokens, now: now}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, syntaxError(tok.pos, "unexpected %s", tok)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, syntaxError(p.peek().pos, "query is nested too deeply")
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.keyword("not"):
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: node}, nil
+	case tok.kind == tokLParen:
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, syntaxError(closing.pos, "expected \")\" but found %s", closing)
+		}
+		return node, nil
+	case tok.kind == tokIdent:
+		p.conditions++
+		if p.conditions > maxConditions {
+			return nil, syntaxError(tok.pos, "query has more than %d conditions", maxConditions)
+		}
+		if p.tokens[p.pos+1].kind == tokColon {
+			return p.parseMembership()
+		}
+		return p.parseComparison()
+	default:
+		return nil, syntaxError(tok.pos, "expected a condition but found %s", tok)
+	}
+}
+
+func (p *parser) parseMembership() (expr, error) {
+	kindTok := p.next()
+	p.next() // colon
+
+	kind := strings.ToLower(kindTok.value)
+	if kind != "list" && kind != "campaign" {
+		return nil, syntaxError(kindTok.pos, "unknown membership %q, expected list or campaign", kindTok.value)
+	}
+
+	ref := p.next()
+	switch ref.kind {
+	case tokNumber:
+		id, err := strconv.ParseUint(ref.value, 10, 32)
+		if err != nil || id == 0 {
+			return nil, syntaxError(ref.pos, "invalid %s ID %q", kind, ref.value)
+		}
+		return membership{kind: kind, id: uint(id)}, nil
+	case tokIdent, tokString:
+		if ref.value == "" {
+			return nil, syntaxError(ref.pos, "%s name is empty", kind)
+		}
+		return membership{kind: kind, name: ref.value}, nil
+	default:
+		return nil, syntaxError(ref.pos, "expected a %s name or ID but found %s", kind, ref)
+	}
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	fieldTok := p.next()
+	f, err := parseField(fieldTok)
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.next()
+	switch {
+	case opTok.kind == tokOperator:
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{field: f, op: opTok.value, values: []value{v}, pos: fieldTok.pos}, nil
+	case opTok.keyword("contains"):
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{field: f, op: "contains", values: []value{v}, pos: fieldTok.pos}, nil
+	case opTok.keyword("in"):
+		values, err := p.parseValueList()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{field: f, op: "in", values: values, pos: fieldTok.pos}, nil
+	case opTok.keyword("is"):
+		op := "is null"
+		if p.peek().keyword("not") {
+			p.next()
+			op = "is not null"
+		}
+		if tok := p.next(); !tok.keyword("null") {
+			return nil, syntaxError(tok.pos, "expected NULL but found %s", tok)
+		}
+		return comparison{field: f, op: op, pos: fieldTok.pos}, nil
+	default:
+		return nil, syntaxError(opTok.pos, "expected an operator after %q but found %s", fieldTok.value, opTok)
+	}
+}
+
+func parseField(tok token) (field, error) {
+	name := strings.ToLower(tok.value)
+	if strings.HasPrefix(name, "attributes.") {
+		path := strings.Split(tok.value[len("attributes."):], ".")
+		for _, key := range path {
+			if key == "" {
+				return field{}, syntaxError(tok.pos, "invalid attribute %q", tok.value)
+			}
+		}
+		return field{name: "attributes", path: path}, nil
+	}
+	if _, ok := columns[name]; !ok {
+		return field{}, syntaxError(tok.pos, "unknown field %q", tok.value)
+	}
+	return field{name: name}, nil
+}
+
+func (p *parser) parseValueList() ([]value, error) {
+	if tok := p.next(); tok.kind != tokLParen {
+		return nil, syntaxError(tok.pos, "expected \"(\" after IN but found %s", tok)
+	}
+
+	var values []value
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if len(values) > maxConditions {
+			return nil, syntaxError(v.pos, "IN has more than %d values", maxConditions)
+		}
+
+		tok := p.next()
+		if tok.kind == tokRParen {
+			return values, nil
+		}
+		if tok.kind != tokComma {
+			return nil, syntaxError(tok.pos, "expected \",\" or \")\" but found %s", tok)
+		}
+	}
+}
+
+func (p *parser) parseValue() (value, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokString:
+		return value{kind: valueString, text: tok.value, pos: tok.pos}, nil
+	case tok.kind == tokNumber:
+		return value{kind: valueNumber, text: tok.value, pos: tok.pos}, nil
+	case tok.kind == tokMinus && p.peek().kind == tokNumber:
+		return value{kind: valueNumber, text: "-" + p.next().value, pos: tok.pos}, nil
+	case tok.keyword("true"), tok.keyword("false"):
+		return value{kind: valueBool, b: tok.keyword("true"), pos: tok.pos}, nil
+	case tok.keyword("now"):
+		return p.parseRelativeTime(tok)
+	default:
+		return value{}, syntaxError(tok.pos, "expected a value but found %s", tok)
+	}
+}
+
+// parseRelativeTime reads now, now-30d or now+2h. Units are s, m, h, d and w.
+func (p *parser) parseRelativeTime(nowTok token) (value, error) {
+	v := value{kind: valueTime, t: p.now, pos: nowTok.pos}
+
+	sign := p.peek()
+	if sign.kind != tokPlus && sign.kind != tokMinus {
+		return v, nil
+	}
+	p.next()
+
+	tok := p.next()
+	if tok.kind != tokDuration {
+		return value{}, syntaxError(tok.pos, "expected a duration such as 30d but found %s", tok)
+	}
+	amount, err := strconv.Atoi(tok.value[:len(tok.value)-1])
+	if err != nil || amount > 100*366 {
+		return value{}, syntaxError(tok.pos, "invalid duration %q", tok.value)
+	}
+	if sign.kind == tokMinus {
+		amount = -amount
+	}
+
+	switch tok.value[len(tok.value)-1] {
+	case 's':
+		v.t = v.t.Add(time.Duration(amount) * time.Second)
+	case 'm':
+		v.t = v.t.Add(time.Duration(amount) * time.Minute)
+	case 'h':
+		v.t = v.t.Add(time.Duration(amount) * time.Hour)
+	case 'd':
+		v.t = v.t.AddDate(0, 0, amount)
+	case 'w':
+		v.t = v.t.AddDate(0, 0, 7*amount)
+	}
+	return v, nil
+}
@@ -0,0 +1,341 @@
+package segment
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+)
+
+type columnType int
+
+const (
+	typeString columnType = iota
+	typeNumber
+	typeBool
+	typeTime
+)
+
+func (t columnType) String() string {
+	switch t {
+	case typeNumber:
+		return "number"
+	case typeBool:
+		return "boolean"
+	case typeTime:
+		return "time"
+	default:
+		return "string"
+	}
+}
+
+type column struct {
+	sql string
+	typ columnType
+}
+
+// columns are the contact fields a segment can query besides attributes.
+var columns = map[string]column{
+	"id":           {"contacts.id", typeNumber},
+	"email":        {"contacts.email", typeString},
+	"first_name":   {"contacts.first_name", typeString},
+	"last_name":    {"contacts.last_name", typeString},
+	"status":       {"contacts.status", typeString},
+	"unsubscribed": {"contacts.un_subscribe", typeBool},
+	"created_at":   {"contacts.created_at", typeTime},
+	"updated_at":   {"contacts.updated_at", typeTime},
+}
+
+// Values are only compared as numbers or times when the stored text looks
+// like one, so a stray value fails the condition instead of the query.
+// They are bound as parameters because gorm treats "?" as a placeholder.
+//
+// timePattern matches exactly what ParseTime accepts, so that the cast
+// cannot fail: real calendar dates, leap days included, times of day and
+// offsets PostgreSQL can store. Digits are spelled [0-9] because \d also
+// matches other scripts' digits in PostgreSQL.
+const (
+	numberPattern = `^\s*-?[0-9]+(\.[0-9]+)?\s*$`
+	timePattern   = `^` + datePattern + `(T` + clockPattern + zonePattern + `?| ` + clockPattern + `)?$`
+
+	yearPattern     = `(000[1-9]|00[1-9][0-9]|0[1-9][0-9]{2}|[1-9][0-9]{3})`
+	leapYearPattern = `([0-9]{2}(0[48]|[2468][048]|[13579][26])|(0[48]|[2468][048]|[13579][26])00)`
+	datePattern     = `(` + yearPattern + `-(0[13578]|1[02])-(0[1-9]|[12][0-9]|3[01])` +
+		`|` + yearPattern + `-(0[469]|11)-(0[1-9]|[12][0-9]|30)` +
+		`|` + yearPattern + `-02-(0[1-9]|1[0-9]|2[0-8])` +
+		`|` + leapYearPattern + `-02-29)`
+	clockPattern = `([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9](\.[0-9]+)?`
+	zonePattern  = `(Z|[+-](0[0-9]|1[0-5]):[0-5][0-9])`
+)
+
+// Query is a compiled segment: a condition on the contacts table, written
+// with "?" placeholders for Args, to be used in a WHERE clause.
+type Query struct {
+	SQL  string
+	Args []interface{}
+}
+
+// AttributeTypes maps attribute keys to their declared models.AttributeType*.
+// Declared attributes are compared as their type and the values they are
+// compared with must fit it; others take the type of the value.
+type AttributeTypes map[string]string
+
+var declaredTypes = map[string]columnType{
+	models.AttributeTypeString:  typeString,
+	models.AttributeTypeNumber:  typeNumber,
+	models.AttributeTypeBoolean: typeBool,
+	models.AttributeTypeDate:    typeTime,
+}
+
+// Compile parses a segment query and turns it into parameterised SQL.
+// Relative times such as now-30d are resolved against the current time, so
+// a segment should be compiled when it is used, not when it is saved.
+func Compile(input string, types AttributeTypes) (*Query, error) {
+	return compileAt(input, types, time.Now())
+}
+
+func compileAt(input string, types AttributeTypes, now time.Time) (*Query, error) {
+	node, err := parse(input, now)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiler{types: types}
+	sql, err := c.compileExpr(node)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{SQL: sql, Args: c.args}, nil
+}
+
+type compiler struct {
+	types AttributeTypes
+	args  []interface{}
+}
+
+func (c *compiler) compileExpr(node expr) (string, error) {
+	switch n := node.(type) {
+	case logicalExpr:
+		left, err := c.compileExpr(n.left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.compileExpr(n.right)
+		if err != nil {
+			return "", err
+		}
+		return "(" + left + " " + n.op + " " + right + ")", nil
+	case notExpr:
+		inner, err := c.compileExpr(n.expr)
+		if err != nil {
+			return "", err
+		}
+		return "(NOT " + inner + ")", nil
+	case membership:
+		return c.compileMembership(n), nil
+	case comparison:
+		return c.compileComparison(n)
+	default:
+		return "", fmt.Errorf("unsupported segment node %T", node)
+	}
+}
+
+// compileMembership matches list members that have not opted out of the
+// list, and campaign audiences.
+func (c *compiler) compileMembership(m membership) string {
+	table, join, column := "list_contacts", "lists", "list_id"
+	status := " AND list_contacts.status = 'subscribed'"
+	if m.kind == "campaign" {
+		table, join, column = "campaign_audiences", "campaigns", "campaign_id"
+		status = ""
+	}
+
+	if m.name == "" {
+		c.args = append(c.args, m.id)
+		return fmt.Sprintf("contacts.id IN (SELECT %[1]s.contact_id FROM %[1]s WHERE %[1]s.%[2]s = ?%[3]s)", table, column, status)
+	}
+	c.args = append(c.args, m.name)
+	return fmt.Sprintf("contacts.id IN (SELECT %[1]s.contact_id FROM %[1]s JOIN %[2]s ON %[2]s.id = %[1]s.%[3]s "+
+		"WHERE %[2]s.name = ? AND %[2]s.deleted_at IS NULL%[4]s)", table, join, column, status)
+}
+
+// compileComparison never yields NULL: a missing attribute simply does not
+// match, so NOT and != include the contacts that lack it.
+func (c *compiler) compileComparison(cmp comparison) (string, error) {
+	if cmp.op == "is null" || cmp.op == "is not null" {
+		return c.fieldText(cmp.field) + " " + strings.ToUpper(cmp.op), nil
+	}
+	if cmp.op == "!=" {
+		eq := cmp
+		eq.op = "="
+		sql, err := c.compileComparison(eq)
+		if err != nil {
+			return "", err
+		}
+		return "(NOT " + sql + ")", nil
+	}
+	if cmp.op == "in" {
+		parts := make([]string, len(cmp.values))
+		for i, v := range cmp.values {
+			sql, err := c.compileComparison(comparison{field: cmp.field, op: "=", values: []value{v}, pos: cmp.pos})
+			if err != nil {
+				return "", err
+			}
+			parts[i] = sql
+		}
+		return "(" + strings.Join(parts, " OR ") + ")", nil
+	}
+
+	typ, v, err := c.comparisonType(cmp, cmp.values[0])
+	if err != nil {
+		return "", err
+	}
+
+	if cmp.op == "contains" {
+		if typ != typeString {
+			return "", syntaxError(cmp.pos, "CONTAINS needs a string field and value")
+		}
+		lhs := c.fieldText(cmp.field)
+		c.args = append(c.args, "%"+escapeLike(v.text)+"%")
+		return "COALESCE(" + lhs + " ILIKE ?, FALSE)", nil
+	}
+
+	if typ == typeBool && cmp.op != "=" {
+		return "", syntaxError(v.pos, "booleans can only be compared with = or !=")
+	}
+
+	lhs := c.fieldExpr(cmp.field, typ)
+	switch typ {
+	case typeNumber:
+		c.args = append(c.args, v.text)
+		return "COALESCE(" + lhs + " " + cmp.op + " ?::numeric, FALSE)", nil
+	case typeBool:
+		if cmp.field.name == "attributes" {
+			c.args = append(c.args, fmt.Sprint(v.b))
+		} else {
+			c.args = append(c.args, v.b)
+		}
+	case typeTime:
+		c.args = append(c.args, v.t)
+	default:
+		c.args = append(c.args, v.text)
+	}
+	return "COALESCE(" + lhs + " " + cmp.op + " ?, FALSE)", nil
+}
+
+// comparisonType works out how a field and value compare. Columns and
+// declared attributes have fixed types; other attributes take the type of
+// the value they are compared with. The value is converted where a string
+// stands for a time or a number stands for a string.
+func (c *compiler) comparisonType(cmp comparison, v value) (columnType, value, error) {
+	typ, declared := columns[cmp.field.name].typ, true
+	if cmp.field.name == "attributes" {
+		typ, declared = c.attributeType(cmp.field)
+	}
+	if !declared {
+		switch v.kind {
+		case valueNumber:
+			return typeNumber, v, nil
+		case valueBool:
+			return typeBool, v, nil
+		case valueTime:
+			return typeTime, v, nil
+		default:
+			return typeString, v, nil
+		}
+	}
+
+	switch {
+	case typ == typeString && v.kind == valueString,
+		typ == typeNumber && v.kind == valueNumber,
+		typ == typeBool && v.kind == valueBool,
+		typ == typeTime && v.kind == valueTime:
+		return typ, v, nil
+	case typ == typeString && v.kind == valueNumber && cmp.field.name == "attributes":
+		v.kind = valueString
+		return typ, v, nil
+	case typ == typeTime && v.kind == valueString:
+		t, err := ParseTime(v.text)
+		if err != nil {
+			return 0, v, syntaxError(v.pos, "%s needs a date such as \"2006-01-02\" or a time such as now-30d", cmp.field)
+		}
+		v.kind, v.t = valueTime, t
+		return typ, v, nil
+	default:
+		return 0, v, syntaxError(v.pos, "%s is a %s field", cmp.field, typ)
+	}
+}
+
+// attributeType returns the declared type of a top-level attribute.
+func (c *compiler) attributeType(f field) (columnType, bool) {
+	if len(f.path) != 1 {
+		return typeString, false
+	}
+	typ, ok := declaredTypes[c.types[f.path[0]]]
+	return typ, ok
+}
+
+var timeRegexp = regexp.MustCompile(timePattern)
+
+// ParseTime parses the date and time formats accepted in segment queries,
+// limited to those timePattern lets PostgreSQL cast.
+func ParseTime(text string) (time.Time, error) {
+	if timeRegexp.MatchString(text) {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", text)
+}
+
+// fieldText returns the field as text: the column, or the attribute as
+// extracted with ->> (NULL when missing or JSON null).
+func (c *compiler) fieldText(f field) string {
+	if f.name != "attributes" {
+		return columns[f.name].sql
+	}
+
+	var sql strings.Builder
+	sql.WriteString("contacts.attributes")
+	for i, key := range f.path {
+		if i == len(f.path)-1 {
+			sql.WriteString("->>?::text")
+		} else {
+			sql.WriteString("->?::text")
+		}
+		c.args = append(c.args, key)
+	}
+	return "(" + sql.String() + ")"
+}
+
+// fieldExpr returns the field converted to typ for comparison.
+func (c *compiler) fieldExpr(f field, typ columnType) string {
+	if f.name != "attributes" {
+		return columns[f.name].sql
+	}
+
+	switch typ {
+	case typeNumber:
+		text := c.fieldText(f)
+		c.args = append(c.args, numberPattern)
+		cast := c.fieldText(f)
+		return "(CASE WHEN " + text + " ~ ? THEN " + cast + "::numeric END)"
+	case typeTime:
+		text := c.fieldText(f)
+		c.args = append(c.args, timePattern)
+		cast := c.fieldText(f)
+		return "(CASE WHEN " + text + " ~ ? THEN " + cast + "::timestamptz END)"
+	case typeBool:
+		return "lower(" + c.fieldText(f) + ")"
+	default:
+		return c.fieldText(f)
+	}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
@@ -0,0 +1,234 @@
+package segment
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+)
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+const (
+	attributeText    = "(contacts.attributes->>?::text)"
+	attributeNumber  = "(CASE WHEN " + attributeText + " ~ ? THEN " + attributeText + "::numeric END)"
+	attributeTime    = "(CASE WHEN " + attributeText + " ~ ? THEN " + attributeText + "::timestamptz END)"
+	listByID         = "contacts.id IN (SELECT list_contacts.contact_id FROM list_contacts WHERE list_contacts.list_id = ? AND list_contacts.status = 'subscribed')"
+	listByName       = "contacts.id IN (SELECT list_contacts.contact_id FROM list_contacts JOIN lists ON lists.id = list_contacts.list_id WHERE lists.name = ? AND lists.deleted_at IS NULL AND list_contacts.status = 'subscribed')"
+	campaignByName   = "contacts.id IN (SELECT campaign_audiences.contact_id FROM campaign_audiences JOIN campaigns ON campaigns.id = campaign_audiences.campaign_id WHERE campaigns.name = ? AND campaigns.deleted_at IS NULL)"
+	testDeclaredDate = "renewal"
+)
+
+var testTypes = AttributeTypes{
+	"age":            models.AttributeTypeNumber,
+	"vip":            models.AttributeTypeBoolean,
+	"zip":            models.AttributeTypeString,
+	testDeclaredDate: models.AttributeTypeDate,
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		query string
+		sql   string
+		args  []interface{}
+	}{
+		{`email = "a@example.com"`, "COALESCE(contacts.email = ?, FALSE)", []interface{}{"a@example.com"}},
+		{`status != 'unsubscribed'`, "(NOT COALESCE(contacts.status = ?, FALSE))", []interface{}{"unsubscribed"}},
+		{`status <> "bounced"`, "(NOT COALESCE(contacts.status = ?, FALSE))", []interface{}{"bounced"}},
+		{`id > 10`, "COALESCE(contacts.id > ?::numeric, FALSE)", []interface{}{"10"}},
+		{`id >= -5.5`, "COALESCE(contacts.id >= ?::numeric, FALSE)", []interface{}{"-5.5"}},
+		{`unsubscribed = false`, "COALESCE(contacts.un_subscribe = ?, FALSE)", []interface{}{false}},
+		{`created_at > now-30d`, "COALESCE(contacts.created_at > ?, FALSE)", []interface{}{testNow.AddDate(0, 0, -30)}},
+		{`updated_at <= now+2h`, "COALESCE(contacts.updated_at <= ?, FALSE)", []interface{}{testNow.Add(2 * time.Hour)}},
+		{`created_at < "2024-02-29"`, "COALESCE(contacts.created_at < ?, FALSE)", []interface{}{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)}},
+		{`first_name contains "an_%"`, "COALESCE(contacts.first_name ILIKE ?, FALSE)", []interface{}{`%an\_\%%`}},
+		{`last_name is null`, "contacts.last_name IS NULL", nil},
+		{`last_name IS NOT NULL`, "contacts.last_name IS NOT NULL", nil},
+		{`status in ("active", "bounced")`, "(COALESCE(contacts.status = ?, FALSE) OR COALESCE(contacts.status = ?, FALSE))", []interface{}{"active", "bounced"}},
+
+		// Undeclared attributes take the type of the value.
+		{`attributes.plan = "pro"`, "COALESCE(" + attributeText + " = ?, FALSE)", []interface{}{"plan", "pro"}},
+		{`attributes.address.city = "Oslo"`, "COALESCE((contacts.attributes->?::text->>?::text) = ?, FALSE)", []interface{}{"address", "city", "Oslo"}},
+		{`attributes.score >= 18`, "COALESCE(" + attributeNumber + " >= ?::numeric, FALSE)", []interface{}{"score", numberPattern, "score", "18"}},
+		{`attributes.trial = true`, "COALESCE(lower(" + attributeText + ") = ?, FALSE)", []interface{}{"trial", "true"}},
+		{`attributes.seen > now`, "COALESCE(" + attributeTime + " > ?, FALSE)", []interface{}{"seen", timePattern, "seen", testNow}},
+		{`attributes.plan is null`, attributeText + " IS NULL", []interface{}{"plan"}},
+		{`attributes.note contains "x"`, "COALESCE(" + attributeText + " ILIKE ?, FALSE)", []interface{}{"note", "%x%"}},
+
+		// Declared attributes are compared as their type.
+		{`attributes.age < 30`, "COALESCE(" + attributeNumber + " < ?::numeric, FALSE)", []interface{}{"age", numberPattern, "age", "30"}},
+		{`attributes.vip = false`, "COALESCE(lower(" + attributeText + ") = ?, FALSE)", []interface{}{"vip", "false"}},
+		{`attributes.zip = 1234`, "COALESCE(" + attributeText + " = ?, FALSE)", []interface{}{"zip", "1234"}},
+		{`attributes.renewal < "2025-01-01T10:00:00Z"`, "COALESCE(" + attributeTime + " < ?, FALSE)",
+			[]interface{}{testDeclaredDate, timePattern, testDeclaredDate, time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)}},
+
+		{`list:3`, listByID, []interface{}{uint(3)}},
+		{`list:news`, listByName, []interface{}{"news"}},
+		{`Campaign:"Spring sale"`, campaignByName, []interface{}{"Spring sale"}},
+
+		{`email = "a" or id = 1 and list:2`,
+			"(COALESCE(contacts.email = ?, FALSE) OR (COALESCE(contacts.id = ?::numeric, FALSE) AND " + listByID + "))",
+			[]interface{}{"a", "1", uint(2)}},
+		{`NOT (email = "a" OR id = 1) AND list:news`,
+			"((NOT (COALESCE(contacts.email = ?, FALSE) OR COALESCE(contacts.id = ?::numeric, FALSE))) AND " + listByName + ")",
+			[]interface{}{"a", "1", "news"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := compileAt(tt.query, testTypes, testNow)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if query.SQL != tt.sql {
+				t.Errorf("SQL:\n got %s\nwant %s", query.SQL, tt.sql)
+			}
+			if !reflect.DeepEqual(query.Args, tt.args) {
+				t.Errorf("Args:\n got %#v\nwant %#v", query.Args, tt.args)
+			}
+			if got := strings.Count(query.SQL, "?"); got != len(query.Args) {
+				t.Errorf("%d placeholders for %d args", got, len(query.Args))
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		// Syntax
+		{``, 1, "query is empty"},
+		{`email = `, 9, "expected a value but found end of query"},
+		{`email == "a"`, 8, `expected a value but found "="`},
+		{`email ! "a"`, 7, `unexpected "!", did you mean "!="?`},
+		{`email = "a`, 9, "unterminated string"},
+		{`email = "a" #`, 13, "unexpected character '#'"},
+		{`email = "a" extra`, 13, `unexpected "extra"`},
+		{`(email = "a"`, 13, `expected ")" but found end of query`},
+		{`email "a"`, 7, `expected an operator after "email" but found string "a"`},
+		{`email is "a"`, 10, `expected NULL but found string "a"`},
+		{`status in "a"`, 11, `expected "(" after IN but found string "a"`},
+		{`status in ("a" "b")`, 16, `expected "," or ")" but found string "b"`},
+		{`or email = "a"`, 1, `unknown field "or"`},
+		{`) email = "a"`, 1, `expected a condition but found ")"`},
+		{`12abc = 1`, 1, `invalid number "12a"`},
+		{`nickname = "a"`, 1, `unknown field "nickname"`},
+		{`attributes..plan = "a"`, 1, `invalid attribute "attributes..plan"`},
+		{`group:1`, 1, `unknown membership "group", expected list or campaign`},
+		{`list:0`, 6, `invalid list ID "0"`},
+		{`list:""`, 6, "list name is empty"},
+		{`campaign:(`, 10, `expected a campaign name or ID but found "("`},
+		{`created_at > now-30`, 18, `expected a duration such as 30d but found "30"`},
+		{`created_at > now-99999d`, 18, `invalid duration "99999d"`},
+		{strings.Repeat("(", 40) + `email = "a"` + strings.Repeat(")", 40), 33, "query is nested too deeply"},
+		{strings.Repeat(`id = 1 or `, 200) + `id = 1`, 2001, "query has more than 200 conditions"},
+		{strings.Repeat(" ", MaxLength) + `id = 1`, MaxLength + 1, "query is longer than 4096 characters"},
+
+		// Operators and types
+		{`id = "ten"`, 6, "id is a number field"},
+		{`email = true`, 9, "email is a string field"},
+		{`unsubscribed = "yes"`, 16, "unsubscribed is a boolean field"},
+		{`created_at > 5`, 14, "created_at is a time field"},
+		{`unsubscribed > true`, 16, "booleans can only be compared with = or !="},
+		{`attributes.trial >= false`, 21, "booleans can only be compared with = or !="},
+		{`id contains 1`, 1, "CONTAINS needs a string field and value"},
+		{`attributes.note contains 5`, 1, "CONTAINS needs a string field and value"},
+		{`attributes.age = "old"`, 18, "attributes.age is a number field"},
+		{`attributes.vip = 1`, 18, "attributes.vip is a boolean field"},
+		{`status in ("a", 2)`, 17, "status is a string field"},
+
+		// Dates are checked when the segment is compiled, not by PostgreSQL.
+		{`created_at > "2024-02-30"`, 14, `created_at needs a date such as "2006-01-02" or a time such as now-30d`},
+		{`created_at > "2023-02-29"`, 14, `created_at needs a date such as "2006-01-02" or a time such as now-30d`},
+		{`attributes.renewal < "2025-13-01"`, 22, `attributes.renewal needs a date such as "2006-01-02" or a time such as now-30d`},
+		{`created_at > "2024-06-01T10:00:00+16:00"`, 14, `created_at needs a date such as "2006-01-02" or a time such as now-30d`},
+	}
+	for _, tt := range tests {
+		name := tt.query
+		if len(name) > 40 {
+			name = name[:40]
+		}
+		t.Run(name, func(t *testing.T) {
+			_, err := compileAt(tt.query, testTypes, testNow)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("err = %v, want a *SyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+				t.Errorf("got %q at %d, want %q at %d", syntaxErr.Msg, syntaxErr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+// An attribute only reaches the ::timestamptz cast when it matches
+// timePattern, so the pattern must not let through anything PostgreSQL
+// would reject, and everything it lets through must parse with time.Parse.
+func TestTimePattern(t *testing.T) {
+	pattern := regexp.MustCompile(timePattern)
+	// ParseTime would reject these if timePattern did not.
+	tests := []struct {
+		text  string
+		valid bool
+	}{
+		{"2024-06-01", true},
+		{"2024-02-29", true},
+		{"2000-02-29", true},
+		{"0400-02-29", true},
+		{"0004-02-29", true},
+		{"2023-12-31", true},
+		{"2023-04-30", true},
+		{"0001-01-01", true},
+		{"9999-12-31", true},
+		{"2024-06-01T10:20:30", true},
+		{"2024-06-01 10:20:30", true},
+		{"2024-06-01 10:20:30.5", true},
+		{"2024-06-01T10:20:30Z", true},
+		{"2024-06-01T00:00:00.123456+02:00", true},
+		{"2024-06-01T23:59:59-15:59", true},
+
+		{"2024-02-30", false},
+		{"2023-02-29", false},
+		{"1900-02-29", false},
+		{"0000-02-29", false},
+		{"2024-04-31", false},
+		{"2024-11-31", false},
+		{"2024-13-01", false},
+		{"2024-00-10", false},
+		{"2024-01-00", false},
+		{"2024-01-32", false},
+		{"0000-01-01", false},
+		{"2024-6-1", false},
+		{"24-06-01", false},
+		{"20240601", false},
+		{"２０２４-06-01", false},
+		{"2024-06-01T24:00:00", false},
+		{"2024-06-01T10:60:00", false},
+		{"2024-06-01T10:20:60", false},
+		{"2024-06-01T10:20", false},
+		{"2024-06-01T10:20:30+16:00", false},
+		{"2024-06-01T10:20:30+02:60", false},
+		{"2024-06-01T10:20:30+0200", false},
+		{"2024-06-01T10:20:30+02", false},
+		{"2024-06-01T10:20:30z", false},
+		{"2024-06-01 10:20:30Z", false},
+		{" 2024-06-01", false},
+		{"2024-06-01\n", false},
+		{"", false},
+		{"yesterday", false},
+	}
+	for _, tt := range tests {
+		if got := pattern.MatchString(tt.text); got != tt.valid {
+			t.Errorf("timePattern matches %q = %v, want %v", tt.text, got, tt.valid)
+		}
+		if _, err := ParseTime(tt.text); (err == nil) != tt.valid {
+			t.Errorf("ParseTime(%q) err = %v, want valid %v", tt.text, err, tt.valid)
+		}
+	}
+}
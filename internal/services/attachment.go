@@ -0,0 +1,168 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+)
+
+const (
+	AttachmentSourceUpload  = "upload"
+	AttachmentSourceStorage = "storage"
+)
+
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// AttachmentInput carries either base64 Content or a Path relative to the
+// configured attachment storage directory.
+type AttachmentInput struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+	Path        string `json:"path"`
+	ContentID   string `json:"content_id"`
+}
+
+type attachmentLoader struct {
+	config config.AttachmentConfig
+	total  int64
+}
+
+func (l *attachmentLoader) load(input AttachmentInput, inline bool) (email.Attachment, models.EmailLogAttachment, error) {
+	var attachment email.Attachment
+	var record models.EmailLogAttachment
+
+	if (input.Content == "") == (input.Path == "") {
+		return attachment, record, fmt.Errorf("%w: exactly one of content or path is required", ErrInvalidAttachment)
+	}
+	if inline && input.ContentID == "" {
+		return attachment, record, fmt.Errorf("%w: inline images require a content_id", ErrInvalidAttachment)
+	}
+
+	filename := input.Filename
+	source := AttachmentSourceUpload
+	var content []byte
+	var err error
+	if input.Content != "" {
+		if l.config.MaxSize > 0 && int64(base64.StdEncoding.DecodedLen(len(input.Content))) > l.config.MaxSize+2 {
+			return attachment, record, fmt.Errorf("%w: %s exceeds the maximum size of %d bytes", ErrInvalidAttachment, filename, l.config.MaxSize)
+		}
+		content, err = base64.StdEncoding.DecodeString(input.Content)
+		if err != nil {
+			return attachment, record, fmt.Errorf("%w: %s is not valid base64", ErrInvalidAttachment, filename)
+		}
+	} else {
+		source = AttachmentSourceStorage
+		content, err = l.readStored(input.Path)
+		if err != nil {
+			return attachment, record, err
+		}
+		if filename == "" {
+			filename = path.Base(input.Path)
+		}
+	}
+
+	if filename == "" {
+		return attachment, record, fmt.Errorf("%w: filename is required", ErrInvalidAttachment)
+	}
+
+	size := int64(len(content))
+	if l.config.MaxSize > 0 && size > l.config.MaxSize {
+		return attachment, record, fmt.Errorf("%w: %s exceeds the maximum size of %d bytes", ErrInvalidAttachment, filename, l.config.MaxSize)
+	}
+	l.total += size
+	if l.config.MaxTotalSize > 0 && l.total > l.config.MaxTotalSize {
+		return attachment, record, fmt.Errorf("%w: attachments exceed the maximum total size of %d bytes", ErrInvalidAttachment, l.config.MaxTotalSize)
+	}
+
+	contentType, err := l.contentType(input.ContentType, filename, content)
+	if err != nil {
+		return attachment, record, err
+	}
+
+	attachment = email.Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		ContentID:   input.ContentID,
+		Content:     content,
+	}
+	record = models.EmailLogAttachment{
+		Filename:    filename,
+		ContentType: contentType,
+		ContentID:   input.ContentID,
+		Size:        size,
+		Inline:      inline,
+		Source:      source,
+	}
+	return attachment, record, nil
+}
+
+func (l *attachmentLoader) readStored(name string) ([]byte, error) {
+	if l.config.StorageDir == "" {
+		return nil, fmt.Errorf("%w: attachment storage is not configured", ErrInvalidAttachment)
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return nil, fmt.Errorf("%w: invalid path %s", ErrInvalidAttachment, name)
+		}
+	}
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" {
+		return nil, fmt.Errorf("%w: invalid path %s", ErrInvalidAttachment, name)
+	}
+	fullPath := filepath.Join(l.config.StorageDir, filepath.FromSlash(cleaned))
+
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		return nil, fmt.Errorf("%w: stored file %s not found", ErrInvalidAttachment, name)
+	}
+	if l.config.MaxSize > 0 && info.Size() > l.config.MaxSize {
+		return nil, fmt.Errorf("%w: %s exceeds the maximum size of %d bytes", ErrInvalidAttachment, name, l.config.MaxSize)
+	}
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stored attachment: %w", err)
+	}
+	return content, nil
+}
+
+func (l *attachmentLoader) contentType(declared, filename string, content []byte) (string, error) {
+	contentType := declared
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid content type %s", ErrInvalidAttachment, contentType)
+	}
+
+	if len(l.config.AllowedTypes) == 0 {
+		return mediaType, nil
+	}
+	for _, allowed := range l.config.AllowedTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == mediaType {
+			return mediaType, nil
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return mediaType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: content type %s is not allowed", ErrInvalidAttachment, mediaType)
+}
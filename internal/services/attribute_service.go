@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/internal/segment"
+	"gorm.io/gorm"
+)
+
+// attributeSchemaTTL is how long the mail service reuses a loaded schema
+// before reading the definitions again.
+const attributeSchemaTTL = time.Minute
+
+var (
+	ErrAttributeNotFound = errors.New("attribute not found")
+	ErrInvalidAttribute  = errors.New("invalid attribute")
+)
+
+// Keys must be usable as attributes.<key> in segment queries.
+var attributeKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,99}$`)
+
+type AttributeService struct {
+	repo *repositories.AttributeRepository
+}
+
+func NewAttributeService(db *gorm.DB) *AttributeService {
+	return &AttributeService{
+		repo: repositories.NewAttributeRepository(db),
+	}
+}
+
+func (s *AttributeService) CreateDefinition(definition *models.AttributeDefinition) (*models.AttributeDefinition, error) {
+	definition.Key = strings.TrimSpace(definition.Key)
+	if !attributeKeyPattern.MatchString(definition.Key) {
+		return nil, fmt.Errorf("%w: key must start with a letter or underscore and contain only letters, digits and underscores", ErrInvalidAttribute)
+	}
+	if err := validateDefinition(definition); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetDefinitionByKey(definition.Key)
+	if err != nil {
+		return nil, err
+	}
+	if existing.ID != 0 {
+		return nil, fmt.Errorf("%w: attribute %q is already defined", ErrInvalidAttribute, definition.Key)
+	}
+
+	createdDefinition, err := s.repo.CreateDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	return &createdDefinition, nil
+}
+
+func (s *AttributeService) GetAllDefinitions() ([]models.AttributeDefinition, error) {
+	definitions, err := s.repo.GetAllDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (s *AttributeService) GetDefinitionByID(id uint) (*models.AttributeDefinition, error) {
+	definition, err := s.repo.GetDefinitionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if definition.ID == 0 {
+		return nil, ErrAttributeNotFound
+	}
+	return definition, nil
+}
+
+// UpdateDefinition changes everything but the key. Stored values are not
+// rewritten, so a type change applies to contacts as they are next saved.
+func (s *AttributeService) UpdateDefinition(id uint, definition *models.AttributeDefinition) (*models.AttributeDefinition, error) {
+	existingDefinition, err := s.GetDefinitionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateDefinition(definition); err != nil {
+		return nil, err
+	}
+
+	existingDefinition.Type = definition.Type
+	existingDefinition.Required = definition.Required
+	existingDefinition.Default = definition.Default
+	existingDefinition.Description = definition.Description
+	existingDefinition.UpdatedAt = time.Now()
+
+	updatedDefinition, err := s.repo.UpdateDefinition(existingDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return &updatedDefinition, nil
+}
+
+func (s *AttributeService) DeleteDefinition(id uint) error {
+	if _, err := s.GetDefinitionByID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteDefinition(id)
+}
+
+func validateDefinition(definition *models.AttributeDefinition) error {
+	definition.Type = strings.ToLower(strings.TrimSpace(definition.Type))
+	switch definition.Type {
+	case models.AttributeTypeString, models.AttributeTypeNumber, models.AttributeTypeBoolean, models.AttributeTypeDate:
+	default:
+		return fmt.Errorf("%w: type must be string, number, boolean or date", ErrInvalidAttribute)
+	}
+
+	value, err := coerceAttribute(definition.Type, definition.Default.Data)
+	if err != nil {
+		return fmt.Errorf("%w: default %v", ErrInvalidAttribute, err)
+	}
+	definition.Default.Data = value
+	return nil
+}
+
+// attributeSchema holds the attribute definitions by key.
+type attributeSchema map[string]models.AttributeDefinition
+
+func loadAttributeSchema(repo *repositories.AttributeRepository) (attributeSchema, error) {
+	definitions, err := repo.GetAllDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("error loading attribute definitions: %w", err)
+	}
+
+	schema := make(attributeSchema, len(definitions))
+	for _, definition := range definitions {
+		schema[definition.Key] = definition
+	}
+	return schema, nil
+}
+
+// coerce converts the defined attributes present in attributes to their
+// type, in place. Empty values are removed so defaults can apply.
+func (schema attributeSchema) coerce(attributes models.JSONMap) error {
+	for key, definition := range schema {
+		value, ok := attributes[key]
+		if !ok {
+			continue
+		}
+		converted, err := coerceAttribute(definition.Type, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s %v", ErrInvalidAttribute, key, err)
+		}
+		if converted == nil {
+			delete(attributes, key)
+			continue
+		}
+		attributes[key] = converted
+	}
+	return nil
+}
+
+// complete fills in defaults for missing attributes and fails when a
+// required one is still missing.
+func (schema attributeSchema) complete(attributes models.JSONMap) error {
+	for key, definition := range schema {
+		if _, ok := attributes[key]; ok {
+			continue
+		}
+		if definition.Default.Data != nil {
+			attributes[key] = definition.Default.Data
+			continue
+		}
+		if definition.Required {
+			return fmt.Errorf("%w: %s is required", ErrInvalidAttribute, key)
+		}
+	}
+	return nil
+}
+
+// validate coerces and completes the attributes of a new or replaced set.
+func (schema attributeSchema) validate(attributes models.JSONMap) error {
+	if err := schema.coerce(attributes); err != nil {
+		return err
+	}
+	return schema.complete(attributes)
+}
+
+// withDefaults returns a copy of attributes with defaults filled in, for
+// contacts saved before an attribute was defined.
+func (schema attributeSchema) withDefaults(attributes models.JSONMap) models.JSONMap {
+	result := make(models.JSONMap, len(attributes)+len(schema))
+	for key, value := range attributes {
+		result[key] = value
+	}
+	for key, definition := range schema {
+		if _, ok := result[key]; !ok && definition.Default.Data != nil {
+			result[key] = definition.Default.Data
+		}
+	}
+	return result
+}
+
+// types returns the declared types for typed segment comparisons.
+func (schema attributeSchema) types() segment.AttributeTypes {
+	types := make(segment.AttributeTypes, len(schema))
+	for key, definition := range schema {
+		types[key] = definition.Type
+	}
+	return types
+}
+
+// coerceAttribute converts value to the attribute type. Strings are parsed,
+// as CSV imports only produce strings. nil and blank strings become nil.
+func coerceAttribute(attributeType string, value interface{}) (interface{}, error) {
+	if text, ok := value.(string); ok {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, nil
+		}
+		if attributeType != models.AttributeTypeString {
+			value = text
+		}
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch attributeType {
+	case models.AttributeTypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		return nil, errors.New("must be a string")
+	case models.AttributeTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			number, err := strconv.ParseFloat(v, 64)
+			if err == nil && !math.IsInf(number, 0) && !math.IsNaN(number) {
+				return number, nil
+			}
+		}
+		return nil, errors.New("must be a number")
+	case models.AttributeTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(v) {
+			case "true", "t", "yes", "y", "1":
+				return true, nil
+			case "false", "f", "no", "n", "0":
+				return false, nil
+			}
+		}
+		return nil, errors.New("must be a boolean")
+	case models.AttributeTypeDate:
+		if v, ok := value.(string); ok {
+			t, err := segment.ParseTime(v)
+			if err == nil {
+				// Dates stay dates; anything with a time is stored in UTC.
+				if len(v) == len("2006-01-02") {
+					return t.Format("2006-01-02"), nil
+				}
+				return t.UTC().Format(time.RFC3339), nil
+			}
+		}
+		return nil, errors.New("must be a date such as 2006-01-02 or an RFC 3339 time")
+	default:
+		return value, nil
+	}
+}
+
+// attributeSchemaCache keeps a recently loaded schema for the send path,
+// where loading definitions for every message would cost a query each.
+type attributeSchemaCache struct {
+	repo     *repositories.AttributeRepository
+	mutex    sync.Mutex
+	schema   attributeSchema
+	loadedAt time.Time
+}
+
+func (c *attributeSchemaCache) get() attributeSchema {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.schema != nil && time.Since(c.loadedAt) < attributeSchemaTTL {
+		return c.schema
+	}
+	schema, err := loadAttributeSchema(c.repo)
+	if err != nil {
+		// Keep sending with the last known schema rather than failing mail.
+		log.Printf("Error refreshing attribute schema: %v\n", err)
+		return c.schema
+	}
+	c.schema = schema
+	c.loadedAt = time.Now()
+	return schema
+}
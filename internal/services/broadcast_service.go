@@ -1,6 +1,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
 	"time"
 
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
@@ -8,17 +12,47 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrBroadcastNotFound    = errors.New("broadcast not found")
+	ErrInvalidBroadcast     = errors.New("invalid broadcast")
+	ErrBroadcastNotSendable = errors.New("broadcast has already been sent or is sending")
+	ErrBroadcastNotEditable = errors.New("only draft and scheduled broadcasts can be changed")
+)
+
 type BroadcastService struct {
-	repo repositories.BroadcastRepository
+	db            *gorm.DB
+	repo          repositories.BroadcastRepository
+	contactRepo   *repositories.ContactRepository
+	listRepo      *repositories.ListRepository
+	segmentRepo   *repositories.SegmentRepository
+	attributeRepo *repositories.AttributeRepository
+	jobRepo       *repositories.EmailJobRepository
 }
 
 func NewBroadcastService(db *gorm.DB) *BroadcastService {
 	return &BroadcastService{
-		repo: *repositories.NewBroadcastRepository(db),
+		db:            db,
+		repo:          *repositories.NewBroadcastRepository(db),
+		contactRepo:   repositories.NewContactRepository(db),
+		listRepo:      repositories.NewListRepository(db),
+		segmentRepo:   repositories.NewSegmentRepository(db),
+		attributeRepo: repositories.NewAttributeRepository(db),
+		jobRepo:       repositories.NewEmailJobRepository(db),
 	}
 }
 
 func (s *BroadcastService) CreateBroadcast(broadcast *models.Broadcast) (*models.Broadcast, error) {
+	lists, err := resolveLists(s.listRepo, broadcast.ListIDs)
+	if err != nil {
+		return nil, err
+	}
+	broadcast.Lists = lists
+	broadcast.ListIDs = models.ListIDs(lists)
+	if broadcast.SegmentID, err = resolveSegment(s.segmentRepo, broadcast.SegmentID); err != nil {
+		return nil, err
+	}
+	broadcast.Segment = nil
+
 	createdBroadcast, err := s.repo.CreateBroadcast(broadcast)
 	if err != nil {
 		return nil, err
@@ -34,14 +68,40 @@ func (s *BroadcastService) GetBroadcastByID(id uint) (*models.Broadcast, error)
 	return broadcast, nil
 }
 
+// UpdateBroadcast changes a draft or scheduled broadcast. Its status and
+// sent time only change as it is sent.
 func (s *BroadcastService) UpdateBroadcast(id uint, broadcast *models.Broadcast) (*models.Broadcast, error) {
 	existingBoradcast, err := s.repo.GetBroadcastByID(id)
 	if err != nil {
 		return nil, err
 	}
+	if existingBoradcast.ID == 0 {
+		return nil, ErrBroadcastNotFound
+	}
+	if existingBoradcast.Status != models.BroadcastStatusDraft && existingBoradcast.Status != models.BroadcastStatusScheduled {
+		return nil, ErrBroadcastNotEditable
+	}
+	// A scheduled broadcast is only picked up once its time has come.
+	if existingBoradcast.Status == models.BroadcastStatusScheduled && broadcast.ScheduledAt == nil {
+		return nil, fmt.Errorf("%w: a scheduled broadcast needs scheduled_at", ErrInvalidBroadcast)
+	}
+
+	// Omitting list_ids keeps the current lists; an empty array clears them.
+	var lists []models.List
+	if broadcast.ListIDs != nil {
+		lists, err = resolveLists(s.listRepo, broadcast.ListIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	segmentID, err := resolveSegment(s.segmentRepo, broadcast.SegmentID)
+	if err != nil {
+		return nil, err
+	}
 
 	existingBoradcast.Name = broadcast.Name
 	existingBoradcast.AudienceID = broadcast.AudienceID
+	existingBoradcast.SegmentID = segmentID
 	existingBoradcast.CampaignID = broadcast.CampaignID
 	existingBoradcast.UserID = broadcast.UserID
 	existingBoradcast.From = broadcast.From
@@ -49,26 +109,214 @@ func (s *BroadcastService) UpdateBroadcast(id uint, broadcast *models.Broadcast)
 	existingBoradcast.ReplyTo = broadcast.ReplyTo
 	existingBoradcast.HTML = broadcast.HTML
 	existingBoradcast.Text = broadcast.Text
-	existingBoradcast.Status = broadcast.Status
-	existingBoradcast.SentAt = broadcast.SentAt
-	existingBoradcast.Campaign = broadcast.Campaign
-	existingBoradcast.User = broadcast.User
 	existingBoradcast.ScheduledAt = broadcast.ScheduledAt
 	existingBoradcast.UpdatedAt = time.Now()
 
-	updatedBroadcast, err := s.repo.UpdateBroadcast(existingBoradcast)
+	// The broadcast may have been sent since it was read.
+	updated, err := s.repo.UpdateBroadcast(existingBoradcast)
 	if err != nil {
 		return nil, err
 	}
-	return updatedBroadcast, nil
+	if !updated {
+		return nil, ErrBroadcastNotEditable
+	}
+	if broadcast.ListIDs != nil {
+		if err = s.repo.ReplaceLists(existingBoradcast, lists); err != nil {
+			return nil, err
+		}
+	}
+	return existingBoradcast, nil
 }
 
-func (s *BroadcastService) SendBroadcast(broadcast_id, scheduled_at string) (id string, error error) {
-	broadcast, err := s.repo.SendBroadcast(broadcast_id, scheduled_at)
+// SendBroadcast queues the broadcast right away, or schedules it when
+// scheduledAt is in the future; the scheduler queues due broadcasts.
+func (s *BroadcastService) SendBroadcast(id uint, scheduledAt *time.Time) (*models.Broadcast, error) {
+	broadcast, err := s.repo.GetBroadcastByID(id)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return broadcast, nil
+	if broadcast.ID == 0 {
+		return nil, ErrBroadcastNotFound
+	}
+	if err = validateBroadcast(broadcast); err != nil {
+		return nil, err
+	}
+	if broadcast.Status != models.BroadcastStatusDraft && broadcast.Status != models.BroadcastStatusScheduled {
+		return nil, ErrBroadcastNotSendable
+	}
+
+	now := time.Now()
+	if scheduledAt != nil && scheduledAt.After(now) {
+		err = s.repo.UpdateStatus(id, map[string]interface{}{
+			"status":         models.BroadcastStatusScheduled,
+			"scheduled_at":   *scheduledAt,
+			"status_message": "",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s.repo.GetBroadcastByID(id)
+	}
+
+	if err = s.repo.UpdateStatus(id, map[string]interface{}{"scheduled_at": now}); err != nil {
+		return nil, err
+	}
+	if err = s.QueueBroadcast(broadcast); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBroadcastByID(id)
+}
+
+// QueueBroadcast enqueues one EmailJob per subscribed contact in the
+// broadcast's audience and moves it to sending in the same transaction, so it
+// is never sending without its jobs. The status transition is conditional so
+// the API and the scheduler cannot both queue the same broadcast.
+func (s *BroadcastService) QueueBroadcast(broadcast *models.Broadcast) error {
+	seg, err := compileAudienceSegment(s.segmentRepo, s.attributeRepo, broadcast.SegmentID)
+	if err != nil {
+		s.returnToDraft(broadcast.ID, err)
+		return fmt.Errorf("error compiling broadcast segment: %w", err)
+	}
+
+	contacts, err := s.contactRepo.GetAudienceContacts(broadcast.AudienceID, models.ListIDs(broadcast.Lists), seg)
+	if err != nil {
+		s.returnToDraft(broadcast.ID, err)
+		return fmt.Errorf("error resolving broadcast audience: %w", err)
+	}
+
+	now := time.Now()
+	jobs := make([]models.EmailJob, len(contacts))
+	for i := range contacts {
+		jobs[i] = models.EmailJob{
+			BroadcastID: &broadcast.ID,
+			ContactID:   &contacts[i].ID,
+			Status:      models.EmailJobStatusQueued,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewBroadcastRepository(tx)
+		fields := map[string]interface{}{
+			"status":         models.BroadcastStatusSending,
+			"status_message": fmt.Sprintf("Queued %d emails", len(jobs)),
+		}
+		if len(jobs) == 0 {
+			fields = map[string]interface{}{
+				"status":         models.BroadcastStatusSent,
+				"sent_at":        now,
+				"status_message": "No contacts to send to",
+			}
+		}
+		ok, err := repo.UpdateStatusFrom(broadcast.ID,
+			[]string{models.BroadcastStatusDraft, models.BroadcastStatusScheduled}, fields)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrBroadcastNotSendable
+		}
+		if err = repositories.NewEmailJobRepository(tx).CreateJobs(jobs); err != nil {
+			return fmt.Errorf("error creating email jobs: %w", err)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrBroadcastNotSendable) {
+		s.returnToDraft(broadcast.ID, err)
+	}
+	return err
+}
+
+// returnToDraft records why a broadcast could not be queued, moving a
+// scheduled broadcast back to draft so that it is not retried.
+func (s *BroadcastService) returnToDraft(id uint, cause error) {
+	_, err := s.repo.UpdateStatusFrom(id,
+		[]string{models.BroadcastStatusDraft, models.BroadcastStatusScheduled},
+		map[string]interface{}{
+			"status":         models.BroadcastStatusDraft,
+			"status_message": truncate(cause.Error(), 255),
+		})
+	if err != nil {
+		log.Printf("Error recording failure of broadcast %d: %v\n", id, err)
+	}
+}
+
+func (s *BroadcastService) QueueDueBroadcasts() {
+	broadcasts, err := s.repo.GetDueBroadcasts()
+	if err != nil {
+		log.Printf("Error getting scheduled broadcasts: %v\n", err)
+		return
+	}
+
+	for i := range broadcasts {
+		if err := s.QueueBroadcast(&broadcasts[i]); err != nil {
+			log.Printf("Error queueing broadcast %d: %v\n", broadcasts[i].ID, err)
+			continue
+		}
+		log.Printf("Queued broadcast: %s (ID: %d)\n", broadcasts[i].Name, broadcasts[i].ID)
+	}
+}
+
+// CompleteBroadcasts marks sending broadcasts as sent once none of their jobs
+// are waiting in the queue or being delivered.
+func (s *BroadcastService) CompleteBroadcasts() {
+	broadcasts, err := s.repo.GetBroadcastsByStatus(models.BroadcastStatusSending)
+	if err != nil {
+		log.Printf("Error getting sending broadcasts: %v\n", err)
+		return
+	}
+
+	for _, broadcast := range broadcasts {
+		counts, err := s.jobRepo.CountBroadcastJobsByStatus(broadcast.ID)
+		if err != nil {
+			log.Printf("Error getting job counts for broadcast %d: %v\n", broadcast.ID, err)
+			continue
+		}
+		if counts[models.EmailJobStatusQueued]+counts[models.EmailJobStatusSending] > 0 {
+			continue
+		}
+
+		// Opened and clicked mail was sent; rejected and bounced mail failed.
+		sent := counts[models.EmailJobStatusSent] + counts[models.EmailJobStatusOpened] + counts[models.EmailJobStatusClicked]
+		failed := counts[models.EmailJobStatusDead] + counts[models.EmailJobStatusRejected] + counts[models.EmailJobStatusBounced]
+		now := time.Now()
+		err = s.repo.UpdateStatus(broadcast.ID, map[string]interface{}{
+			"status":  models.BroadcastStatusSent,
+			"sent_at": now,
+			"status_message": fmt.Sprintf("Completed: %d sent, %d failed, %d skipped",
+				sent, failed, counts[models.EmailJobStatusSkipped]),
+		})
+		if err != nil {
+			log.Printf("Error completing broadcast %d: %v\n", broadcast.ID, err)
+			continue
+		}
+		log.Printf("Broadcast %d marked as sent\n", broadcast.ID)
+	}
+}
+
+func validateBroadcast(broadcast *models.Broadcast) error {
+	if broadcast.From == "" {
+		return fmt.Errorf("%w: from address is required", ErrInvalidBroadcast)
+	}
+	if _, err := mail.ParseAddress(broadcast.From); err != nil {
+		return fmt.Errorf("%w: invalid from address: %v", ErrInvalidBroadcast, err)
+	}
+	if broadcast.ReplyTo != "" {
+		if _, err := mail.ParseAddress(broadcast.ReplyTo); err != nil {
+			return fmt.Errorf("%w: invalid reply-to address: %v", ErrInvalidBroadcast, err)
+		}
+	}
+	if broadcast.Subject == "" {
+		return fmt.Errorf("%w: subject is required", ErrInvalidBroadcast)
+	}
+	if broadcast.HTML == "" && broadcast.Text == "" {
+		return fmt.Errorf("%w: html or text content is required", ErrInvalidBroadcast)
+	}
+	if broadcast.AudienceID == 0 && len(broadcast.Lists) == 0 && broadcast.SegmentID == nil {
+		return fmt.Errorf("%w: audience, lists or a segment are required", ErrInvalidBroadcast)
+	}
+	return nil
 }
 
 func (s *BroadcastService) ListBroadcasts() ([]models.Broadcast, error) {
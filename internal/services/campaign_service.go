@@ -7,16 +7,31 @@ import (
 )
 
 type CampaignService struct {
-	repo repositories.CampaignRepository
+	repo        repositories.CampaignRepository
+	listRepo    *repositories.ListRepository
+	segmentRepo *repositories.SegmentRepository
 }
 
 func NewCampaignService(db *gorm.DB) *CampaignService {
 	return &CampaignService{
-		repo: *repositories.NewCampaignRepository(db),
+		repo:        *repositories.NewCampaignRepository(db),
+		listRepo:    repositories.NewListRepository(db),
+		segmentRepo: repositories.NewSegmentRepository(db),
 	}
 }
 
 func (s *CampaignService) CreateCampaign(campaign *models.Campaign) (*models.Campaign, error) {
+	lists, err := resolveLists(s.listRepo, campaign.ListIDs)
+	if err != nil {
+		return nil, err
+	}
+	campaign.Lists = lists
+	campaign.ListIDs = models.ListIDs(lists)
+	if campaign.SegmentID, err = resolveSegment(s.segmentRepo, campaign.SegmentID); err != nil {
+		return nil, err
+	}
+	campaign.Segment = nil
+
 	createdCampaign, err := s.repo.Create(campaign)
 	if err != nil {
 		return nil, err
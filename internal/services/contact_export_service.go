@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportNotFound = errors.New("export not found")
+	ErrInvalidExport  = errors.New("invalid export")
+	ErrExportNotReady = errors.New("export is not ready")
+	ErrExportExpired  = errors.New("export has expired")
+)
+
+type ContactExportService struct {
+	repo        *repositories.ContactExportRepository
+	contactRepo *repositories.ContactRepository
+	config      config.ExportConfig
+	slots       chan struct{}
+}
+
+func NewContactExportService(db *gorm.DB, cfg config.ExportConfig) *ContactExportService {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	return &ContactExportService{
+		repo:        repositories.NewContactExportRepository(db),
+		contactRepo: repositories.NewContactRepository(db),
+		config:      cfg,
+		slots:       make(chan struct{}, cfg.Concurrency),
+	}
+}
+
+// NormalizeExportFormat maps the accepted format names onto csv or ndjson.
+func NormalizeExportFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", models.ContactExportFormatCSV:
+		return models.ContactExportFormatCSV, nil
+	case models.ContactExportFormatNDJSON, "jsonl", "json":
+		return models.ContactExportFormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("%w: format must be csv or ndjson", ErrInvalidExport)
+	}
+}
+
+func validateContactFilter(filter models.ContactFilter) error {
+	switch filter.Status {
+	case "", models.ContactStatusEnabled, models.ContactStatusBlocklisted:
+		return nil
+	default:
+		return fmt.Errorf("%w: invalid status %q", ErrInvalidExport, filter.Status)
+	}
+}
+
+// WriteContacts streams the contacts matching filter to w. progress runs
+// ahead of every batch with the number of contacts written so far, which
+// lets HTTP handlers push their write deadline and jobs report progress.
+func (s *ContactExportService) WriteContacts(w io.Writer, format string, filter models.ContactFilter, progress func(exported int64) error) (int64, error) {
+	if err := validateContactFilter(filter); err != nil {
+		return 0, err
+	}
+
+	var attributeKeys []string
+	if format == models.ContactExportFormatCSV {
+		keys, err := s.contactRepo.GetAttributeKeys(filter)
+		if err != nil {
+			return 0, fmt.Errorf("error loading attribute keys: %w", err)
+		}
+		sort.Strings(keys)
+		attributeKeys = keys
+	}
+
+	writer, err := newContactExportWriter(format, w, attributeKeys)
+	if err != nil {
+		return 0, err
+	}
+
+	var exported int64
+	err = s.contactRepo.StreamContacts(filter, s.config.BatchSize, func(contacts []models.Contact) error {
+		if progress != nil {
+			if err := progress(exported); err != nil {
+				return err
+			}
+		}
+		if err := writer.write(contacts); err != nil {
+			return err
+		}
+		exported += int64(len(contacts))
+		return writer.flush()
+	})
+	if err != nil {
+		return exported, err
+	}
+	return exported, writer.flush()
+}
+
+func (s *ContactExportService) CreateExport(format string, filter models.ContactFilter, createdBy uint) (*models.ContactExport, error) {
+	format, err := NormalizeExportFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateContactFilter(filter); err != nil {
+		return nil, err
+	}
+
+	contactExport := &models.ContactExport{
+		Format:    format,
+		Filter:    filter,
+		Status:    models.ContactExportStatusPending,
+		CreatedBy: createdBy,
+	}
+	if err = s.repo.CreateExport(contactExport); err != nil {
+		return nil, err
+	}
+
+	go s.run(contactExport.ID)
+	return contactExport, nil
+}
+
+func (s *ContactExportService) GetExport(id uint) (*models.ContactExport, error) {
+	contactExport, err := s.repo.GetExportByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if contactExport.ID == 0 {
+		return nil, ErrExportNotFound
+	}
+	return contactExport, nil
+}
+
+func (s *ContactExportService) ListExports() ([]models.ContactExport, error) {
+	return s.repo.ListExports()
+}
+
+// OpenExport returns the finished file of an export; the caller closes it.
+func (s *ContactExportService) OpenExport(id uint) (*models.ContactExport, *os.File, error) {
+	contactExport, err := s.GetExport(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contactExport.Status != models.ContactExportStatusCompleted {
+		return nil, nil, ErrExportNotReady
+	}
+	if contactExport.FilePath == "" || (contactExport.ExpiresAt != nil && contactExport.ExpiresAt.Before(time.Now())) {
+		return nil, nil, ErrExportExpired
+	}
+
+	file, err := os.Open(contactExport.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrExportExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return contactExport, file, nil
+}
+
+// ResumeExports restarts exports that were pending or running when the
+// server stopped.
+func (s *ContactExportService) ResumeExports() {
+	exports, err := s.repo.GetExportsByStatus([]string{
+		models.ContactExportStatusPending,
+		models.ContactExportStatusRunning,
+	})
+	if err != nil {
+		log.Printf("Error loading unfinished exports: %v\n", err)
+		return
+	}
+
+	for _, contactExport := range exports {
+		go s.run(contactExport.ID)
+	}
+}
+
+func (s *ContactExportService) PurgeExpiredExports() {
+	exports, err := s.repo.GetExpiredExports()
+	if err != nil {
+		log.Printf("Error loading expired exports: %v\n", err)
+		return
+	}
+
+	for _, contactExport := range exports {
+		if err := os.Remove(contactExport.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error removing export %d: %v\n", contactExport.ID, err)
+			continue
+		}
+		if err := s.repo.UpdateExport(contactExport.ID, map[string]interface{}{"file_path": ""}); err != nil {
+			log.Printf("Error updating export %d: %v\n", contactExport.ID, err)
+		}
+	}
+}
+
+func (s *ContactExportService) run(id uint) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	contactExport, err := s.repo.GetExportByID(id)
+	if err != nil || contactExport.ID == 0 {
+		log.Printf("Error loading export %d: %v\n", id, err)
+		return
+	}
+
+	err = s.repo.UpdateExport(id, map[string]interface{}{
+		"status":     models.ContactExportStatusRunning,
+		"started_at": time.Now(),
+		"exported":   0,
+	})
+	if err != nil {
+		log.Printf("Error starting export %d: %v\n", id, err)
+		return
+	}
+
+	path, exported, size, err := s.writeFile(contactExport)
+	fields := map[string]interface{}{
+		"status":       models.ContactExportStatusCompleted,
+		"completed_at": time.Now(),
+		"exported":     exported,
+		"size":         size,
+		"file_path":    path,
+	}
+	if err != nil {
+		log.Printf("Export %d failed: %v\n", id, err)
+		fields["status"] = models.ContactExportStatusFailed
+		fields["status_message"] = truncate(err.Error(), 255)
+	} else if s.config.Retention > 0 {
+		fields["expires_at"] = time.Now().Add(s.config.Retention)
+	}
+	if err = s.repo.UpdateExport(id, fields); err != nil {
+		log.Printf("Error finishing export %d: %v\n", id, err)
+	}
+}
+
+// writeFile writes to a temporary name and renames it once complete, so a
+// download never sees a partial file.
+func (s *ContactExportService) writeFile(contactExport *models.ContactExport) (string, int64, int64, error) {
+	if err := os.MkdirAll(s.config.Dir, 0o750); err != nil {
+		return "", 0, 0, fmt.Errorf("error creating export directory: %w", err)
+	}
+
+	path := filepath.Join(s.config.Dir, fmt.Sprintf("contacts-%d.%s", contactExport.ID, contactExport.Format))
+	partial := path + ".partial"
+	file, err := os.Create(partial)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("error creating export file: %w", err)
+	}
+
+	buffered := bufio.NewWriterSize(file, 64<<10)
+	exported, err := s.WriteContacts(buffered, contactExport.Format, contactExport.Filter, func(exported int64) error {
+		return s.repo.UpdateExport(contactExport.ID, map[string]interface{}{"exported": exported})
+	})
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(partial, path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", exported, 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", exported, 0, err
+	}
+	return path, exported, info.Size(), nil
+}
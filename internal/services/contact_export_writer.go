@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+)
+
+var contactExportColumns = []string{
+	"id", "email", "first_name", "last_name", "status", "unsubscribed", "lists", "created_at", "updated_at",
+}
+
+type contactExportWriter interface {
+	write(contacts []models.Contact) error
+	flush() error
+}
+
+func newContactExportWriter(format string, w io.Writer, attributeKeys []string) (contactExportWriter, error) {
+	switch format {
+	case models.ContactExportFormatCSV:
+		return newCSVContactWriter(w, attributeKeys)
+	case models.ContactExportFormatNDJSON:
+		return &ndjsonContactWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("%w: format must be csv or ndjson", ErrInvalidExport)
+	}
+}
+
+// csvContactWriter writes one column per attribute key, named
+// "attributes.<key>" so an export can be imported again as-is.
+type csvContactWriter struct {
+	writer        *csv.Writer
+	attributeKeys []string
+	record        []string
+}
+
+func newCSVContactWriter(w io.Writer, attributeKeys []string) (*csvContactWriter, error) {
+	writer := csv.NewWriter(w)
+	header := append([]string{}, contactExportColumns...)
+	for _, key := range attributeKeys {
+		header = append(header, importAttributePrefix+key)
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &csvContactWriter{
+		writer:        writer,
+		attributeKeys: attributeKeys,
+		record:        make([]string, len(header)),
+	}, nil
+}
+
+func (w *csvContactWriter) write(contacts []models.Contact) error {
+	for _, contact := range contacts {
+		listNames := make([]string, len(contact.Lists))
+		for i, list := range contact.Lists {
+			listNames[i] = list.Name
+		}
+
+		record := w.record[:0]
+		record = append(record,
+			strconv.FormatUint(uint64(contact.ID), 10),
+			contact.Email,
+			contact.FirstName,
+			contact.LastName,
+			contact.Status,
+			strconv.FormatBool(contact.UnSubscribe),
+			strings.Join(listNames, ";"),
+			contact.CreatedAt.UTC().Format(time.RFC3339),
+			contact.UpdatedAt.UTC().Format(time.RFC3339),
+		)
+		for _, key := range w.attributeKeys {
+			value, err := attributeText(contact.Attributes[key])
+			if err != nil {
+				return err
+			}
+			record = append(record, value)
+		}
+
+		if err := w.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvContactWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func attributeText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
+
+type ndjsonContactWriter struct {
+	encoder *json.Encoder
+}
+
+type exportedList struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type exportedContact struct {
+	ID           uint           `json:"id"`
+	Email        string         `json:"email"`
+	FirstName    string         `json:"first_name"`
+	LastName     string         `json:"last_name"`
+	Status       string         `json:"status"`
+	Unsubscribed bool           `json:"unsubscribed"`
+	Attributes   models.JSONMap `json:"attributes"`
+	Lists        []exportedList `json:"lists"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+func (w *ndjsonContactWriter) write(contacts []models.Contact) error {
+	for _, contact := range contacts {
+		lists := make([]exportedList, len(contact.Lists))
+		for i, list := range contact.Lists {
+			lists[i] = exportedList{ID: list.ID, Name: list.Name}
+		}
+		attributes := contact.Attributes
+		if attributes == nil {
+			attributes = models.JSONMap{}
+		}
+
+		err := w.encoder.Encode(exportedContact{
+			ID:           contact.ID,
+			Email:        contact.Email,
+			FirstName:    contact.FirstName,
+			LastName:     contact.LastName,
+			Status:       contact.Status,
+			Unsubscribed: contact.UnSubscribe,
+			Attributes:   attributes,
+			Lists:        lists,
+			CreatedAt:    contact.CreatedAt,
+			UpdatedAt:    contact.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonContactWriter) flush() error {
+	return nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+)
+
+const (
+	importFieldEmail      = "email"
+	importFieldFirstName  = "first_name"
+	importFieldLastName   = "last_name"
+	importFieldName       = "name"
+	importFieldStatus     = "status"
+	importFieldAttributes = "attributes"
+
+	importAttributePrefix = "attributes."
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// importRow is one record of an import file. A row-level err rejects only
+// that row; errors returned by next() abort the whole import.
+type importRow struct {
+	line   int64
+	values map[string]interface{}
+	err    error
+}
+
+type importReader interface {
+	next() (importRow, error)
+}
+
+func newImportReader(format string, r io.Reader) (importReader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+
+	switch format {
+	case models.ContactImportFormatCSV:
+		return newCSVImportReader(br)
+	case models.ContactImportFormatJSONL:
+		return &jsonlImportReader{reader: br}, nil
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+type csvImportReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = strings.TrimSpace(column)
+	}
+	return &csvImportReader{reader: reader, header: columns}, nil
+}
+
+func (r *csvImportReader) next() (importRow, error) {
+	record, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return importRow{line: int64(parseErr.StartLine), err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return importRow{}, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	row := importRow{
+		line:   int64(line),
+		values: make(map[string]interface{}, len(record)),
+	}
+	for i, value := range record {
+		if i >= len(r.header) {
+			row.err = fmt.Errorf("row has %d columns but the header has %d", len(record), len(r.header))
+			return row, nil
+		}
+		row.values[r.header[i]] = value
+	}
+	return row, nil
+}
+
+type jsonlImportReader struct {
+	reader *bufio.Reader
+	line   int64
+}
+
+func (r *jsonlImportReader) next() (importRow, error) {
+	for {
+		data, err := r.reader.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return importRow{}, err
+		}
+		r.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		row := importRow{line: r.line}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if decodeErr := decoder.Decode(&row.values); decodeErr != nil {
+			row.values = nil
+			row.err = fmt.Errorf("invalid JSON: %v", decodeErr)
+		}
+		return row, nil
+	}
+}
+
+// importMapping maps source columns or keys to contact fields. Columns that
+// are not mapped explicitly are matched by name and otherwise kept as
+// attributes; mapping a column to "" skips it.
+type importMapping map[string]string
+
+func newImportMapping(mapping models.JSONMap) (importMapping, error) {
+	result := make(importMapping, len(mapping))
+	for column, target := range mapping {
+		field, ok := target.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: mapping for %q must be a string", ErrInvalidImport, column)
+		}
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "", field == importFieldEmail, field == importFieldFirstName,
+			field == importFieldLastName, field == importFieldName,
+			field == importFieldStatus, field == importFieldAttributes:
+		case strings.HasPrefix(field, importAttributePrefix) && len(field) > len(importAttributePrefix):
+		default:
+			return nil, fmt.Errorf("%w: unknown field %q for column %q", ErrInvalidImport, field, column)
+		}
+		result[column] = field
+	}
+	return result, nil
+}
+
+func (m importMapping) field(column string) string {
+	if field, ok := m[column]; ok {
+		return field
+	}
+
+	if strings.HasPrefix(column, importAttributePrefix) && len(column) > len(importAttributePrefix) {
+		return column
+	}
+
+	normalized := strings.ToLower(strings.Join(strings.Fields(column), "_"))
+	switch normalized {
+	// Columns written by contact exports that an import cannot set.
+	case "id", "unsubscribed", "lists", "created_at", "updated_at":
+		return ""
+	case importFieldEmail, "email_address", "e-mail":
+		return importFieldEmail
+	case importFieldFirstName, "firstname":
+		return importFieldFirstName
+	case importFieldLastName, "lastname":
+		return importFieldLastName
+	case importFieldName, "full_name":
+		return importFieldName
+	case importFieldStatus, importFieldAttributes:
+		return normalized
+	}
+	return importAttributePrefix + column
+}
+
+func (m importMapping) email(values map[string]interface{}) string {
+	for column, value := range values {
+		if m.field(column) == importFieldEmail {
+			email, _ := value.(string)
+			return strings.TrimSpace(email)
+		}
+	}
+	return ""
+}
+
+// contact builds the contact for a row and reports whether the row set a
+// status explicitly.
+func (m importMapping) contact(values map[string]interface{}) (models.Contact, bool, error) {
+	contact := models.Contact{
+		Status:     models.ContactStatusEnabled,
+		Attributes: models.JSONMap{},
+	}
+	statusSet := false
+	var fullName string
+
+	for column, value := range values {
+		field := m.field(column)
+		if field == "" || value == nil {
+			continue
+		}
+
+		if field == importFieldAttributes {
+			attributes, ok := value.(map[string]interface{})
+			if !ok {
+				return contact, false, fmt.Errorf("%s must be an object", column)
+			}
+			for key, attribute := range attributes {
+				contact.Attributes[key] = attribute
+			}
+			continue
+		}
+		if strings.HasPrefix(field, importAttributePrefix) {
+			if text, ok := value.(string); ok {
+				text = strings.TrimSpace(text)
+				if text == "" {
+					continue
+				}
+				value = text
+			}
+			contact.Attributes[strings.TrimPrefix(field, importAttributePrefix)] = value
+			continue
+		}
+
+		text, ok := value.(string)
+		if !ok {
+			return contact, false, fmt.Errorf("%s must be a string", column)
+		}
+		text = strings.TrimSpace(text)
+
+		switch field {
+		case importFieldEmail:
+			contact.Email = text
+		case importFieldFirstName:
+			contact.FirstName = text
+		case importFieldLastName:
+			contact.LastName = text
+		case importFieldName:
+			fullName = text
+		case importFieldStatus:
+			if text == "" {
+				continue
+			}
+			status := strings.ToLower(text)
+			if status != models.ContactStatusEnabled && status != models.ContactStatusBlocklisted {
+				return contact, false, fmt.Errorf("invalid status %q", text)
+			}
+			contact.Status = status
+			statusSet = true
+		}
+	}
+
+	if fullName != "" && contact.FirstName == "" && contact.LastName == "" {
+		first, last, _ := strings.Cut(fullName, " ")
+		contact.FirstName = first
+		contact.LastName = strings.TrimSpace(last)
+	}
+
+	if contact.Email == "" {
+		return contact, false, errors.New("email is required")
+	}
+	address, err := mail.ParseAddress(contact.Email)
+	if err != nil || address.Address != contact.Email {
+		return contact, false, fmt.Errorf("invalid email %q", contact.Email)
+	}
+
+	return contact, statusSet, nil
+}
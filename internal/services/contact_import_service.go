@@ -0,0 +1,485 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImportNotFound = errors.New("import not found")
+	ErrInvalidImport  = errors.New("invalid import")
+	ErrImportTooLarge = errors.New("import file is too large")
+)
+
+type ContactImportService struct {
+	repo          *repositories.ContactImportRepository
+	contactRepo   *repositories.ContactRepository
+	listRepo      *repositories.ListRepository
+	campaignRepo  *repositories.CampaignRepository
+	attributeRepo *repositories.AttributeRepository
+	config        config.ImportConfig
+	slots         chan struct{}
+}
+
+func NewContactImportService(db *gorm.DB, cfg config.ImportConfig) *ContactImportService {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	return &ContactImportService{
+		repo:          repositories.NewContactImportRepository(db),
+		contactRepo:   repositories.NewContactRepository(db),
+		listRepo:      repositories.NewListRepository(db),
+		campaignRepo:  repositories.NewCampaignRepository(db),
+		attributeRepo: repositories.NewAttributeRepository(db),
+		config:        cfg,
+		slots:         make(chan struct{}, cfg.Concurrency),
+	}
+}
+
+type ContactImportRequest struct {
+	Filename   string
+	FilePath   string
+	Format     string
+	Mapping    models.JSONMap
+	ListID     *uint
+	CampaignID *uint
+	CreatedBy  uint
+}
+
+type ContactImportErrors struct {
+	Errors  []models.ContactImportError `json:"errors"`
+	Total   int64                       `json:"total"`
+	Page    int                         `json:"page"`
+	PerPage int                         `json:"per_page"`
+}
+
+// StoreUpload streams an uploaded file to the import directory and returns
+// its path, so imports never hold the file in memory.
+func (s *ContactImportService) StoreUpload(r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.config.Dir, 0o750); err != nil {
+		return "", fmt.Errorf("error creating import directory: %w", err)
+	}
+
+	var name [16]byte
+	if _, err := rand.Read(name[:]); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.config.Dir, hex.EncodeToString(name[:])+".upload")
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return "", fmt.Errorf("error creating import file: %w", err)
+	}
+
+	src := r
+	if s.config.MaxFileSize > 0 {
+		src = io.LimitReader(r, s.config.MaxFileSize+1)
+	}
+	written, err := io.Copy(file, src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && s.config.MaxFileSize > 0 && written > s.config.MaxFileSize {
+		err = fmt.Errorf("%w: the maximum size is %d bytes", ErrImportTooLarge, s.config.MaxFileSize)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// CreateImport validates the request, records the import and starts it in
+// the background. The stored file is removed if the request is rejected.
+func (s *ContactImportService) CreateImport(req ContactImportRequest) (*models.ContactImport, error) {
+	contactImport, err := s.newImport(req)
+	if err != nil {
+		os.Remove(req.FilePath)
+		return nil, err
+	}
+
+	if err = s.repo.CreateImport(contactImport); err != nil {
+		os.Remove(req.FilePath)
+		return nil, err
+	}
+
+	go s.run(contactImport.ID)
+	return contactImport, nil
+}
+
+func (s *ContactImportService) newImport(req ContactImportRequest) (*models.ContactImport, error) {
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = importFormatFromFilename(req.Filename)
+	}
+	if format != models.ContactImportFormatCSV && format != models.ContactImportFormatJSONL {
+		return nil, fmt.Errorf("%w: format must be csv or jsonl", ErrInvalidImport)
+	}
+
+	if _, err := newImportMapping(req.Mapping); err != nil {
+		return nil, err
+	}
+
+	if req.ListID != nil {
+		list, err := s.listRepo.GetListByID(*req.ListID)
+		if err != nil {
+			return nil, err
+		}
+		if list.ID == 0 {
+			return nil, fmt.Errorf("%w: list %d", ErrListNotFound, *req.ListID)
+		}
+	}
+	if req.CampaignID != nil {
+		campaign, err := s.campaignRepo.GetCampaignByID(*req.CampaignID)
+		if err != nil {
+			return nil, err
+		}
+		if campaign.ID == 0 {
+			return nil, fmt.Errorf("%w: campaign %d not found", ErrInvalidImport, *req.CampaignID)
+		}
+	}
+
+	mapping := req.Mapping
+	if mapping == nil {
+		mapping = models.JSONMap{}
+	}
+	return &models.ContactImport{
+		Filename:   req.Filename,
+		Format:     format,
+		FilePath:   req.FilePath,
+		Mapping:    mapping,
+		ListID:     req.ListID,
+		CampaignID: req.CampaignID,
+		Status:     models.ContactImportStatusPending,
+		CreatedBy:  req.CreatedBy,
+	}, nil
+}
+
+func (s *ContactImportService) GetImport(id uint) (*models.ContactImport, error) {
+	contactImport, err := s.repo.GetImportByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if contactImport.ID == 0 {
+		return nil, ErrImportNotFound
+	}
+	return contactImport, nil
+}
+
+func (s *ContactImportService) ListImports() ([]models.ContactImport, error) {
+	return s.repo.ListImports()
+}
+
+func (s *ContactImportService) GetImportErrors(id uint, page, perPage int) (*ContactImportErrors, error) {
+	if _, err := s.GetImport(id); err != nil {
+		return nil, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultListPageSize
+	}
+	perPage = min(perPage, maxListPageSize)
+
+	importErrors, total, err := s.repo.GetErrors(id, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &ContactImportErrors{
+		Errors:  importErrors,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}, nil
+}
+
+// ResumeImports restarts imports that were pending or running when the
+// server stopped. Upserts are idempotent, so interrupted imports start over.
+func (s *ContactImportService) ResumeImports() {
+	imports, err := s.repo.GetImportsByStatus([]string{
+		models.ContactImportStatusPending,
+		models.ContactImportStatusRunning,
+	})
+	if err != nil {
+		log.Printf("Error loading unfinished imports: %v\n", err)
+		return
+	}
+
+	for _, contactImport := range imports {
+		if err := s.repo.DeleteErrors(contactImport.ID); err != nil {
+			log.Printf("Error resetting import %d: %v\n", contactImport.ID, err)
+			continue
+		}
+		err = s.repo.UpdateImport(contactImport.ID, map[string]interface{}{
+			"status":    models.ContactImportStatusPending,
+			"processed": 0,
+			"created":   0,
+			"updated":   0,
+			"rejected":  0,
+		})
+		if err != nil {
+			log.Printf("Error resetting import %d: %v\n", contactImport.ID, err)
+			continue
+		}
+		go s.run(contactImport.ID)
+	}
+}
+
+func (s *ContactImportService) run(id uint) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	contactImport, err := s.repo.GetImportByID(id)
+	if err != nil || contactImport.ID == 0 {
+		log.Printf("Error loading import %d: %v\n", id, err)
+		return
+	}
+	defer os.Remove(contactImport.FilePath)
+
+	now := time.Now()
+	err = s.repo.UpdateImport(id, map[string]interface{}{
+		"status":     models.ContactImportStatusRunning,
+		"started_at": now,
+	})
+	if err != nil {
+		log.Printf("Error starting import %d: %v\n", id, err)
+		return
+	}
+
+	job := &contactImportJob{service: s, contactImport: contactImport}
+	err = job.process()
+
+	fields := map[string]interface{}{
+		"status":       models.ContactImportStatusCompleted,
+		"completed_at": time.Now(),
+	}
+	if err != nil {
+		log.Printf("Import %d failed: %v\n", id, err)
+		fields["status"] = models.ContactImportStatusFailed
+		fields["status_message"] = truncate(err.Error(), 255)
+	}
+	if err = s.repo.UpdateImport(id, fields); err != nil {
+		log.Printf("Error finishing import %d: %v\n", id, err)
+	}
+}
+
+type contactImportJob struct {
+	service       *ContactImportService
+	contactImport *models.ContactImport
+	mapping       importMapping
+	schema        attributeSchema
+
+	batch           []models.Contact
+	batchLines      []int64
+	batchEmails     map[string]bool
+	batchSetsStatus bool
+	errors          []models.ContactImportError
+
+	processed int64
+	created   int64
+	updated   int64
+	rejected  int64
+}
+
+func (j *contactImportJob) process() error {
+	mapping, err := newImportMapping(j.contactImport.Mapping)
+	if err != nil {
+		return err
+	}
+	j.mapping = mapping
+	j.batchEmails = make(map[string]bool)
+
+	j.schema, err = loadAttributeSchema(j.service.attributeRepo)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(j.contactImport.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening import file: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := newImportReader(j.contactImport.Format, file)
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading import file: %w", err)
+		}
+		if err = j.add(row); err != nil {
+			return err
+		}
+	}
+
+	return j.flush()
+}
+
+func (j *contactImportJob) add(row importRow) error {
+	j.processed++
+
+	err := row.err
+	var contact models.Contact
+	var setsStatus bool
+	if err == nil {
+		contact, setsStatus, err = j.mapping.contact(row.values)
+	}
+	if err == nil {
+		err = j.schema.coerce(contact.Attributes)
+	}
+	if err != nil {
+		j.reject(row.line, j.mapping.email(row.values), err.Error())
+		return j.flushIfFull()
+	}
+
+	// A batch is a single upsert statement, which cannot touch the same
+	// row twice or mix rows that do and do not set a status.
+	if j.batchEmails[contact.Email] || (len(j.batch) > 0 && setsStatus != j.batchSetsStatus) {
+		if err = j.flush(); err != nil {
+			return err
+		}
+	}
+
+	j.batch = append(j.batch, contact)
+	j.batchLines = append(j.batchLines, row.line)
+	j.batchEmails[contact.Email] = true
+	j.batchSetsStatus = setsStatus
+	return j.flushIfFull()
+}
+
+func (j *contactImportJob) reject(line int64, email, reason string) {
+	j.rejected++
+	j.errors = append(j.errors, models.ContactImportError{
+		ImportID: j.contactImport.ID,
+		Row:      line,
+		Email:    truncate(email, 255),
+		Reason:   truncate(reason, 500),
+	})
+}
+
+func (j *contactImportJob) flushIfFull() error {
+	if len(j.batch)+len(j.errors) < j.service.config.BatchSize {
+		return nil
+	}
+	return j.flush()
+}
+
+func (j *contactImportJob) flush() error {
+	s := j.service
+
+	var existing map[string]bool
+	var err error
+	if len(j.batch) > 0 {
+		emails := make([]string, len(j.batch))
+		for i, contact := range j.batch {
+			emails[i] = contact.Email
+		}
+		existing, err = s.contactRepo.GetExistingEmails(emails)
+		if err != nil {
+			return fmt.Errorf("error checking existing contacts: %w", err)
+		}
+
+		// Only new contacts get defaults and must have required attributes;
+		// existing ones keep the stored values the upsert merges into.
+		contacts := j.batch[:0]
+		for i, contact := range j.batch {
+			if !existing[contact.Email] {
+				if err = j.schema.complete(contact.Attributes); err != nil {
+					j.reject(j.batchLines[i], contact.Email, err.Error())
+					continue
+				}
+			}
+			contacts = append(contacts, contact)
+		}
+		j.batch = contacts
+	}
+
+	if len(j.batch) > 0 {
+		now := time.Now()
+		for i := range j.batch {
+			j.batch[i].CreatedAt = now
+			j.batch[i].UpdatedAt = now
+		}
+		if err = s.contactRepo.UpsertContacts(j.batch, j.batchSetsStatus); err != nil {
+			return fmt.Errorf("error saving contacts: %w", err)
+		}
+
+		ids := make([]uint, len(j.batch))
+		for i, contact := range j.batch {
+			ids[i] = contact.ID
+			if existing[contact.Email] {
+				j.updated++
+			} else {
+				j.created++
+			}
+		}
+
+		if j.contactImport.ListID != nil {
+			if _, err = s.listRepo.AddContacts(*j.contactImport.ListID, ids); err != nil {
+				return fmt.Errorf("error adding contacts to list: %w", err)
+			}
+		}
+		if j.contactImport.CampaignID != nil {
+			if err = s.campaignRepo.AddContacts(*j.contactImport.CampaignID, ids); err != nil {
+				return fmt.Errorf("error adding contacts to campaign: %w", err)
+			}
+		}
+	}
+
+	if err = s.repo.AddErrors(j.errors); err != nil {
+		return fmt.Errorf("error saving import errors: %w", err)
+	}
+
+	j.batch = j.batch[:0]
+	j.batchLines = j.batchLines[:0]
+	j.batchEmails = make(map[string]bool)
+	j.errors = j.errors[:0]
+
+	return s.repo.UpdateImport(j.contactImport.ID, map[string]interface{}{
+		"processed": j.processed,
+		"created":   j.created,
+		"updated":   j.updated,
+		"rejected":  j.rejected,
+	})
+}
+
+func importFormatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		return models.ContactImportFormatJSONL
+	default:
+		return models.ContactImportFormatCSV
+	}
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return strings.ToValidUTF8(value[:length], "")
+}
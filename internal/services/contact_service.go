@@ -9,16 +9,25 @@ import (
 )
 
 type ContactService struct {
-	repo *repositories.ContactRepository
+	repo          *repositories.ContactRepository
+	attributeRepo *repositories.AttributeRepository
 }
 
 func NewContactService(db *gorm.DB) *ContactService {
 	return &ContactService{
-		repo: repositories.NewContactRepository(db),
+		repo:          repositories.NewContactRepository(db),
+		attributeRepo: repositories.NewAttributeRepository(db),
 	}
 }
 
 func (s *ContactService) CreateContact(contact *models.Contact) (*models.Contact, error) {
+	if contact.Attributes == nil {
+		contact.Attributes = models.JSONMap{}
+	}
+	if err := s.validateAttributes(contact.Attributes); err != nil {
+		return nil, err
+	}
+
 	createdContact, err := s.repo.CreateContact(contact)
 	if err != nil {
 		return nil, err
@@ -47,10 +56,21 @@ func (s *ContactService) UpdateContact(id uint, contact *models.Contact) (*model
 	if err != nil {
 		return nil, err
 	}
+	if contact.Attributes != nil {
+		if err = s.validateAttributes(contact.Attributes); err != nil {
+			return nil, err
+		}
+	}
 
 	existingContact.FirstName = contact.FirstName
 	existingContact.LastName = contact.LastName
 	existingContact.Email = contact.Email
+	if contact.Status != "" {
+		existingContact.Status = contact.Status
+	}
+	if contact.Attributes != nil {
+		existingContact.Attributes = contact.Attributes
+	}
 	existingContact.UpdatedAt = time.Now()
 
 	updatedContact, err := s.repo.UpdateContact(existingContact)
@@ -61,6 +81,14 @@ func (s *ContactService) UpdateContact(id uint, contact *models.Contact) (*model
 	return &updatedContact, nil
 }
 
+func (s *ContactService) validateAttributes(attributes models.JSONMap) error {
+	schema, err := loadAttributeSchema(s.attributeRepo)
+	if err != nil {
+		return err
+	}
+	return schema.validate(attributes)
+}
+
 func (s *ContactService) DeleteContact(id uint) error {
 	err := s.repo.DeleteContact(id)
 	if err != nil {
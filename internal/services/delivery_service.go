@@ -0,0 +1,345 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/bounce"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBouncePageSize = 50
+	maxBouncePageSize     = 1000
+)
+
+var ErrInvalidBounce = errors.New("invalid bounce")
+
+// BounceEvent reports a bounce or complaint for a message we sent. The
+// message is found by JobID or MessageID, and the recipient from the job,
+// ContactID or Email.
+type BounceEvent struct {
+	JobID      uint   `json:"job_id"`
+	ContactID  uint   `json:"contact_id"`
+	MessageID  string `json:"message_id"`
+	Email      string `json:"email"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`
+	Diagnostic string `json:"diagnostic"`
+	Source     string `json:"source"`
+}
+
+type Bounces struct {
+	Bounces []models.Bounce `json:"bounces"`
+	Total   int64           `json:"total"`
+	Page    int             `json:"page"`
+	PerPage int             `json:"per_page"`
+}
+
+// DeliveryService records what happens to mail after it has been handed to
+// the provider. Hard bounces and complaints suppress the address so it is
+// never mailed again.
+type DeliveryService struct {
+	db          *gorm.DB
+	bounceRepo  *repositories.BounceRepository
+	jobRepo     *repositories.EmailJobRepository
+	contactRepo *repositories.ContactRepository
+	verp        *verpAddresses
+}
+
+func NewDeliveryService(db *gorm.DB, cfg config.Config) *DeliveryService {
+	return &DeliveryService{
+		db:          db,
+		bounceRepo:  repositories.NewBounceRepository(db),
+		jobRepo:     repositories.NewEmailJobRepository(db),
+		contactRepo: repositories.NewContactRepository(db),
+		verp:        newVERPAddresses(cfg),
+	}
+}
+
+// withDB returns a copy of the service that works in tx.
+func (s *DeliveryService) withDB(tx *gorm.DB) *DeliveryService {
+	return &DeliveryService{
+		db:          tx,
+		bounceRepo:  repositories.NewBounceRepository(tx),
+		jobRepo:     repositories.NewEmailJobRepository(tx),
+		contactRepo: repositories.NewContactRepository(tx),
+		verp:        s.verp,
+	}
+}
+
+// RecordBounce records a hard or soft bounce. The job is marked bounced, and
+// a hard bounce suppresses the address.
+func (s *DeliveryService) RecordBounce(event BounceEvent) (*models.Bounce, error) {
+	event.Type = strings.ToLower(strings.TrimSpace(event.Type))
+	if event.Type == "" {
+		event.Type = models.BounceTypeHard
+	}
+	if event.Type != models.BounceTypeHard && event.Type != models.BounceTypeSoft {
+		return nil, fmt.Errorf("%w: type must be hard or soft", ErrInvalidBounce)
+	}
+	return s.record(event)
+}
+
+// RecordComplaint records a spam complaint. The address is suppressed and
+// the contact unsubscribed.
+func (s *DeliveryService) RecordComplaint(event BounceEvent) (*models.Bounce, error) {
+	event.Type = models.BounceTypeComplaint
+	return s.record(event)
+}
+
+func (s *DeliveryService) record(event BounceEvent) (*models.Bounce, error) {
+	job, err := s.findJob(event)
+	if err != nil {
+		return nil, err
+	}
+
+	contactID := event.ContactID
+	if job != nil && job.ContactID != nil {
+		contactID = *job.ContactID
+	}
+	var contact *models.Contact
+	if contactID != 0 {
+		if contact, err = s.contactRepo.GetContactByID(contactID); err != nil {
+			return nil, fmt.Errorf("error loading contact: %w", err)
+		}
+	}
+	address := strings.TrimSpace(event.Email)
+	if address == "" && contact != nil && contact.ID != 0 {
+		address = contact.Email
+	}
+	if address == "" {
+		return nil, fmt.Errorf("%w: the message was not found and no email was given", ErrInvalidBounce)
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid email address: %v", ErrInvalidBounce, err)
+	}
+	address = parsed.Address
+	if contact == nil || contact.ID == 0 {
+		if contact, err = s.contactRepo.GetContactByEmail(address); err != nil {
+			return nil, fmt.Errorf("error loading contact: %w", err)
+		}
+	}
+
+	bounce := &models.Bounce{
+		Email:      address,
+		Type:       event.Type,
+		Source:     event.Source,
+		Status:     truncate(event.Status, 20),
+		Diagnostic: truncate(event.Diagnostic, 1000),
+		MessageID:  truncate(strings.Trim(event.MessageID, "<> "), 255),
+		CreatedAt:  time.Now(),
+	}
+	if bounce.Source == "" {
+		bounce.Source = models.BounceSourceAPI
+	}
+	if job != nil {
+		bounce.EmailJobID = &job.ID
+	}
+	if contact.ID != 0 {
+		bounce.ContactID = &contact.ID
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewBounceRepository(tx).CreateBounce(bounce); err != nil {
+			return err
+		}
+		if job != nil && bounce.Type != models.BounceTypeComplaint {
+			message := bounce.Type + " bounce"
+			if bounce.Diagnostic != "" {
+				message += ": " + bounce.Diagnostic
+			}
+			err := repositories.NewEmailJobRepository(tx).UpdateJobStatus(job.ID, models.EmailJobStatusBounced, truncate(message, 255))
+			if err != nil {
+				return err
+			}
+		}
+
+		switch bounce.Type {
+		case models.BounceTypeHard:
+			return suppressAddress(tx, address, models.SuppressionReasonHardBounce, models.SuppressionSourceBounce, bounce.Diagnostic)
+		case models.BounceTypeComplaint:
+			err := suppressAddress(tx, address, models.SuppressionReasonComplaint, models.SuppressionSourceComplaint, bounce.Diagnostic)
+			if err != nil || contact.ID == 0 || contact.UnSubscribe {
+				return err
+			}
+			if err = repositories.NewContactRepository(tx).Unsubscribe(contact.ID); err != nil {
+				return err
+			}
+			changes := models.JSONMap{}
+			if job != nil {
+				changes["email_job_id"] = job.ID
+			}
+			return repositories.NewPreferenceAuditRepository(tx).CreateAudits([]models.PreferenceAudit{
+				newPreferenceAudit(contact.ID, models.PreferenceActionUnsubscribed, models.PreferenceSourceComplaint, changes, RequestInfo{}),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error recording %s: %w", bounce.Type, err)
+	}
+	return bounce, nil
+}
+
+// ProcessMailbox records the bounces and complaints in up to limit messages
+// from the bounce mailbox and returns how many were recorded. A bounce is
+// traced to its job by the VERP address it was returned to, or else by the
+// original Message-ID or job header. Messages are removed once processed,
+// including those that are not bounces; a message is kept for the next run
+// only when recording failed.
+func (s *DeliveryService) ProcessMailbox(mailbox bounce.Mailbox, limit int) (int, error) {
+	messages, err := mailbox.Fetch(limit)
+	if err != nil {
+		return 0, fmt.Errorf("error reading bounce mailbox: %w", err)
+	}
+
+	recorded := 0
+	processed := make([]string, 0, len(messages))
+	for _, message := range messages {
+		bounces, err := bounce.Parse(message.Raw)
+		if err != nil {
+			log.Printf("Ignoring bounce mailbox message %s: %v", message.ID, err)
+			processed = append(processed, message.ID)
+			continue
+		}
+
+		done := true
+		for _, b := range bounces {
+			event := BounceEvent{
+				MessageID:  b.MessageID,
+				Email:      b.Recipient,
+				Type:       b.Type,
+				Status:     b.Status,
+				Diagnostic: b.Diagnostic,
+				Source:     models.BounceSourceMailbox,
+			}
+			if token, ok := s.verpToken(b.To); ok {
+				event.JobID = token.JobID
+				event.ContactID = token.ContactID
+			} else if event.MessageID == "" {
+				event.JobID = headerJobID(b.Headers)
+			}
+
+			if b.Type == bounce.TypeComplaint {
+				_, err = s.RecordComplaint(event)
+			} else {
+				_, err = s.RecordBounce(event)
+			}
+			if errors.Is(err, ErrInvalidBounce) {
+				log.Printf("Ignoring bounce in mailbox message %s: %v", message.ID, err)
+				continue
+			}
+			if err != nil {
+				log.Printf("Error recording bounce from mailbox message %s: %v", message.ID, err)
+				done = false
+				break
+			}
+			recorded++
+		}
+		if done {
+			processed = append(processed, message.ID)
+		}
+	}
+
+	if err = mailbox.Remove(processed); err != nil {
+		return recorded, fmt.Errorf("error removing processed bounces: %w", err)
+	}
+	return recorded, nil
+}
+
+// verpToken finds the VERP address among those a bounce was delivered to.
+func (s *DeliveryService) verpToken(addresses []string) (VERPToken, bool) {
+	for _, address := range addresses {
+		if token, err := s.verp.parse(address); err == nil {
+			return token, true
+		}
+	}
+	return VERPToken{}, false
+}
+
+// headerJobID reads the job ID added to queued mail from the original
+// headers returned with a bounce.
+func headerJobID(headers textproto.MIMEHeader) uint {
+	value := headers.Get(jobIDHeader)
+	if value == "" {
+		return 0
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// findJob returns the job an event refers to, or nil when it names none or
+// only a message ID we did not send.
+func (s *DeliveryService) findJob(event BounceEvent) (*models.EmailJob, error) {
+	if event.JobID != 0 {
+		job, err := s.jobRepo.GetJobByID(event.JobID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading email job: %w", err)
+		}
+		if job.ID == 0 {
+			return nil, fmt.Errorf("%w: email job %d not found", ErrInvalidBounce, event.JobID)
+		}
+		return job, nil
+	}
+
+	messageID := strings.Trim(event.MessageID, "<> ")
+	if messageID == "" {
+		return nil, nil
+	}
+	job, err := s.jobRepo.GetJobByMessageID(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading email job: %w", err)
+	}
+	if job.ID == 0 {
+		return nil, nil
+	}
+	return job, nil
+}
+
+func (s *DeliveryService) GetBounces(bounceType, email string, page, perPage int) (*Bounces, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultBouncePageSize
+	}
+	perPage = min(perPage, maxBouncePageSize)
+
+	bounces, total, err := s.bounceRepo.GetBounces(strings.ToLower(bounceType), strings.TrimSpace(email), (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &Bounces{
+		Bounces: bounces,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}, nil
+}
+
+// suppressAddress adds an address to the suppression list unless it is
+// already there.
+func suppressAddress(tx *gorm.DB, address, reason, source, details string) error {
+	_, err := repositories.NewSuppressionRepository(tx).AddSuppressions([]models.Suppression{{
+		Type:    models.SuppressionTypeEmail,
+		Value:   strings.ToLower(address),
+		Reason:  reason,
+		Source:  source,
+		Details: details,
+	}})
+	return err
+}
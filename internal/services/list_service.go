@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListPageSize = 50
+	maxListPageSize     = 1000
+)
+
+var (
+	ErrListNotFound = errors.New("list not found")
+	ErrInvalidList  = errors.New("invalid list")
+)
+
+type ListService struct {
+	repo *repositories.ListRepository
+}
+
+func NewListService(db *gorm.DB) *ListService {
+	return &ListService{
+		repo: repositories.NewListRepository(db),
+	}
+}
+
+type ListContacts struct {
+	Contacts []models.Contact `json:"contacts"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PerPage  int              `json:"per_page"`
+}
+
+func (s *ListService) CreateList(list *models.List) (*models.List, error) {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidList)
+	}
+	if err := validateOptIn(list); err != nil {
+		return nil, err
+	}
+
+	createdList, err := s.repo.CreateList(list)
+	if err != nil {
+		return nil, err
+	}
+	return &createdList, nil
+}
+
+func (s *ListService) GetAllLists() ([]models.List, error) {
+	lists, err := s.repo.GetAllLists()
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (s *ListService) GetListByID(id uint) (*models.List, error) {
+	list, err := s.repo.GetListByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if list.ID == 0 {
+		return nil, ErrListNotFound
+	}
+	return list, nil
+}
+
+func (s *ListService) UpdateList(id uint, list *models.List) (*models.List, error) {
+	existingList, err := s.GetListByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(list.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidList)
+	}
+	if err = validateOptIn(list); err != nil {
+		return nil, err
+	}
+	existingList.Name = name
+	existingList.Description = list.Description
+	existingList.Public = list.Public
+	existingList.OptIn = list.OptIn
+	existingList.UpdatedAt = time.Now()
+
+	updatedList, err := s.repo.UpdateList(existingList)
+	if err != nil {
+		return nil, err
+	}
+	return &updatedList, nil
+}
+
+// validateOptIn defaults the opt-in setting to single. Double opt-in lists
+// only take public signups after the address is confirmed.
+func validateOptIn(list *models.List) error {
+	list.OptIn = strings.ToLower(strings.TrimSpace(list.OptIn))
+	switch list.OptIn {
+	case "":
+		list.OptIn = models.ListOptInSingle
+	case models.ListOptInSingle, models.ListOptInDouble:
+	default:
+		return fmt.Errorf("%w: opt_in must be single or double", ErrInvalidList)
+	}
+	return nil
+}
+
+func (s *ListService) DeleteList(id uint) error {
+	if _, err := s.GetListByID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteList(id)
+}
+
+func (s *ListService) AddContacts(id uint, contactIDs []uint) (int64, error) {
+	if _, err := s.GetListByID(id); err != nil {
+		return 0, err
+	}
+	return s.repo.AddContacts(id, contactIDs)
+}
+
+func (s *ListService) RemoveContacts(id uint, contactIDs []uint) (int64, error) {
+	if _, err := s.GetListByID(id); err != nil {
+		return 0, err
+	}
+	return s.repo.RemoveContacts(id, contactIDs)
+}
+
+func (s *ListService) GetListContacts(id uint, page, perPage int) (*ListContacts, error) {
+	if _, err := s.GetListByID(id); err != nil {
+		return nil, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultListPageSize
+	}
+	perPage = min(perPage, maxListPageSize)
+
+	contacts, total, err := s.repo.GetListContacts(id, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &ListContacts{
+		Contacts: contacts,
+		Total:    total,
+		Page:     page,
+		PerPage:  perPage,
+	}, nil
+}
+
+// resolveLists loads the lists targeted by a campaign or broadcast and fails
+// if any of the IDs is unknown.
+func resolveLists(repo *repositories.ListRepository, ids []uint) ([]models.List, error) {
+	lists, err := repo.GetListsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(lists))
+	for _, list := range lists {
+		found[list.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: list %d", ErrListNotFound, id)
+		}
+	}
+	return lists, nil
+}
@@ -2,28 +2,93 @@ package services
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"net/mail"
+	"strconv"
+	"sync"
+	texttemplate "text/template"
 	"time"
 
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
 	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
 	"gorm.io/gorm"
 )
 
+// jobIDHeader carries the EmailJob ID in queued mail, so bounces that return
+// the original headers can be traced to the job.
+const jobIDHeader = "X-Job-Id"
+
 type MailService struct {
-	db         *gorm.DB
-	smtpClient *email.SMTPClient
-	templates  map[string]*template.Template
+	db           *gorm.DB
+	mailer       email.Mailer
+	config       config.Config
+	templates    map[string]*mailTemplate
+	mutex        sync.RWMutex
+	attributes   *attributeSchemaCache
+	links        *subscriptionLinks
+	suppressions *repositories.SuppressionRepository
+	jobRepo      *repositories.EmailJobRepository
+	verp         *verpAddresses
+	rateLimits   *RateLimitService
+}
+
+type mailTemplate struct {
+	html *template.Template
+	text string
 }
 
-func NewMailService(db *gorm.DB, smtpClient *email.SMTPClient) *MailService {
+// optInConfirmationTemplate is used for the opt-in confirmation email when
+// no stored template has the configured name.
+var optInConfirmationTemplate = models.Template{
+	Content: `<html><body>
+<p>{{if .toName}}Hi {{.toName}},{{else}}Hi,{{end}}</p>
+<p>Please confirm that you want to receive emails from:</p>
+<ul>{{range .lists}}<li>{{.}}</li>{{end}}</ul>
+<p><a href="{{.confirmURL}}">Confirm subscription</a></p>
+<p>If you did not sign up, ignore this email and you will not be subscribed.</p>
+</body></html>`,
+	Text: `{{if .toName}}Hi {{.toName}},{{else}}Hi,{{end}}
+
+Please confirm that you want to receive emails from:
+{{range .lists}}
+- {{.}}{{end}}
+
+Confirm your subscription: {{.confirmURL}}
+
+If you did not sign up, ignore this email and you will not be subscribed.
+`,
+}
+
+type TransactionalEmail struct {
+	ToEmail      string
+	ToName       string
+	Subject      string
+	TemplateName string
+	Data         map[string]interface{}
+	Attachments  []AttachmentInput
+	Inline       []AttachmentInput
+}
+
+func NewMailService(db *gorm.DB, mailer email.Mailer, config config.Config) *MailService {
 	return &MailService{
-		db:         db,
-		smtpClient: smtpClient,
-		templates:  make(map[string]*template.Template),
+		db:        db,
+		mailer:    mailer,
+		config:    config,
+		templates: make(map[string]*mailTemplate),
+		attributes: &attributeSchemaCache{
+			repo: repositories.NewAttributeRepository(db),
+		},
+		links:        newSubscriptionLinks(config),
+		verp:         newVERPAddresses(config),
+		suppressions: repositories.NewSuppressionRepository(db),
+		jobRepo:      repositories.NewEmailJobRepository(db),
+		rateLimits:   NewRateLimitService(db, config.Queue),
 	}
 }
 
@@ -40,7 +105,7 @@ func (s *MailService) SendTestEmail(toEmail string) error {
 		Headers: map[string]string{"X-Test": "true"},
 	}
 
-	_, err := s.smtpClient.Send(message)
+	_, err := s.mailer.Send(message)
 	if err != nil {
 		return fmt.Errorf("failed to send test email: %w", err)
 	}
@@ -48,45 +113,154 @@ func (s *MailService) SendTestEmail(toEmail string) error {
 	return nil
 }
 
-func (s *MailService) SendTransactionalEmail(
-	toEmail string,
-	toName string,
-	subject string,
-	templateName string,
-	data map[string]interface{}) error {
+// transactionalAttachments are the attachments of a queued transactional
+// email, as given, to be loaded again when it is sent.
+type transactionalAttachments struct {
+	Attachments []AttachmentInput `json:"attachments"`
+	Inline      []AttachmentInput `json:"inline"`
+}
 
-	tmpl, err := s.getTemplate(templateName)
+// QueueTransactionalEmail renders a transactional email and queues it ahead
+// of bulk mail, returning its job. Attachments are checked now, so that
+// errors in the request are reported to the caller.
+func (s *MailService) QueueTransactionalEmail(req TransactionalEmail) (*models.EmailJob, error) {
+	if err := checkSuppressed(s.suppressions, req.ToEmail); err != nil {
+		if errors.Is(err, ErrRecipientSuppressed) {
+			if logErr := s.logTransactionalEmail(req.ToEmail, req.Subject, req.TemplateName, models.EmailJobStatusRejected, nil); logErr != nil {
+				log.Printf("Failed to log transactional email: %v", logErr)
+			}
+		}
+		return nil, err
+	}
+
+	tmpl, err := s.getTemplate(req.TemplateName)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	files := transactionalAttachments{Attachments: req.Attachments, Inline: req.Inline}
+	if _, _, _, err = s.loadAttachments(files); err != nil {
+		return nil, err
 	}
 
+	data := req.Data
 	if data == nil {
 		data = make(map[string]interface{})
 	}
-	data["toEmail"] = toEmail
-	data["toName"] = toName
+	data["toEmail"] = req.ToEmail
+	data["toName"] = req.ToName
 
 	var htmlBuf bytes.Buffer
-	err = tmpl.Execute(&htmlBuf, data)
+	err = tmpl.html.Execute(&htmlBuf, data)
+	if err != nil {
+		return nil, fmt.Errorf("template execution error: %w", err)
+	}
+	textContent, err := renderText(tmpl.text, data, htmlBuf.String())
 	if err != nil {
-		return fmt.Errorf("template execution error: %w", err)
+		return nil, err
 	}
-	textContent := "Please view this email with an HTML-capable email client."
 
-	message := email.Message{
-		To:      toEmail,
-		Subject: subject,
-		HTML:    htmlBuf.String(),
-		Text:    textContent,
-		Headers: map[string]string{"X-Email-Type": "transactional"},
+	// Bounces are recorded against the contact when the recipient is one.
+	contact, err := repositories.NewContactRepository(s.db).GetContactByEmail(req.ToEmail)
+	if err != nil {
+		return nil, fmt.Errorf("error loading contact: %w", err)
+	}
+
+	now := time.Now()
+	message := &models.TransactionalMessage{
+		ToEmail:   req.ToEmail,
+		ToName:    req.ToName,
+		Subject:   req.Subject,
+		Template:  req.TemplateName,
+		HTML:      htmlBuf.String(),
+		Text:      textContent,
+		CreatedAt: now,
+	}
+	if len(files.Attachments) > 0 || len(files.Inline) > 0 {
+		message.Attachments = models.JSONValue{Data: files}
+	}
+	job := &models.EmailJob{
+		Status:        models.EmailJobStatusQueued,
+		Priority:      models.EmailJobPriorityTransactional,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Transactional: message,
+	}
+	if contact.ID != 0 {
+		job.ContactID = &contact.ID
+	}
+	if err = s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("error queueing transactional email: %w", err)
+	}
+	return job, nil
+}
+
+// sendTransactionalJob sends a queued transactional email. It is sent
+// whatever the recipient's subscription state, as it was asked for, and is
+// only held back by its recipient domain's rate limit, never by the global
+// one; suppressed addresses are still refused.
+func (s *MailService) sendTransactionalJob(owner string, job *models.EmailJob) error {
+	queued := job.Transactional
+	if err := checkSuppressed(s.suppressions, queued.ToEmail); err != nil {
+		if !errors.Is(err, ErrRecipientSuppressed) {
+			return err
+		}
+		job.Status = models.EmailJobStatusRejected
+		job.StatusMessage = err.Error()
+		if err = s.saveJob(owner, job); err != nil {
+			log.Printf("Failed to update job status: %v", err)
+		}
+		if err = s.logTransactionalEmail(queued.ToEmail, queued.Subject, queued.Template, models.EmailJobStatusRejected, nil); err != nil {
+			log.Printf("Failed to log transactional email: %v", err)
+		}
+		return nil
+	}
+
+	var files transactionalAttachments
+	encoded, err := json.Marshal(queued.Attachments)
+	if err == nil {
+		err = json.Unmarshal(encoded, &files)
+	}
+	if err != nil {
+		return unsendable(fmt.Errorf("invalid stored attachments: %w", err))
+	}
+	attachments, inline, records, err := s.loadAttachments(files)
+	if err != nil {
+		return unsendable(err)
 	}
 
-	_, err = s.smtpClient.Send(message)
+	message := email.Message{
+		To:          queued.ToEmail,
+		ToName:      queued.ToName,
+		Subject:     queued.Subject,
+		HTML:        queued.HTML,
+		Text:        queued.Text,
+		Headers:     map[string]string{"X-Email-Type": "transactional"},
+		Attachments: attachments,
+		Inline:      inline,
+	}
+	message.Headers[jobIDHeader] = strconv.FormatUint(uint64(job.ID), 10)
+	token := VERPToken{JobID: job.ID}
+	if job.ContactID != nil {
+		token.ContactID = *job.ContactID
+	}
+	message.ReturnPath = s.verp.address(token)
+
+	if err = s.rateLimits.AcquireTransactional(message.To); err != nil {
+		return err
+	}
+	messageID, err := s.mailer.Send(message)
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
-	err = s.logTransactionalEmail(toEmail, subject, templateName)
+	now := time.Now()
+	job.Status = models.EmailJobStatusSent
+	job.SentAt = &now
+	job.MessageID = messageID
+	if err = s.saveJob(owner, job); err != nil {
+		log.Printf("Failed to update job status: %v", err)
+	}
+	err = s.logTransactionalEmail(queued.ToEmail, queued.Subject, queued.Template, models.EmailJobStatusSent, records)
 	if err != nil {
 		log.Printf("Failed to log transactional email: %v", err)
 	}
@@ -94,85 +268,289 @@ func (s *MailService) SendTransactionalEmail(
 	return nil
 }
 
-func (s *MailService) ProcessJob(job *models.EmailJob) error {
-	err := s.db.Preload("Campaign").Preload("Subscriber").First(job, job.ID).Error
+// loadAttachments reads and checks the attachments of a transactional email.
+func (s *MailService) loadAttachments(files transactionalAttachments) ([]email.Attachment, []email.Attachment, []models.EmailLogAttachment, error) {
+	loader := &attachmentLoader{config: s.config.Attachments}
+	var records []models.EmailLogAttachment
+	attachments := make([]email.Attachment, 0, len(files.Attachments))
+	for _, input := range files.Attachments {
+		attachment, record, err := loader.load(input, false)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		attachments = append(attachments, attachment)
+		records = append(records, record)
+	}
+	inline := make([]email.Attachment, 0, len(files.Inline))
+	for _, input := range files.Inline {
+		attachment, record, err := loader.load(input, true)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		inline = append(inline, attachment)
+		records = append(records, record)
+	}
+	return attachments, inline, records, nil
+}
+
+// ProcessJob sends a job owner has claimed. When a rate limit would hold the
+// message back for long, it is not sent and a *RateLimitedError is returned.
+func (s *MailService) ProcessJob(owner string, job *models.EmailJob) error {
+	err := s.db.Preload("Campaign").Preload("Contact").Preload("Transactional").First(job, job.ID).Error
 	if err != nil {
 		return fmt.Errorf("error loading job data: %w", err)
 	}
+	if job.Transactional != nil {
+		return s.sendTransactionalJob(owner, job)
+	}
+	if job.Contact == nil {
+		return unsendable(errors.New("email job has no contact"))
+	}
+	// The audience is filtered when jobs are queued, but the contact may
+	// have unsubscribed, paused or been blocklisted while the job waited.
+	if reason := checkCanReceive(job.Contact); reason != nil {
+		job.Status = models.EmailJobStatusSkipped
+		job.StatusMessage = reason.Error()
+		if err = s.saveJob(owner, job); err != nil {
+			log.Printf("Failed to update job status: %v", err)
+		}
+		return nil
+	}
+	if err = checkSuppressed(s.suppressions, job.Contact.Email); err != nil {
+		if !errors.Is(err, ErrRecipientSuppressed) {
+			return err
+		}
+		job.Status = models.EmailJobStatusRejected
+		job.StatusMessage = err.Error()
+		if err = s.saveJob(owner, job); err != nil {
+			log.Printf("Failed to update job status: %v", err)
+		}
+		return nil
+	}
+
+	var emailMessage email.Message
+	if job.BroadcastID != nil {
+		emailMessage, err = s.buildBroadcastMessage(job)
+	} else {
+		emailMessage, err = s.buildCampaignMessage(job)
+	}
+	if err != nil {
+		return err
+	}
+	emailMessage.Headers[jobIDHeader] = strconv.FormatUint(uint64(job.ID), 10)
+	emailMessage.ReturnPath = s.verp.address(VERPToken{JobID: job.ID, ContactID: job.Contact.ID})
+
+	if err = s.rateLimits.Acquire(emailMessage.FromEmail, emailMessage.To); err != nil {
+		return err
+	}
+	messageID, err := s.mailer.Send(emailMessage)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	now := time.Now()
+	job.Status = models.EmailJobStatusSent
+	job.SentAt = &now
+	job.MessageID = messageID
+	err = s.saveJob(owner, job)
+	if err != nil {
+		log.Printf("Failed to update job status: %v", err)
+	}
 
+	return nil
+}
+
+// saveJob stores the outcome of a job owner holds and releases it. If the
+// lease ran out meanwhile, the job belongs to another worker and is left to
+// it, and ErrLeaseLost is returned.
+func (s *MailService) saveJob(owner string, job *models.EmailJob) error {
+	held, err := s.jobRepo.FinishJob(owner, job.ID, map[string]interface{}{
+		"status":         job.Status,
+		"status_message": job.StatusMessage,
+		"sent_at":        job.SentAt,
+		"message_id":     job.MessageID,
+	})
+	if err != nil {
+		return err
+	}
+	if !held {
+		return fmt.Errorf("%w: job %d", ErrLeaseLost, job.ID)
+	}
+	job.LockedBy = ""
+	job.LockedUntil = nil
+	return nil
+}
+
+func (s *MailService) buildCampaignMessage(job *models.EmailJob) (email.Message, error) {
 	var message models.Message
-	err = s.db.Where("id = ?", job.Campaign.ID).First(&message).Error
+	err := s.db.Where("id = ?", job.Campaign.ID).First(&message).Error
 	if err != nil {
-		return fmt.Errorf("error loading message data: %w", err)
+		return email.Message{}, fmt.Errorf("error loading message data: %w", err)
 	}
 
+	contact := job.Contact
+	unsubscribeURL := s.links.unsubscribeURL(UnsubscribeToken{ContactID: contact.ID, CampaignID: job.Campaign.ID})
 	data := map[string]interface{}{
-		"subscriber": job.Subscriber,
-		"campaign":   job.Campaign,
-		"message":    message,
-		"date":       time.Now().Format("2006-01-02"),
-		"unsubscribeURL": fmt.Sprintf("http://example.com/unsubscribe?email=%s&uuid=%d",
-			job.Subscriber.Email, job.Subscriber.ID),
+		"contact":        contact,
+		"attributes":     s.attributes.get().withDefaults(contact.Attributes),
+		"campaign":       job.Campaign,
+		"message":        message,
+		"date":           time.Now().Format("2006-01-02"),
+		"unsubscribeURL": unsubscribeURL,
+		"preferencesURL": s.links.preferencesURL(contact.ID),
 	}
 
 	subjectTmpl, err := template.New("subject").Parse(message.Subject)
 	if err != nil {
-		return fmt.Errorf("subject template parse error: %w", err)
+		return email.Message{}, unsendable(fmt.Errorf("subject template parse error: %w", err))
 	}
 	var subjectBuf bytes.Buffer
 	err = subjectTmpl.Execute(&subjectBuf, data)
 	if err != nil {
-		return fmt.Errorf("subject template execution error: %w", err)
+		return email.Message{}, unsendable(fmt.Errorf("subject template execution error: %w", err))
 	}
 	subject := subjectBuf.String()
 	htmlTmpl, err := template.New("html").Parse(message.Body)
 	if err != nil {
-		return fmt.Errorf("html template parse error: %w", err)
+		return email.Message{}, unsendable(fmt.Errorf("html template parse error: %w", err))
 	}
 	var htmlBuf bytes.Buffer
 	err = htmlTmpl.Execute(&htmlBuf, data)
 	if err != nil {
-		return fmt.Errorf("html template execution error: %w", err)
+		return email.Message{}, unsendable(fmt.Errorf("html template execution error: %w", err))
 	}
 	htmlContent := htmlBuf.String()
-	textContent := "Please view this email with an HTML-capable email client."
+	textContent, err := renderText(message.AltBody, data, htmlContent)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	headers := s.links.headers(unsubscribeURL)
+	headers["X-Campaign-ID"] = fmt.Sprintf("%d", job.Campaign.ID)
+	headers["X-Contact-ID"] = fmt.Sprintf("%d", contact.ID)
 
 	emailMessage := email.Message{
 		FromEmail: message.FromEmail,
 		FromName:  message.FromName,
-		To:        job.Subscriber.Email,
+		To:        contact.Email,
+		ToName:    contact.Name(),
 		Subject:   subject,
 		HTML:      htmlContent,
 		Text:      textContent,
-		Headers: map[string]string{
-			"X-Campaign-ID":   fmt.Sprintf("%d", job.CampaignID),
-			"X-Subscriber-ID": fmt.Sprintf("%d", job.SubscriberID),
-		},
+		Headers:   headers,
 	}
 
-	messageID, err := s.smtpClient.Send(emailMessage)
+	return emailMessage, nil
+}
+
+func (s *MailService) buildBroadcastMessage(job *models.EmailJob) (email.Message, error) {
+	var broadcast models.Broadcast
+	err := s.db.First(&broadcast, *job.BroadcastID).Error
 	if err != nil {
-		job.Status = models.EmailJobStatusFailed
-		job.StatusMessage = err.Error()
-		s.db.Save(job)
-		return fmt.Errorf("failed to send email: %w", err)
+		return email.Message{}, fmt.Errorf("error loading broadcast data: %w", err)
 	}
+	contact := job.Contact
 
-	now := time.Now()
-	job.Status = models.EmailJobStatusSent
-	job.SentAt = &now
-	job.MessageID = messageID
-	err = s.db.Save(job).Error
+	from, err := mail.ParseAddress(broadcast.From)
 	if err != nil {
-		log.Printf("Failed to update job status: %v", err)
+		return email.Message{}, unsendable(fmt.Errorf("invalid broadcast from address: %w", err))
 	}
 
-	return nil
+	unsubscribeURL := s.links.unsubscribeURL(UnsubscribeToken{ContactID: contact.ID, BroadcastID: broadcast.ID})
+	data := map[string]interface{}{
+		"contact":        contact,
+		"attributes":     s.attributes.get().withDefaults(contact.Attributes),
+		"broadcast":      broadcast,
+		"date":           time.Now().Format("2006-01-02"),
+		"unsubscribeURL": unsubscribeURL,
+		"preferencesURL": s.links.preferencesURL(contact.ID),
+	}
+
+	subjectTmpl, err := texttemplate.New("subject").Parse(broadcast.Subject)
+	if err != nil {
+		return email.Message{}, unsendable(fmt.Errorf("subject template parse error: %w", err))
+	}
+	var subjectBuf bytes.Buffer
+	err = subjectTmpl.Execute(&subjectBuf, data)
+	if err != nil {
+		return email.Message{}, unsendable(fmt.Errorf("subject template execution error: %w", err))
+	}
+
+	var htmlContent string
+	if broadcast.HTML != "" {
+		htmlTmpl, err := template.New("html").Parse(broadcast.HTML)
+		if err != nil {
+			return email.Message{}, unsendable(fmt.Errorf("html template parse error: %w", err))
+		}
+		var htmlBuf bytes.Buffer
+		err = htmlTmpl.Execute(&htmlBuf, data)
+		if err != nil {
+			return email.Message{}, unsendable(fmt.Errorf("html template execution error: %w", err))
+		}
+		htmlContent = htmlBuf.String()
+	}
+
+	textContent, err := renderText(broadcast.Text, data, htmlContent)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	headers := s.links.headers(unsubscribeURL)
+	headers["X-Broadcast-ID"] = fmt.Sprintf("%d", broadcast.ID)
+	headers["X-Contact-ID"] = fmt.Sprintf("%d", contact.ID)
+	if broadcast.ReplyTo != "" {
+		headers["Reply-To"] = broadcast.ReplyTo
+	}
+
+	return email.Message{
+		FromEmail: from.Address,
+		FromName:  from.Name,
+		To:        contact.Email,
+		ToName:    contact.Name(),
+		Subject:   subjectBuf.String(),
+		HTML:      htmlContent,
+		Text:      textContent,
+		Headers:   headers,
+	}, nil
 }
 
+// ProcessCampaignJob sends a campaign message to a contact given by the
+// caller. Contacts are matched to stored ones by email, so that unsubscribed
+// and blocklisted contacts are refused and the unsubscribe link works. The
+// rate limits apply as to queued mail; a message they would hold back for
+// long is not sent, and a *RateLimitedError is returned.
 func (s *MailService) ProcessCampaignJob(campaignID uint, contact *models.Contact) error {
+	stored, err := repositories.NewContactRepository(s.db).GetContactByEmail(contact.Email)
+	if err != nil {
+		return fmt.Errorf("error loading contact: %w", err)
+	}
+	if stored.ID != 0 {
+		if err = checkCanReceive(stored); err != nil {
+			return err
+		}
+		contact.ID = stored.ID
+	}
+	if err = checkSuppressed(s.suppressions, contact.Email); err != nil {
+		if errors.Is(err, ErrRecipientSuppressed) {
+			job := &models.EmailJob{
+				CampaignID:    &campaignID,
+				Status:        models.EmailJobStatusRejected,
+				StatusMessage: err.Error(),
+				CreatedAt:     time.Now(),
+				UpdatedAt:     time.Now(),
+			}
+			if contact.ID != 0 {
+				job.ContactID = &contact.ID
+			}
+			if createErr := s.db.Create(job).Error; createErr != nil {
+				log.Printf("Failed to create job record: %v", createErr)
+			}
+		}
+		return err
+	}
+
 	var campaign models.Campaign
-	err := s.db.First(&campaign, campaignID).Error
+	err = s.db.First(&campaign, campaignID).Error
 	if err != nil {
 		return fmt.Errorf("error loading campaign data: %w", err)
 	}
@@ -184,13 +562,21 @@ func (s *MailService) ProcessCampaignJob(campaignID uint, contact *models.Contac
 	}
 
 	data := map[string]interface{}{
-		"contact":  contact,
-		"campaign": campaign,
-		"message":  message,
-		"date":     time.Now().Format("2006-01-02"),
-		"unsubscribeURL": fmt.Sprintf("http://example.com/unsubscribe?email=%s&uuid=%d",
-			contact.Email, contact.ID),
+		"contact":    contact,
+		"attributes": s.attributes.get().withDefaults(contact.Attributes),
+		"campaign":   campaign,
+		"message":    message,
+		"date":       time.Now().Format("2006-01-02"),
+	}
+	headers := map[string]string{}
+	if contact.ID != 0 {
+		unsubscribeURL := s.links.unsubscribeURL(UnsubscribeToken{ContactID: contact.ID, CampaignID: campaignID})
+		data["unsubscribeURL"] = unsubscribeURL
+		data["preferencesURL"] = s.links.preferencesURL(contact.ID)
+		headers = s.links.headers(unsubscribeURL)
 	}
+	headers["X-Campaign-ID"] = fmt.Sprintf("%d", campaignID)
+	headers["X-Contact-ID"] = fmt.Sprintf("%d", contact.ID)
 
 	subjectTmpl, err := template.New("subject").Parse(message.Subject)
 	if err != nil {
@@ -214,29 +600,38 @@ func (s *MailService) ProcessCampaignJob(campaignID uint, contact *models.Contac
 	}
 	htmlContent := htmlBuf.String()
 
-	textContent := "Please view this email with an HTML-capable email client."
+	textContent, err := renderText(message.AltBody, data, htmlContent)
+	if err != nil {
+		return err
+	}
 
 	emailMessage := email.Message{
 		FromEmail: message.FromEmail,
 		FromName:  message.FromName,
 		To:        contact.Email,
+		ToName:    contact.Name(),
 		Subject:   subject,
 		HTML:      htmlContent,
 		Text:      textContent,
-		Headers: map[string]string{
-			"X-Campaign-ID": fmt.Sprintf("%d", campaignID),
-			"X-Contact-ID":  fmt.Sprintf("%d", contact.ID),
-		},
+		Headers:   headers,
+	}
+	var contactID *uint
+	if contact.ID != 0 {
+		contactID = &contact.ID
+		emailMessage.ReturnPath = s.verp.address(VERPToken{ContactID: contact.ID})
 	}
 
-	messageID, err := s.smtpClient.Send(emailMessage)
+	if err = s.rateLimits.Acquire(emailMessage.FromEmail, emailMessage.To); err != nil {
+		return err
+	}
+	messageID, err := s.mailer.Send(emailMessage)
 	if err != nil {
 		log.Printf("Failed to send email to contact %d: %v", contact.ID, err)
 		job := &models.EmailJob{
-			CampaignID:    campaignID,
-			SubscriberID:  contact.ID,
-			Status:        models.EmailJobStatusFailed,
-			StatusMessage: err.Error(),
+			CampaignID:    &campaignID,
+			ContactID:     contactID,
+			Status:        models.EmailJobStatusDead,
+			StatusMessage: truncate(err.Error(), 255),
 			Attempts:      1,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
@@ -248,14 +643,14 @@ func (s *MailService) ProcessCampaignJob(campaignID uint, contact *models.Contac
 
 	now := time.Now()
 	job := &models.EmailJob{
-		CampaignID:   campaignID,
-		SubscriberID: contact.ID,
-		Status:       models.EmailJobStatusSent,
-		MessageID:    messageID,
-		Attempts:     1,
-		SentAt:       &now,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		CampaignID: &campaignID,
+		ContactID:  contactID,
+		Status:     models.EmailJobStatusSent,
+		MessageID:  messageID,
+		Attempts:   1,
+		SentAt:     &now,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
 	}
 	err = s.db.Create(job).Error
 	if err != nil {
@@ -265,37 +660,71 @@ func (s *MailService) ProcessCampaignJob(campaignID uint, contact *models.Contac
 	return nil
 }
 
-func (s *MailService) getTemplate(name string) (*template.Template, error) {
-	if tmpl, ok := s.templates[name]; ok {
+func (s *MailService) getTemplate(name string) (*mailTemplate, error) {
+	s.mutex.RLock()
+	tmpl, ok := s.templates[name]
+	s.mutex.RUnlock()
+	if ok {
 		return tmpl, nil
 	}
 
-	var templateContent string
-	err := s.db.Model(models.Template{}).
-		Where("name = ?", name).
-		Select("content").
-		Scan(&templateContent).Error
+	var record models.Template
+	err := s.db.Where("name = ?", name).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && name == s.config.OptIn.TemplateName {
+		record = optInConfirmationTemplate
+		err = nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("template not found: %w", err)
 	}
 
-	tmpl, err := template.New(name).Parse(templateContent)
+	htmlTmpl, err := template.New(name).Parse(record.Content)
 	if err != nil {
 		return nil, fmt.Errorf("template parse error: %w", err)
 	}
+	if record.Text != "" {
+		if _, err = texttemplate.New(name).Parse(record.Text); err != nil {
+			return nil, fmt.Errorf("text template parse error: %w", err)
+		}
+	}
 
+	tmpl = &mailTemplate{
+		html: htmlTmpl,
+		text: record.Text,
+	}
+	s.mutex.Lock()
 	s.templates[name] = tmpl
+	s.mutex.Unlock()
 	return tmpl, nil
 }
 
-func (s *MailService) logTransactionalEmail(toEmail, subject, templateName string) error {
+// renderText executes the author's plain-text template when there is one and
+// otherwise derives the text part from the rendered HTML.
+func renderText(source string, data map[string]interface{}, htmlContent string) (string, error) {
+	if source == "" {
+		return email.HTMLToText(htmlContent), nil
+	}
+
+	tmpl, err := texttemplate.New("text").Parse(source)
+	if err != nil {
+		return "", unsendable(fmt.Errorf("text template parse error: %w", err))
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", unsendable(fmt.Errorf("text template execution error: %w", err))
+	}
+	return buf.String(), nil
+}
+
+func (s *MailService) logTransactionalEmail(toEmail, subject, templateName, status string, attachments []models.EmailLogAttachment) error {
 	log := models.EmailLog{
-		Email:    toEmail,
-		Subject:  subject,
-		Template: templateName,
-		Type:     "transactional",
-		SentAt:   time.Now(),
-		Status:   "sent",
+		Email:       toEmail,
+		Subject:     subject,
+		Template:    templateName,
+		Type:        "transactional",
+		SentAt:      time.Now(),
+		Status:      status,
+		Attachments: attachments,
 	}
 
 	return s.db.Create(&log).Error
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultClaimBatchSize = 10
+	defaultLeaseTimeout   = 5 * time.Minute
+	defaultPollInterval   = 30 * time.Second
+	defaultJobPageSize    = 50
+	maxJobPageSize        = 1000
+)
+
+// jobWaitInterval is how often WaitForJob checks on a job.
+const jobWaitInterval = 250 * time.Millisecond
+
+var (
+	ErrJobNotFound       = errors.New("email job not found")
+	ErrJobNotClaimable   = errors.New("email job is not queued or dead, or is being sent")
+	ErrInvalidDeadJobSet = errors.New("invalid dead job selection")
+	ErrLeaseLost         = errors.New("email job lease was lost to another worker")
+)
+
+type DeadJobs struct {
+	Jobs    []models.EmailJob `json:"jobs"`
+	Total   int64             `json:"total"`
+	Page    int               `json:"page"`
+	PerPage int               `json:"per_page"`
+}
+
+// QueueService hands queued email jobs to workers. A claimed job is leased
+// to its owner, which renews the lease while it holds the job; a job whose
+// lease runs out, because its worker or replica died, is requeued by
+// RequeueExpiredJobs. This keeps any job from being sent by two workers at
+// once.
+type QueueService struct {
+	db        *gorm.DB
+	jobRepo   *repositories.EmailJobRepository
+	batchSize int
+	lease     time.Duration
+	poll      time.Duration
+	retry     retryPolicy
+}
+
+func NewQueueService(db *gorm.DB, cfg config.QueueConfig) *QueueService {
+	batchSize := cfg.ClaimBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultClaimBatchSize
+	}
+	lease := cfg.LeaseTimeout
+	if lease <= 0 {
+		lease = defaultLeaseTimeout
+	}
+	poll := cfg.PollInterval
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+	return &QueueService{
+		db:        db,
+		jobRepo:   repositories.NewEmailJobRepository(db),
+		batchSize: batchSize,
+		lease:     lease,
+		poll:      poll,
+		retry:     newRetryPolicy(cfg),
+	}
+}
+
+// QueueOwner names a claimant uniquely across replicas, so leases held by
+// one process are never renewed or released by another.
+func QueueOwner(name string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), name)
+}
+
+// LeaseTimeout is how long a claim lasts without being renewed.
+func (s *QueueService) LeaseTimeout() time.Duration {
+	return s.lease
+}
+
+// PollInterval is how often idle workers look for due jobs without being
+// notified, which picks up retries as their backoff ends.
+func (s *QueueService) PollInterval() time.Duration {
+	return s.poll
+}
+
+// ClaimJobs claims the next batch of queued jobs with at least minPriority
+// for owner, highest priority then oldest first.
+func (s *QueueService) ClaimJobs(owner string, minPriority int) ([]models.EmailJob, error) {
+	jobs, err := s.jobRepo.ClaimJobs(owner, s.batchSize, minPriority, time.Now().Add(s.lease))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming email jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// ClaimJob claims one queued or failed job, to send it out of turn.
+func (s *QueueService) ClaimJob(id uint, owner string) (*models.EmailJob, error) {
+	job, err := s.jobRepo.ClaimJob(id, owner, time.Now().Add(s.lease))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming email job: %w", err)
+	}
+	if job.ID != 0 {
+		return job, nil
+	}
+
+	existing, err := s.jobRepo.GetJobByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading email job: %w", err)
+	}
+	if existing.ID == 0 {
+		return nil, ErrJobNotFound
+	}
+	return nil, fmt.Errorf("%w: status is %s", ErrJobNotClaimable, existing.Status)
+}
+
+func (s *QueueService) GetJob(id uint) (*models.EmailJob, error) {
+	job, err := s.jobRepo.GetJobByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading email job: %w", err)
+	}
+	if job.ID == 0 {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// WaitForJob waits up to timeout for a job to be sent or to fail for good,
+// and returns it as it then is. Any replica may send the job, so its status
+// is polled rather than waited for in this process.
+func (s *QueueService) WaitForJob(ctx context.Context, id uint, timeout time.Duration) (*models.EmailJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(jobWaitInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := s.GetJob(id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status != models.EmailJobStatusQueued && job.Status != models.EmailJobStatusSending {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RenewLeases extends owner's leases on jobs and returns how many of them it
+// still holds.
+func (s *QueueService) RenewLeases(owner string, ids []uint) (int64, error) {
+	return s.jobRepo.RenewLeases(owner, ids, time.Now().Add(s.lease))
+}
+
+// ReleaseJobs puts claimed jobs that were never started back in the queue.
+func (s *QueueService) ReleaseJobs(owner string, ids []uint) error {
+	return s.jobRepo.ReleaseJobs(owner, ids)
+}
+
+// FailJob records a failed attempt at a job owner holds. The job is queued
+// again after a backoff, and the time of the next attempt returned, unless
+// the failure was permanent or it has no retries left; it is then moved to
+// the dead-letter queue and nil returned.
+func (s *QueueService) FailJob(owner string, job *models.EmailJob, cause error) (*time.Time, error) {
+	message := truncate(cause.Error(), 255)
+	next, retry := s.retry.next(job.Attempts, cause)
+	if !retry {
+		if err := s.jobRepo.DeadLetterJob(owner, job.ID, message); err != nil {
+			return nil, fmt.Errorf("error dead-lettering email job: %w", err)
+		}
+		return nil, nil
+	}
+	if err := s.jobRepo.RetryJob(owner, job.ID, message, next); err != nil {
+		return nil, fmt.Errorf("error requeueing email job: %w", err)
+	}
+	return &next, nil
+}
+
+// DeferJob puts back a job owner holds that a rate limit kept from being
+// sent, to be claimed again once the limit allows it.
+func (s *QueueService) DeferJob(owner string, job *models.EmailJob, limited *RateLimitedError) error {
+	if err := s.jobRepo.DeferJob(owner, job.ID, truncate(limited.Error(), 255), limited.Until); err != nil {
+		return fmt.Errorf("error deferring email job: %w", err)
+	}
+	return nil
+}
+
+// RequeueExpiredJobs returns jobs left sending by a worker that stopped
+// renewing its lease to the queue. A job that has used all its attempts is
+// dead-lettered instead, so a message that crashes the sender is not
+// retried forever. It returns the numbers requeued and dead-lettered.
+func (s *QueueService) RequeueExpiredJobs() (int64, int64, error) {
+	return s.jobRepo.RequeueExpiredJobs(s.lease, s.retry.maxAttempts())
+}
+
+func (s *QueueService) GetDeadJobs(filter models.DeadJobFilter, page, perPage int) (*DeadJobs, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultJobPageSize
+	}
+	perPage = min(perPage, maxJobPageSize)
+
+	filter.IDs = nil
+	jobs, total, err := s.jobRepo.GetDeadJobs(filter, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadJobs{
+		Jobs:    jobs,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}, nil
+}
+
+// RequeueDeadJobs returns the selected dead jobs to the queue with a fresh
+// set of retries, and returns how many were requeued.
+func (s *QueueService) RequeueDeadJobs(filter models.DeadJobFilter) (int64, error) {
+	if err := validateDeadJobFilter(filter); err != nil {
+		return 0, err
+	}
+	return s.jobRepo.RequeueDeadJobs(filter)
+}
+
+// DiscardDeadJobs deletes the selected dead jobs and returns how many were
+// deleted.
+func (s *QueueService) DiscardDeadJobs(filter models.DeadJobFilter) (int64, error) {
+	if err := validateDeadJobFilter(filter); err != nil {
+		return 0, err
+	}
+	return s.jobRepo.DiscardDeadJobs(filter)
+}
+
+// validateDeadJobFilter requires bulk changes to name their jobs or ask for
+// all of them explicitly, so an empty request cannot empty the queue.
+func validateDeadJobFilter(filter models.DeadJobFilter) error {
+	if len(filter.IDs) == 0 && !filter.All {
+		return fmt.Errorf("%w: give ids or set all", ErrInvalidDeadJobSet)
+	}
+	if len(filter.IDs) > 0 && filter.All {
+		return fmt.Errorf("%w: give either ids or all, not both", ErrInvalidDeadJobSet)
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"gorm.io/gorm"
+)
+
+// maxRateLimitWait is the longest a sender waits for a token. A message that
+// would wait longer is put back in the queue, so that a worker is not held
+// up by one busy domain while mail for others is waiting.
+const maxRateLimitWait = 5 * time.Second
+
+var (
+	ErrRateLimitNotFound = errors.New("rate limit not found")
+	ErrInvalidRateLimit  = errors.New("invalid rate limit")
+)
+
+// RateLimitedError is returned when a message cannot be sent before Until
+// without exceeding Limit.
+type RateLimitedError struct {
+	Limit models.RateLimit
+	Until time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	name := e.Limit.Scope
+	if e.Limit.Key != "" {
+		name += " " + e.Limit.Key
+	}
+	return fmt.Sprintf("%s rate limit of %d a minute reached until %s", name, e.Limit.Rate, e.Until.Format(time.RFC3339))
+}
+
+// RateLimitService enforces the global, domain and sender rate limits. The
+// buckets are rows in Postgres, taken from under a row lock, so the limits
+// hold across every worker and replica, and changes made through the API
+// apply to the next message sent.
+type RateLimitService struct {
+	db     *gorm.DB
+	repo   *repositories.RateLimitRepository
+	config config.QueueConfig
+}
+
+func NewRateLimitService(db *gorm.DB, cfg config.QueueConfig) *RateLimitService {
+	return &RateLimitService{
+		db:     db,
+		repo:   repositories.NewRateLimitRepository(db),
+		config: cfg,
+	}
+}
+
+// SeedLimits creates the configured limits that do not exist yet. Stored
+// limits are left as they are, so changes made through the API survive
+// restarts.
+func (s *RateLimitService) SeedLimits() error {
+	configured := s.config.RateLimits
+	if s.config.RateLimit > 0 {
+		configured = append([]config.RateLimitConfig{{
+			Scope: models.RateLimitScopeGlobal,
+			Rate:  s.config.RateLimit,
+			Burst: s.config.RateLimitBurst,
+		}}, configured...)
+	}
+
+	if len(configured) == 0 {
+		return nil
+	}
+	now, err := s.repo.Now()
+	if err != nil {
+		return err
+	}
+
+	limits := make([]models.RateLimit, 0, len(configured))
+	for _, cfg := range configured {
+		limit := models.RateLimit{Scope: cfg.Scope, Key: cfg.Key, Rate: cfg.Rate, Burst: cfg.Burst}
+		if err := normalizeRateLimit(&limit, now); err != nil {
+			return fmt.Errorf("queue.rateLimits: %w", err)
+		}
+		limits = append(limits, limit)
+	}
+
+	_, err = s.repo.AddLimits(limits)
+	return err
+}
+
+func (s *RateLimitService) CreateLimit(limit *models.RateLimit) (*models.RateLimit, error) {
+	now, err := s.repo.Now()
+	if err != nil {
+		return nil, err
+	}
+	if err = normalizeRateLimit(limit, now); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetLimit(limit.Scope, limit.Key)
+	if err != nil {
+		return nil, err
+	}
+	if existing.ID != 0 {
+		return nil, fmt.Errorf("%w: a %s limit already exists for %q", ErrInvalidRateLimit, limit.Scope, limit.Key)
+	}
+
+	createdLimit, err := s.repo.CreateLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	return &createdLimit, nil
+}
+
+func (s *RateLimitService) GetLimits(scope string) ([]models.RateLimit, error) {
+	return s.repo.GetLimits(strings.ToLower(scope))
+}
+
+func (s *RateLimitService) GetLimitByID(id uint) (*models.RateLimit, error) {
+	limit, err := s.repo.GetLimitByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if limit.ID == 0 {
+		return nil, ErrRateLimitNotFound
+	}
+	return limit, nil
+}
+
+// UpdateLimit changes the rate and burst. Tokens already in the bucket are
+// kept, up to the new burst. The bucket is locked, as in take, so tokens
+// taken meanwhile are not lost.
+func (s *RateLimitService) UpdateLimit(id uint, limit *models.RateLimit) (*models.RateLimit, error) {
+	if err := validateRate(limit); err != nil {
+		return nil, err
+	}
+
+	var updatedLimit models.RateLimit
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewRateLimitRepository(tx)
+		existingLimit, err := repo.LockLimit(id)
+		if err != nil {
+			return err
+		}
+		if existingLimit.ID == 0 {
+			return ErrRateLimitNotFound
+		}
+		now, err := repo.Now()
+		if err != nil {
+			return err
+		}
+
+		refill(existingLimit, now)
+		existingLimit.Rate = limit.Rate
+		existingLimit.Burst = limit.Burst
+		existingLimit.Tokens = math.Min(existingLimit.Tokens, float64(limit.Burst))
+		existingLimit.UpdatedAt = now
+
+		updatedLimit, err = repo.UpdateLimit(existingLimit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updatedLimit, nil
+}
+
+func (s *RateLimitService) DeleteLimit(id uint) error {
+	if _, err := s.GetLimitByID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteLimit(id)
+}
+
+// Acquire takes a token from every limit that applies to mail from sender to
+// recipient, waiting for them to refill if that takes no longer than
+// maxRateLimitWait. Otherwise it returns a *RateLimitedError, and the
+// message should not be sent before its Until. Times are on the database's
+// clock, as the buckets are.
+func (s *RateLimitService) Acquire(sender, recipient string) error {
+	return s.acquire(true, recipientDomain(recipient), strings.ToLower(strings.TrimSpace(sender)))
+}
+
+// AcquireTransactional is Acquire for transactional mail, which only the
+// recipient's domain limit applies to: the global limit paces bulk mail,
+// which transactional mail must never wait behind.
+func (s *RateLimitService) AcquireTransactional(recipient string) error {
+	return s.acquire(false, recipientDomain(recipient), "")
+}
+
+func (s *RateLimitService) acquire(global bool, domain, sender string) error {
+	var deadline time.Time
+	for {
+		now, wait, limit, err := s.take(global, domain, sender)
+		if err != nil {
+			return fmt.Errorf("error taking rate limit tokens: %w", err)
+		}
+		if limit == nil {
+			return nil
+		}
+		if deadline.IsZero() {
+			deadline = now.Add(maxRateLimitWait)
+		}
+		until := now.Add(wait)
+		if until.After(deadline) {
+			return &RateLimitedError{Limit: *limit, Until: until}
+		}
+		time.Sleep(wait)
+	}
+}
+
+// take takes a token from each applicable bucket if all of them have one.
+// Otherwise it takes none, and returns the limit that will take longest to
+// refill and how long that is from now, the database's time.
+func (s *RateLimitService) take(global bool, domain, sender string) (time.Time, time.Duration, *models.RateLimit, error) {
+	var now time.Time
+	var wait time.Duration
+	var blocking *models.RateLimit
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewRateLimitRepository(tx)
+		limits, err := repo.LockLimits(global, domain, sender)
+		if err != nil || len(limits) == 0 {
+			return err
+		}
+		now, err = repo.Now()
+		if err != nil {
+			return err
+		}
+
+		for i := range limits {
+			limit := &limits[i]
+			refill(limit, now)
+			if limit.Tokens >= 1 {
+				continue
+			}
+			missing := time.Duration((1 - limit.Tokens) / float64(limit.Rate) * float64(time.Minute))
+			if blocking == nil || missing > wait {
+				wait = missing
+				blocking = limit
+			}
+		}
+		if blocking != nil {
+			return nil
+		}
+
+		for i := range limits {
+			limits[i].Tokens--
+		}
+		return repo.SaveTokens(limits)
+	})
+	if err != nil {
+		return time.Time{}, 0, nil, err
+	}
+	return now, wait, blocking, nil
+}
+
+func recipientDomain(recipient string) string {
+	if i := strings.LastIndexByte(recipient, '@'); i >= 0 {
+		return strings.ToLower(strings.TrimSpace(recipient[i+1:]))
+	}
+	return ""
+}
+
+// refill adds the tokens earned since the bucket was last refilled.
+func refill(limit *models.RateLimit, now time.Time) {
+	elapsed := now.Sub(limit.RefilledAt)
+	if elapsed <= 0 {
+		return
+	}
+	limit.Tokens = math.Min(float64(limit.Burst), limit.Tokens+elapsed.Minutes()*float64(limit.Rate))
+	limit.RefilledAt = now
+}
+
+// normalizeRateLimit checks a new limit and starts it with a full bucket at
+// now. Keys are lower-cased; a domain may be given as @example.com.
+func normalizeRateLimit(limit *models.RateLimit, now time.Time) error {
+	scope := strings.ToLower(strings.TrimSpace(limit.Scope))
+	key := strings.ToLower(strings.TrimSpace(limit.Key))
+	switch scope {
+	case models.RateLimitScopeGlobal:
+		if key != "" {
+			return fmt.Errorf("%w: the global limit has no key", ErrInvalidRateLimit)
+		}
+	case models.RateLimitScopeDomain:
+		key = strings.TrimPrefix(key, "@")
+		if len(key) > 253 || !domainPattern.MatchString(key) {
+			return fmt.Errorf("%w: %q is not a domain", ErrInvalidRateLimit, limit.Key)
+		}
+	case models.RateLimitScopeSender:
+		if !strings.Contains(key, "@") || len(key) > 255 {
+			return fmt.Errorf("%w: %q is not an email address", ErrInvalidRateLimit, limit.Key)
+		}
+	default:
+		return fmt.Errorf("%w: scope must be global, domain or sender", ErrInvalidRateLimit)
+	}
+	if err := validateRate(limit); err != nil {
+		return err
+	}
+
+	limit.ID = 0
+	limit.Scope = scope
+	limit.Key = key
+	limit.Tokens = float64(limit.Burst)
+	limit.RefilledAt = now
+	limit.CreatedAt = now
+	limit.UpdatedAt = now
+	return nil
+}
+
+// validateRate checks the rate and burst of a limit, defaulting the burst
+// to one second's worth of the rate.
+func validateRate(limit *models.RateLimit) error {
+	if limit.Rate <= 0 {
+		return fmt.Errorf("%w: rate must be at least 1 a minute", ErrInvalidRateLimit)
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = defaultBurst(limit.Rate)
+	}
+	return nil
+}
+
+func defaultBurst(rate int) int {
+	return max(1, (rate+59)/60)
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRetryBackoff    = 5 * time.Minute
+	defaultMaxRetryBackoff = 6 * time.Hour
+)
+
+// retryPolicy decides whether and when a failed job is tried again. Delays
+// grow exponentially with half of each delay randomised, so jobs that
+// failed together, say during a provider outage, do not all retry at once.
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func newRetryPolicy(cfg config.QueueConfig) retryPolicy {
+	policy := retryPolicy{
+		maxRetries: max(cfg.MaxRetries, 0),
+		backoff:    cfg.RetryBackoff,
+		maxBackoff: cfg.MaxRetryBackoff,
+	}
+	if policy.backoff <= 0 {
+		policy.backoff = defaultRetryBackoff
+	}
+	if policy.maxBackoff <= 0 {
+		policy.maxBackoff = defaultMaxRetryBackoff
+	}
+	policy.maxBackoff = max(policy.maxBackoff, policy.backoff)
+	return policy
+}
+
+// maxAttempts is the first attempt and every retry.
+func (p retryPolicy) maxAttempts() int {
+	return p.maxRetries + 1
+}
+
+// next returns when to retry a job that has failed after the given number
+// of attempts, or false when it should be dead-lettered.
+func (p retryPolicy) next(attempts int, err error) (time.Time, bool) {
+	if isPermanentFailure(err) || attempts >= p.maxAttempts() {
+		return time.Time{}, false
+	}
+	return time.Now().Add(p.delay(attempts)), true
+}
+
+func (p retryPolicy) delay(attempts int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < attempts && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.maxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// unsendable marks a job that cannot be built into a message, such as one
+// whose template does not parse. Retrying will not help until it is fixed,
+// so such jobs go straight to the dead-letter queue.
+func unsendable(err error) error {
+	return &email.PermanentError{Err: err}
+}
+
+// isPermanentFailure reports whether a job failed in a way that retrying
+// cannot fix: the provider refused it for good, it cannot be built, or what
+// it was to send has been deleted.
+func isPermanentFailure(err error) bool {
+	return email.IsPermanentError(err) || errors.Is(err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/internal/segment"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSegmentPreviewSize = 20
+	maxSegmentPreviewSize     = 100
+)
+
+var (
+	ErrSegmentNotFound = errors.New("segment not found")
+	ErrInvalidSegment  = errors.New("invalid segment")
+)
+
+type SegmentService struct {
+	repo          *repositories.SegmentRepository
+	contactRepo   *repositories.ContactRepository
+	attributeRepo *repositories.AttributeRepository
+}
+
+func NewSegmentService(db *gorm.DB) *SegmentService {
+	return &SegmentService{
+		repo:          repositories.NewSegmentRepository(db),
+		contactRepo:   repositories.NewContactRepository(db),
+		attributeRepo: repositories.NewAttributeRepository(db),
+	}
+}
+
+type SegmentPreview struct {
+	Query      string           `json:"query"`
+	Matched    int64            `json:"matched"`
+	Subscribed int64            `json:"subscribed"`
+	Contacts   []models.Contact `json:"contacts"`
+}
+
+func (s *SegmentService) CreateSegment(seg *models.Segment) (*models.Segment, error) {
+	if err := s.validateSegment(seg); err != nil {
+		return nil, err
+	}
+
+	createdSegment, err := s.repo.CreateSegment(seg)
+	if err != nil {
+		return nil, err
+	}
+	return &createdSegment, nil
+}
+
+func (s *SegmentService) GetAllSegments() ([]models.Segment, error) {
+	segments, err := s.repo.GetAllSegments()
+	if err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+func (s *SegmentService) GetSegmentByID(id uint) (*models.Segment, error) {
+	seg, err := s.repo.GetSegmentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if seg.ID == 0 {
+		return nil, ErrSegmentNotFound
+	}
+	return seg, nil
+}
+
+func (s *SegmentService) UpdateSegment(id uint, seg *models.Segment) (*models.Segment, error) {
+	existingSegment, err := s.GetSegmentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.validateSegment(seg); err != nil {
+		return nil, err
+	}
+
+	existingSegment.Name = seg.Name
+	existingSegment.Description = seg.Description
+	existingSegment.Query = seg.Query
+	existingSegment.UpdatedAt = time.Now()
+
+	updatedSegment, err := s.repo.UpdateSegment(existingSegment)
+	if err != nil {
+		return nil, err
+	}
+	return &updatedSegment, nil
+}
+
+func (s *SegmentService) DeleteSegment(id uint) error {
+	if _, err := s.GetSegmentByID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSegment(id)
+}
+
+// PreviewQuery evaluates a segment query, saved or not, and reports how many
+// contacts match along with a sample of them.
+func (s *SegmentService) PreviewQuery(query string, limit int) (*SegmentPreview, error) {
+	compiled, err := compileSegmentQuery(s.attributeRepo, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit < 1 {
+		limit = defaultSegmentPreviewSize
+	}
+	limit = min(limit, maxSegmentPreviewSize)
+
+	contacts, matched, subscribed, err := s.contactRepo.PreviewSegment(compiled, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &SegmentPreview{
+		Query:      query,
+		Matched:    matched,
+		Subscribed: subscribed,
+		Contacts:   contacts,
+	}, nil
+}
+
+func (s *SegmentService) PreviewSegment(id uint, limit int) (*SegmentPreview, error) {
+	seg, err := s.GetSegmentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.PreviewQuery(seg.Query, limit)
+}
+
+// CompileAudience compiles the segment targeted by a campaign or broadcast.
+// It runs at send time so relative conditions such as now-30d stay fresh.
+func (s *SegmentService) CompileAudience(id *uint) (*segment.Query, error) {
+	return compileAudienceSegment(s.repo, s.attributeRepo, id)
+}
+
+func (s *SegmentService) validateSegment(seg *models.Segment) error {
+	seg.Name = strings.TrimSpace(seg.Name)
+	if seg.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSegment)
+	}
+	_, err := compileSegmentQuery(s.attributeRepo, seg.Query)
+	return err
+}
+
+// compileSegmentQuery compiles a query with the declared attribute types.
+func compileSegmentQuery(attributeRepo *repositories.AttributeRepository, query string) (*segment.Query, error) {
+	schema, err := loadAttributeSchema(attributeRepo)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := segment.Compile(query, schema.types())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSegment, err)
+	}
+	return compiled, nil
+}
+
+// resolveSegment checks the segment targeted by a campaign or broadcast
+// exists. A zero ID is treated as no segment.
+func resolveSegment(repo *repositories.SegmentRepository, id *uint) (*uint, error) {
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	seg, err := repo.GetSegmentByID(*id)
+	if err != nil {
+		return nil, err
+	}
+	if seg.ID == 0 {
+		return nil, fmt.Errorf("%w: segment %d", ErrSegmentNotFound, *id)
+	}
+	return id, nil
+}
+
+func compileAudienceSegment(repo *repositories.SegmentRepository, attributeRepo *repositories.AttributeRepository, id *uint) (*segment.Query, error) {
+	if id == nil {
+		return nil, nil
+	}
+	seg, err := repo.GetSegmentByID(*id)
+	if err != nil {
+		return nil, err
+	}
+	if seg.ID == 0 {
+		return nil, fmt.Errorf("%w: segment %d", ErrSegmentNotFound, *id)
+	}
+	return compileSegmentQuery(attributeRepo, seg.Query)
+}
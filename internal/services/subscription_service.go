@@ -0,0 +1,714 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"gorm.io/gorm"
+)
+
+const (
+	unsubscribeTokenPurpose = "unsubscribe"
+	preferencesTokenPurpose = "preferences"
+	confirmTokenPurpose     = "confirm"
+
+	// maxPauseDays bounds how long a contact can pause their mail for.
+	maxPauseDays = 365
+)
+
+var (
+	ErrContactUnsubscribed = errors.New("contact has unsubscribed or is blocklisted")
+	ErrContactPaused       = errors.New("contact has paused their mail")
+	ErrContactUnconfirmed  = errors.New("contact has not confirmed their subscription")
+	ErrInvalidPreferences  = errors.New("invalid preferences")
+	ErrInvalidSignup       = errors.New("invalid signup")
+)
+
+// UnsubscribeToken identifies the contact an unsubscribe link was sent to and
+// the campaign or broadcast it came from; the unused one is zero.
+type UnsubscribeToken struct {
+	ContactID   uint
+	CampaignID  uint
+	BroadcastID uint
+}
+
+// RequestInfo describes where a public request came from, for the audit log.
+type RequestInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Preferences is what a contact sees in the preference center.
+type Preferences struct {
+	Email        string           `json:"email"`
+	FirstName    string           `json:"first_name"`
+	LastName     string           `json:"last_name"`
+	Attributes   models.JSONMap   `json:"attributes"`
+	Unsubscribed bool             `json:"unsubscribed"`
+	PausedUntil  *time.Time       `json:"paused_until"`
+	Lists        []ListPreference `json:"lists"`
+}
+
+type ListPreference struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Subscribed  bool   `json:"subscribed"`
+}
+
+// PreferenceUpdate holds the changes a contact makes in the preference
+// center. Omitted fields are left alone. Attributes are merged into the
+// existing ones, and a null value removes an attribute. PauseDays pauses all
+// mail for that many days, or resumes it when 0.
+type PreferenceUpdate struct {
+	FirstName    *string          `json:"first_name"`
+	LastName     *string          `json:"last_name"`
+	Attributes   models.JSONMap   `json:"attributes"`
+	Lists        []ListPreference `json:"lists"`
+	PauseDays    *int             `json:"pause_days"`
+	Unsubscribed *bool            `json:"unsubscribed"`
+}
+
+// SignupRequest is a public signup to one or more public lists.
+type SignupRequest struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	ListIDs   []uint `json:"list_ids"`
+}
+
+type SubscriptionService struct {
+	db            *gorm.DB
+	mailService   *MailService
+	contactRepo   *repositories.ContactRepository
+	listRepo      *repositories.ListRepository
+	attributeRepo *repositories.AttributeRepository
+	auditRepo     *repositories.PreferenceAuditRepository
+	links         *subscriptionLinks
+	optIn         config.OptInConfig
+}
+
+func NewSubscriptionService(db *gorm.DB, mailService *MailService, cfg config.Config) *SubscriptionService {
+	return &SubscriptionService{
+		db:            db,
+		mailService:   mailService,
+		contactRepo:   repositories.NewContactRepository(db),
+		listRepo:      repositories.NewListRepository(db),
+		attributeRepo: repositories.NewAttributeRepository(db),
+		auditRepo:     repositories.NewPreferenceAuditRepository(db),
+		links:         newSubscriptionLinks(cfg),
+		optIn:         cfg.OptIn,
+	}
+}
+
+// GetPublicLists returns the lists offered on signup forms.
+func (s *SubscriptionService) GetPublicLists() ([]models.List, error) {
+	return s.listRepo.GetPublicLists()
+}
+
+// Signup subscribes an address to public lists. Double opt-in lists wait for
+// the address to be confirmed through an emailed link, as does every list
+// when the contact is unconfirmed or unsubscribed before. It reports whether
+// a confirmation email was sent. Blocklisted addresses are silently ignored
+// so the response does not reveal them.
+func (s *SubscriptionService) Signup(req SignupRequest, info RequestInfo) (bool, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return false, fmt.Errorf("%w: a valid email address is required", ErrInvalidSignup)
+	}
+	lists, err := s.signupLists(req.ListIDs)
+	if err != nil {
+		return false, err
+	}
+
+	contact, err := s.contactRepo.GetContactByEmail(address.Address)
+	if err != nil {
+		return false, err
+	}
+	if contact.Status == models.ContactStatusBlocklisted {
+		return true, nil
+	}
+
+	isNew := contact.ID == 0
+	current := map[uint]string{}
+	if !isNew {
+		memberships, err := s.listRepo.GetContactMemberships(contact.ID)
+		if err != nil {
+			return false, err
+		}
+		for _, membership := range memberships {
+			current[membership.ID] = membership.Status
+		}
+	}
+	confirmContact := !isNew && (contact.UnSubscribe || contact.Status == models.ContactStatusUnconfirmed)
+
+	changes := make(map[uint]string, len(lists))
+	var pending []string
+	for _, list := range lists {
+		subscribed := current[list.ID] == models.ListContactStatusSubscribed
+		if subscribed && !confirmContact {
+			continue
+		}
+		if list.OptIn == models.ListOptInDouble || confirmContact {
+			// An unsubscribed contact keeps their memberships; confirming
+			// subscribes them again.
+			if !subscribed {
+				changes[list.ID] = models.ListContactStatusUnconfirmed
+			}
+			pending = append(pending, list.Name)
+			continue
+		}
+		changes[list.ID] = models.ListContactStatusSubscribed
+	}
+	if len(changes) == 0 && len(pending) == 0 {
+		return false, nil
+	}
+
+	if isNew {
+		schema, err := loadAttributeSchema(s.attributeRepo)
+		if err != nil {
+			return false, err
+		}
+		contact.Email = address.Address
+		contact.FirstName = strings.TrimSpace(req.FirstName)
+		contact.LastName = strings.TrimSpace(req.LastName)
+		contact.Attributes = schema.withDefaults(models.JSONMap{})
+		contact.Status = models.ContactStatusEnabled
+		if len(pending) == len(changes) {
+			contact.Status = models.ContactStatusUnconfirmed
+		}
+	}
+	// The link is dated from the request, as is the contact's expiry, so
+	// both end together. Tokens carry whole seconds.
+	requestedAt := time.Now().Truncate(time.Second)
+	if len(pending) > 0 {
+		contact.ConfirmRequestedAt = &requestedAt
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		contactRepo := repositories.NewContactRepository(tx)
+		if isNew {
+			if _, err := contactRepo.CreateContact(contact); err != nil {
+				return err
+			}
+		} else if len(pending) > 0 {
+			if err := contactRepo.RequestConfirmation(contact.ID, requestedAt); err != nil {
+				return err
+			}
+		}
+		listRepo := repositories.NewListRepository(tx)
+		for id, status := range changes {
+			if err := listRepo.SetContactStatus(id, contact.ID, status); err != nil {
+				return err
+			}
+		}
+		return repositories.NewPreferenceAuditRepository(tx).CreateAudits([]models.PreferenceAudit{
+			newPreferenceAudit(contact.ID, models.PreferenceActionSignup, models.PreferenceSourceSignupForm,
+				models.JSONMap{"list_ids": models.ListIDs(lists), "confirmation_required": len(pending) > 0}, info),
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("error saving signup: %w", err)
+	}
+
+	if len(pending) == 0 {
+		return false, nil
+	}
+	_, err = s.mailService.QueueTransactionalEmail(TransactionalEmail{
+		ToEmail:      contact.Email,
+		ToName:       contact.Name(),
+		Subject:      s.optIn.Subject,
+		TemplateName: s.optIn.TemplateName,
+		Data: map[string]interface{}{
+			"contact":    contact,
+			"lists":      pending,
+			"confirmURL": s.links.confirmURL(contact.ID, requestedAt),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error queueing confirmation email: %w", err)
+	}
+	return true, nil
+}
+
+// signupLists loads the requested lists, which must all be public.
+func (s *SubscriptionService) signupLists(ids []uint) ([]models.List, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: at least one list is required", ErrInvalidSignup)
+	}
+	lists, err := s.listRepo.GetListsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	public := make(map[uint]bool, len(lists))
+	for _, list := range lists {
+		public[list.ID] = list.Public
+	}
+	for _, id := range ids {
+		if !public[id] {
+			return nil, fmt.Errorf("%w: list %d not found", ErrInvalidSignup, id)
+		}
+	}
+	return lists, nil
+}
+
+// GetContactByConfirmToken returns the contact a confirmation link belongs
+// to, for the confirmation page.
+func (s *SubscriptionService) GetContactByConfirmToken(token string) (*models.Contact, error) {
+	contactID, err := s.links.parseConfirm(token, s.optIn.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	return s.getContact(contactID)
+}
+
+// Confirm activates the contact and the list memberships awaiting
+// confirmation. Following a link again is not an error.
+func (s *SubscriptionService) Confirm(token string, info RequestInfo) (*models.Contact, error) {
+	contact, err := s.GetContactByConfirmToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if contact.Status == models.ContactStatusBlocklisted {
+		return contact, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		listIDs, err := repositories.NewListRepository(tx).ConfirmContact(contact.ID)
+		if err != nil {
+			return err
+		}
+		if len(listIDs) == 0 && contact.Status != models.ContactStatusUnconfirmed && !contact.UnSubscribe {
+			return nil
+		}
+		if err = repositories.NewContactRepository(tx).ConfirmContact(contact.ID); err != nil {
+			return err
+		}
+		contact.Status = models.ContactStatusEnabled
+		contact.UnSubscribe = false
+		return repositories.NewPreferenceAuditRepository(tx).CreateAudits([]models.PreferenceAudit{
+			newPreferenceAudit(contact.ID, models.PreferenceActionConfirmed, models.PreferenceSourceConfirmLink,
+				models.JSONMap{"list_ids": listIDs}, info),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error confirming subscription: %w", err)
+	}
+	return contact, nil
+}
+
+// ExpireUnconfirmed removes signups that were not confirmed in time.
+func (s *SubscriptionService) ExpireUnconfirmed() {
+	before := time.Now().Add(-s.optIn.Expiry)
+
+	contacts, err := s.contactRepo.DeleteUnconfirmed(before)
+	if err != nil {
+		log.Printf("Error expiring unconfirmed contacts: %v\n", err)
+		return
+	}
+	memberships, err := s.listRepo.DeleteUnconfirmed(before)
+	if err != nil {
+		log.Printf("Error expiring unconfirmed list memberships: %v\n", err)
+		return
+	}
+	if contacts > 0 || memberships > 0 {
+		log.Printf("Expired %d unconfirmed contacts and %d unconfirmed list memberships\n", contacts, memberships)
+	}
+}
+
+// GetContactByToken returns the contact an unsubscribe link belongs to,
+// for the confirmation page.
+func (s *SubscriptionService) GetContactByToken(token string) (*models.Contact, error) {
+	parsed, err := s.links.parseUnsubscribe(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.getContact(parsed.ContactID)
+}
+
+// Unsubscribe unsubscribes the contact an unsubscribe link belongs to.
+// Following a link again is not an error.
+func (s *SubscriptionService) Unsubscribe(token string, info RequestInfo) (*models.Contact, error) {
+	parsed, err := s.links.parseUnsubscribe(token)
+	if err != nil {
+		return nil, err
+	}
+	contact, err := s.getContact(parsed.ContactID)
+	if err != nil {
+		return nil, err
+	}
+	if contact.UnSubscribe {
+		return contact, nil
+	}
+
+	changes := models.JSONMap{}
+	if parsed.CampaignID != 0 {
+		changes["campaign_id"] = parsed.CampaignID
+	}
+	if parsed.BroadcastID != 0 {
+		changes["broadcast_id"] = parsed.BroadcastID
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewContactRepository(tx).Unsubscribe(contact.ID); err != nil {
+			return err
+		}
+		return repositories.NewPreferenceAuditRepository(tx).CreateAudits([]models.PreferenceAudit{
+			newPreferenceAudit(contact.ID, models.PreferenceActionUnsubscribed, models.PreferenceSourceUnsubscribeLink, changes, info),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error unsubscribing contact: %w", err)
+	}
+	contact.UnSubscribe = true
+	return contact, nil
+}
+
+func (s *SubscriptionService) GetPreferences(token string) (*Preferences, error) {
+	contactID, err := s.links.parsePreferences(token)
+	if err != nil {
+		return nil, err
+	}
+	contact, err := s.getContact(contactID)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := s.listRepo.GetContactMemberships(contact.ID)
+	if err != nil {
+		return nil, err
+	}
+	return buildPreferences(contact, memberships), nil
+}
+
+// UpdatePreferences applies a contact's changes from the preference center
+// and records one audit entry per kind of change.
+func (s *SubscriptionService) UpdatePreferences(token string, update PreferenceUpdate, info RequestInfo) (*Preferences, error) {
+	contactID, err := s.links.parsePreferences(token)
+	if err != nil {
+		return nil, err
+	}
+	contact, err := s.getContact(contactID)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := s.listRepo.GetContactMemberships(contact.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	source := models.PreferenceSourcePreferenceCenter
+	var audits []models.PreferenceAudit
+	audit := func(action string, changes models.JSONMap) {
+		audits = append(audits, newPreferenceAudit(contact.ID, action, source, changes, info))
+	}
+
+	profile := models.JSONMap{}
+	if update.FirstName != nil && strings.TrimSpace(*update.FirstName) != contact.FirstName {
+		contact.FirstName = strings.TrimSpace(*update.FirstName)
+		profile["first_name"] = contact.FirstName
+	}
+	if update.LastName != nil && strings.TrimSpace(*update.LastName) != contact.LastName {
+		contact.LastName = strings.TrimSpace(*update.LastName)
+		profile["last_name"] = contact.LastName
+	}
+	if len(update.Attributes) > 0 {
+		changed, err := s.mergeAttributes(contact, update.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		if len(changed) > 0 {
+			profile["attributes"] = changed
+		}
+	}
+	if len(profile) > 0 {
+		audit(models.PreferenceActionProfileUpdated, profile)
+	}
+
+	if update.PauseDays != nil {
+		days := *update.PauseDays
+		if days < 0 || days > maxPauseDays {
+			return nil, fmt.Errorf("%w: pause_days must be between 0 and %d", ErrInvalidPreferences, maxPauseDays)
+		}
+		if days == 0 && contact.PausedUntil != nil {
+			contact.PausedUntil = nil
+			audit(models.PreferenceActionResumed, models.JSONMap{})
+		} else if days > 0 {
+			until := time.Now().AddDate(0, 0, days)
+			contact.PausedUntil = &until
+			audit(models.PreferenceActionPaused, models.JSONMap{"paused_until": until.UTC().Format(time.RFC3339), "days": days})
+		}
+	}
+
+	if update.Unsubscribed != nil && *update.Unsubscribed != contact.UnSubscribe {
+		contact.UnSubscribe = *update.Unsubscribed
+		if contact.UnSubscribe {
+			audit(models.PreferenceActionUnsubscribed, models.JSONMap{})
+		} else {
+			audit(models.PreferenceActionResubscribed, models.JSONMap{})
+		}
+	}
+
+	listChanges, err := diffListPreferences(memberships, update.Lists)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range listChanges {
+		action := models.PreferenceActionListUnsubscribed
+		if change.Subscribed {
+			action = models.PreferenceActionListSubscribed
+		}
+		audit(action, models.JSONMap{"list_id": change.ID, "list_name": change.Name})
+	}
+
+	if len(audits) == 0 {
+		return buildPreferences(contact, memberships), nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewContactRepository(tx).UpdatePreferences(contact); err != nil {
+			return err
+		}
+		listRepo := repositories.NewListRepository(tx)
+		for _, change := range listChanges {
+			status := models.ListContactStatusUnsubscribed
+			if change.Subscribed {
+				status = models.ListContactStatusSubscribed
+			}
+			if err := listRepo.SetContactStatus(change.ID, contact.ID, status); err != nil {
+				return err
+			}
+		}
+		return repositories.NewPreferenceAuditRepository(tx).CreateAudits(audits)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating preferences: %w", err)
+	}
+
+	memberships, err = s.listRepo.GetContactMemberships(contact.ID)
+	if err != nil {
+		return nil, err
+	}
+	return buildPreferences(contact, memberships), nil
+}
+
+// GetPreferenceAudit returns the changes a contact made through public
+// links, newest first.
+func (s *SubscriptionService) GetPreferenceAudit(contactID uint) ([]models.PreferenceAudit, error) {
+	return s.auditRepo.GetAuditsByContact(contactID)
+}
+
+func (s *SubscriptionService) getContact(id uint) (*models.Contact, error) {
+	contact, err := s.contactRepo.GetContactByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if contact.ID == 0 {
+		return nil, ErrInvalidToken
+	}
+	return contact, nil
+}
+
+// mergeAttributes merges defined attributes into the contact and returns the
+// ones that changed. Contacts cannot set attributes that are not defined.
+func (s *SubscriptionService) mergeAttributes(contact *models.Contact, attributes models.JSONMap) (models.JSONMap, error) {
+	schema, err := loadAttributeSchema(s.attributeRepo)
+	if err != nil {
+		return nil, err
+	}
+	// coerce drops empty values, so note the keys asked for first.
+	requested := make([]string, 0, len(attributes))
+	for key := range attributes {
+		if _, ok := schema[key]; !ok {
+			return nil, fmt.Errorf("%w: unknown attribute %s", ErrInvalidAttribute, key)
+		}
+		requested = append(requested, key)
+	}
+	if err = schema.coerce(attributes); err != nil {
+		return nil, err
+	}
+
+	merged := make(models.JSONMap, len(contact.Attributes)+len(attributes))
+	for key, value := range contact.Attributes {
+		merged[key] = value
+	}
+	changed := models.JSONMap{}
+	for _, key := range requested {
+		value, set := attributes[key]
+		if !set {
+			if _, ok := merged[key]; ok {
+				delete(merged, key)
+				changed[key] = nil
+			}
+			continue
+		}
+		if fmt.Sprint(merged[key]) != fmt.Sprint(value) {
+			merged[key] = value
+			changed[key] = value
+		}
+	}
+	if err = schema.complete(merged); err != nil {
+		return nil, err
+	}
+	contact.Attributes = merged
+	return changed, nil
+}
+
+// diffListPreferences checks the requested list changes against the lists
+// the contact can see and returns the ones that change a status. Contacts can
+// join public lists and leave or rejoin lists they are a member of.
+func diffListPreferences(memberships []models.ListMembership, requested []ListPreference) ([]ListPreference, error) {
+	byID := make(map[uint]models.ListMembership, len(memberships))
+	for _, membership := range memberships {
+		byID[membership.ID] = membership
+	}
+
+	var changes []ListPreference
+	seen := make(map[uint]bool, len(requested))
+	for _, pref := range requested {
+		membership, ok := byID[pref.ID]
+		if !ok {
+			return nil, fmt.Errorf("%w: list %d not found", ErrInvalidPreferences, pref.ID)
+		}
+		if seen[pref.ID] {
+			continue
+		}
+		seen[pref.ID] = true
+
+		if pref.Subscribed == (membership.Status == models.ListContactStatusSubscribed) {
+			continue
+		}
+		changes = append(changes, ListPreference{
+			ID:         membership.ID,
+			Name:       membership.Name,
+			Subscribed: pref.Subscribed,
+		})
+	}
+	return changes, nil
+}
+
+func buildPreferences(contact *models.Contact, memberships []models.ListMembership) *Preferences {
+	lists := make([]ListPreference, len(memberships))
+	for i, membership := range memberships {
+		lists[i] = ListPreference{
+			ID:          membership.ID,
+			Name:        membership.Name,
+			Description: membership.Description,
+			Subscribed:  membership.Status == models.ListContactStatusSubscribed,
+		}
+	}
+	attributes := contact.Attributes
+	if attributes == nil {
+		attributes = models.JSONMap{}
+	}
+	return &Preferences{
+		Email:        contact.Email,
+		FirstName:    contact.FirstName,
+		LastName:     contact.LastName,
+		Attributes:   attributes,
+		Unsubscribed: contact.UnSubscribe,
+		PausedUntil:  contact.PausedUntil,
+		Lists:        lists,
+	}
+}
+
+func newPreferenceAudit(contactID uint, action, source string, changes models.JSONMap, info RequestInfo) models.PreferenceAudit {
+	userAgent := info.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return models.PreferenceAudit{
+		ContactID: contactID,
+		Action:    action,
+		Source:    source,
+		Changes:   changes,
+		IPAddress: info.IPAddress,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+}
+
+// subscriptionLinks builds the signed unsubscribe and preference center
+// links, and the unsubscribe headers, added to campaign and broadcast mail.
+type subscriptionLinks struct {
+	baseURL string
+	signer  *tokenSigner
+}
+
+func newSubscriptionLinks(cfg config.Config) *subscriptionLinks {
+	return &subscriptionLinks{
+		baseURL: strings.TrimRight(cfg.App.BaseURL, "/"),
+		signer:  newTokenSigner(cfg),
+	}
+}
+
+func (l *subscriptionLinks) unsubscribeURL(token UnsubscribeToken) string {
+	return l.baseURL + "/api/public/unsubscribe/" +
+		l.signer.sign(unsubscribeTokenPurpose, token.ContactID, token.CampaignID, token.BroadcastID)
+}
+
+func (l *subscriptionLinks) preferencesURL(contactID uint) string {
+	return l.baseURL + "/api/public/preferences/" + l.signer.sign(preferencesTokenPurpose, contactID)
+}
+
+func (l *subscriptionLinks) confirmURL(contactID uint, issuedAt time.Time) string {
+	return l.baseURL + "/api/public/confirm/" + l.signer.sign(confirmTokenPurpose, contactID, uint(issuedAt.Unix()))
+}
+
+func (l *subscriptionLinks) parseUnsubscribe(token string) (UnsubscribeToken, error) {
+	ids, err := l.signer.verify(unsubscribeTokenPurpose, token, 3)
+	if err != nil {
+		return UnsubscribeToken{}, err
+	}
+	return UnsubscribeToken{ContactID: ids[0], CampaignID: ids[1], BroadcastID: ids[2]}, nil
+}
+
+func (l *subscriptionLinks) parsePreferences(token string) (uint, error) {
+	ids, err := l.signer.verify(preferencesTokenPurpose, token, 1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// parseConfirm returns the contact of a confirmation link issued less than
+// expiry ago. Links are dated from the contact's confirm_requested_at, the
+// time DeleteUnconfirmed expires the contact from.
+func (l *subscriptionLinks) parseConfirm(token string, expiry time.Duration) (uint, error) {
+	ids, err := l.signer.verify(confirmTokenPurpose, token, 2)
+	if err != nil {
+		return 0, err
+	}
+	if time.Since(time.Unix(int64(ids[1]), 0)) > expiry {
+		return 0, ErrInvalidToken
+	}
+	return ids[0], nil
+}
+
+// headers returns the RFC 2369 List-Unsubscribe header and the RFC 8058
+// header announcing that a POST to the link unsubscribes in one click.
+func (l *subscriptionLinks) headers(url string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + url + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// checkCanReceive reports why bulk mail may not be sent to the contact.
+func checkCanReceive(contact *models.Contact) error {
+	if contact.UnSubscribe || contact.Status == models.ContactStatusBlocklisted {
+		return ErrContactUnsubscribed
+	}
+	if contact.Status == models.ContactStatusUnconfirmed {
+		return ErrContactUnconfirmed
+	}
+	if contact.PausedUntil != nil && contact.PausedUntil.After(time.Now()) {
+		return ErrContactPaused
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSuppressionPageSize = 50
+	maxSuppressionPageSize     = 1000
+	// maxSuppressionImport bounds the entries accepted in one bulk import.
+	maxSuppressionImport = 100000
+)
+
+var (
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	ErrInvalidSuppression  = errors.New("invalid suppression")
+	ErrRecipientSuppressed = errors.New("recipient is suppressed")
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9-]{2,63}$`)
+
+type SuppressionService struct {
+	repo *repositories.SuppressionRepository
+}
+
+func NewSuppressionService(db *gorm.DB) *SuppressionService {
+	return &SuppressionService{
+		repo: repositories.NewSuppressionRepository(db),
+	}
+}
+
+type Suppressions struct {
+	Suppressions []models.Suppression `json:"suppressions"`
+	Total        int64                `json:"total"`
+	Page         int                  `json:"page"`
+	PerPage      int                  `json:"per_page"`
+}
+
+// SuppressionImportResult reports a bulk import. Invalid entries are listed
+// by their 1-based position in the input.
+type SuppressionImportResult struct {
+	Added   int64                    `json:"added"`
+	Skipped int64                    `json:"skipped"`
+	Invalid []SuppressionImportError `json:"invalid"`
+}
+
+type SuppressionImportError struct {
+	Line  int    `json:"line"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+func (s *SuppressionService) CreateSuppression(suppression *models.Suppression) (*models.Suppression, error) {
+	if err := normalizeSuppression(suppression, models.SuppressionSourceAPI); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetSuppression(suppression.Type, suppression.Value)
+	if err != nil {
+		return nil, err
+	}
+	if existing.ID != 0 {
+		return nil, fmt.Errorf("%w: %s is already suppressed", ErrInvalidSuppression, suppression.Value)
+	}
+
+	createdSuppression, err := s.repo.CreateSuppression(suppression)
+	if err != nil {
+		return nil, err
+	}
+	return &createdSuppression, nil
+}
+
+func (s *SuppressionService) GetSuppressions(suppressionType, search string, page, perPage int) (*Suppressions, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultSuppressionPageSize
+	}
+	perPage = min(perPage, maxSuppressionPageSize)
+
+	suppressions, total, err := s.repo.GetSuppressions(strings.ToLower(suppressionType), search, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &Suppressions{
+		Suppressions: suppressions,
+		Total:        total,
+		Page:         page,
+		PerPage:      perPage,
+	}, nil
+}
+
+func (s *SuppressionService) GetSuppressionByID(id uint) (*models.Suppression, error) {
+	suppression, err := s.repo.GetSuppressionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if suppression.ID == 0 {
+		return nil, ErrSuppressionNotFound
+	}
+	return suppression, nil
+}
+
+// UpdateSuppression changes the reason and details. To suppress another
+// address, delete the entry and create a new one.
+func (s *SuppressionService) UpdateSuppression(id uint, suppression *models.Suppression) (*models.Suppression, error) {
+	existingSuppression, err := s.GetSuppressionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	reason, err := normalizeReason(suppression.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	existingSuppression.Reason = reason
+	existingSuppression.Details = truncate(suppression.Details, 1000)
+	existingSuppression.UpdatedAt = time.Now()
+
+	updatedSuppression, err := s.repo.UpdateSuppression(existingSuppression)
+	if err != nil {
+		return nil, err
+	}
+	return &updatedSuppression, nil
+}
+
+func (s *SuppressionService) DeleteSuppression(id uint) error {
+	if _, err := s.GetSuppressionByID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSuppression(id)
+}
+
+// ImportSuppressions adds suppressions in bulk. Invalid entries are reported
+// and skipped; entries already on the list are kept as they are.
+func (s *SuppressionService) ImportSuppressions(entries []models.Suppression) (*SuppressionImportResult, error) {
+	if len(entries) > maxSuppressionImport {
+		return nil, fmt.Errorf("%w: at most %d entries can be imported at once", ErrInvalidSuppression, maxSuppressionImport)
+	}
+
+	result := &SuppressionImportResult{Invalid: []SuppressionImportError{}}
+	valid := make([]models.Suppression, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		value := entry.Value
+		if err := normalizeSuppression(&entry, models.SuppressionSourceImport); err != nil {
+			result.Invalid = append(result.Invalid, SuppressionImportError{
+				Line:  i + 1,
+				Value: value,
+				Error: strings.TrimPrefix(err.Error(), ErrInvalidSuppression.Error()+": "),
+			})
+			continue
+		}
+		key := entry.Type + ":" + entry.Value
+		if seen[key] {
+			result.Skipped++
+			continue
+		}
+		seen[key] = true
+		valid = append(valid, entry)
+	}
+
+	added, err := s.repo.AddSuppressions(valid)
+	if err != nil {
+		return nil, err
+	}
+	result.Added = added
+	result.Skipped += int64(len(valid)) - added
+	return result, nil
+}
+
+// ParseSuppressionCSV reads one entry per row: the address or domain, then
+// optionally the reason and details. A header row is skipped. reason applies
+// to rows without one.
+func ParseSuppressionCSV(r io.Reader, reason string) ([]models.Suppression, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []models.Suppression
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSuppression, err)
+		}
+		if line == 1 && isSuppressionHeader(record[0]) {
+			continue
+		}
+		if len(entries) >= maxSuppressionImport {
+			return nil, fmt.Errorf("%w: at most %d entries can be imported at once", ErrInvalidSuppression, maxSuppressionImport)
+		}
+
+		entry := models.Suppression{Value: record[0], Reason: reason}
+		if len(record) > 1 && record[1] != "" {
+			entry.Reason = record[1]
+		}
+		if len(record) > 2 {
+			entry.Details = record[2]
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func isSuppressionHeader(cell string) bool {
+	switch strings.ToLower(strings.TrimSpace(cell)) {
+	case "email", "domain", "value", "address":
+		return true
+	}
+	return false
+}
+
+// normalizeSuppression lower-cases the value and infers the type when it is
+// missing: values with an @ are addresses, except @example.com which is
+// read as a domain.
+func normalizeSuppression(suppression *models.Suppression, source string) error {
+	value := strings.ToLower(strings.TrimSpace(suppression.Value))
+	suppressionType := strings.ToLower(strings.TrimSpace(suppression.Type))
+	if suppressionType == "" {
+		suppressionType = models.SuppressionTypeEmail
+		if !strings.Contains(value, "@") || strings.HasPrefix(value, "@") {
+			suppressionType = models.SuppressionTypeDomain
+		}
+	}
+
+	switch suppressionType {
+	case models.SuppressionTypeEmail:
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Address != value {
+			return fmt.Errorf("%w: %q is not an email address", ErrInvalidSuppression, suppression.Value)
+		}
+	case models.SuppressionTypeDomain:
+		value = strings.TrimPrefix(value, "@")
+		if len(value) > 253 || !domainPattern.MatchString(value) {
+			return fmt.Errorf("%w: %q is not a domain", ErrInvalidSuppression, suppression.Value)
+		}
+	default:
+		return fmt.Errorf("%w: type must be email or domain", ErrInvalidSuppression)
+	}
+
+	reason, err := normalizeReason(suppression.Reason)
+	if err != nil {
+		return err
+	}
+
+	suppression.ID = 0
+	suppression.Type = suppressionType
+	suppression.Value = value
+	suppression.Reason = reason
+	if suppression.Source == "" {
+		suppression.Source = source
+	}
+	suppression.Details = truncate(suppression.Details, 1000)
+	return nil
+}
+
+func normalizeReason(reason string) (string, error) {
+	reason = strings.ToLower(strings.TrimSpace(reason))
+	switch reason {
+	case "":
+		return models.SuppressionReasonManual, nil
+	case models.SuppressionReasonManual, models.SuppressionReasonHardBounce, models.SuppressionReasonComplaint:
+		return reason, nil
+	}
+	return "", fmt.Errorf("%w: reason must be manual, hard_bounce or complaint", ErrInvalidSuppression)
+}
+
+// checkSuppressed returns ErrRecipientSuppressed with the reason when the
+// address or its domain is on the suppression list.
+func checkSuppressed(repo *repositories.SuppressionRepository, email string) error {
+	suppression, err := repo.FindMatch(email)
+	if err != nil {
+		return fmt.Errorf("error checking suppression list: %w", err)
+	}
+	if suppression.ID == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s %s (%s)", ErrRecipientSuppressed, suppression.Type, suppression.Value, suppression.Reason)
+}
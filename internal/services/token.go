@@ -0,0 +1,75 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+)
+
+// tokenMACSize is the number of HMAC-SHA256 bytes kept in a token; 128 bits
+// is plenty against forgery and keeps links short.
+const tokenMACSize = 16
+
+var ErrInvalidToken = errors.New("invalid or expired link")
+
+// tokenSigner issues and checks the signed tokens embedded in links sent to
+// contacts. A token is a list of IDs in base 36 followed by a MAC over the
+// purpose and the IDs, so a token issued for one purpose is useless for
+// another and the IDs cannot be changed.
+type tokenSigner struct {
+	secret []byte
+}
+
+func newTokenSigner(cfg config.Config) *tokenSigner {
+	return &tokenSigner{secret: []byte(cfg.LinkSecret())}
+}
+
+func (t *tokenSigner) sign(purpose string, ids ...uint) string {
+	parts := make([]string, len(ids), len(ids)+1)
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 36)
+	}
+	payload := strings.Join(parts, ".")
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.mac(purpose, payload))
+}
+
+// verify checks a token issued for purpose and returns its count IDs.
+func (t *tokenSigner) verify(purpose, token string, count int) ([]uint, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return nil, ErrInvalidToken
+	}
+	payload, signature := token[:i], token[i+1:]
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, t.mac(purpose, payload)) {
+		return nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != count {
+		return nil, ErrInvalidToken
+	}
+	ids := make([]uint, count)
+	for i, part := range parts {
+		id, err := strconv.ParseUint(part, 36, 32)
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		ids[i] = uint(id)
+	}
+	return ids, nil
+}
+
+func (t *tokenSigner) mac(purpose, payload string) []byte {
+	h := hmac.New(sha256.New, t.secret)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return h.Sum(nil)[:tokenMACSize]
+}
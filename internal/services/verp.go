@@ -0,0 +1,102 @@
+package services
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+)
+
+const (
+	verpTokenPurpose = "verp"
+	// verpMACSize is smaller than in links: the MAC is hex encoded, since
+	// some servers change the case of the local part, and the whole local
+	// part must fit in 64 characters.
+	verpMACSize = 10
+)
+
+// VERPToken identifies the job and contact a message was sent for. JobID is
+// zero for mail sent without a queued job.
+type VERPToken struct {
+	JobID     uint
+	ContactID uint
+}
+
+// verpAddresses builds the per-message envelope senders used for VERP,
+// prefix+<job>-<contact>-<mac>@domain, and reads them back from bounces.
+type verpAddresses struct {
+	enabled bool
+	prefix  string
+	domain  string
+	signer  *tokenSigner
+}
+
+func newVERPAddresses(cfg config.Config) *verpAddresses {
+	domain := cfg.VERP.Domain
+	if domain == "" {
+		if i := strings.LastIndexByte(cfg.SMTP.FromAddr, '@'); i >= 0 {
+			domain = cfg.SMTP.FromAddr[i+1:]
+		}
+	}
+	prefix := cfg.VERP.Prefix
+	if prefix == "" {
+		prefix = "bounces"
+	}
+	return &verpAddresses{
+		enabled: cfg.VERP.Enabled && domain != "",
+		prefix:  strings.ToLower(prefix),
+		domain:  strings.ToLower(domain),
+		signer:  newTokenSigner(cfg),
+	}
+}
+
+// address returns the envelope sender for a message, or "" when VERP is off
+// and the default sender should be used.
+func (v *verpAddresses) address(token VERPToken) string {
+	if !v.enabled {
+		return ""
+	}
+	payload := strconv.FormatUint(uint64(token.JobID), 36) + "-" + strconv.FormatUint(uint64(token.ContactID), 36)
+	return v.prefix + "+" + payload + "-" + hex.EncodeToString(v.mac(payload)) + "@" + v.domain
+}
+
+// parse reads a VERP address in any case. It works while VERP is disabled,
+// so bounces for mail sent earlier are still attributed. Other addresses and
+// forged ones return ErrInvalidToken.
+func (v *verpAddresses) parse(address string) (VERPToken, error) {
+	address = strings.ToLower(strings.Trim(address, "<> "))
+	i := strings.LastIndexByte(address, '@')
+	if i < 0 || v.domain == "" || address[i+1:] != v.domain {
+		return VERPToken{}, ErrInvalidToken
+	}
+	local, ok := strings.CutPrefix(address[:i], v.prefix+"+")
+	if !ok {
+		return VERPToken{}, ErrInvalidToken
+	}
+
+	parts := strings.Split(local, "-")
+	if len(parts) != 3 {
+		return VERPToken{}, ErrInvalidToken
+	}
+	payload := parts[0] + "-" + parts[1]
+	mac, err := hex.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(mac, v.mac(payload)) {
+		return VERPToken{}, ErrInvalidToken
+	}
+
+	jobID, err := strconv.ParseUint(parts[0], 36, 32)
+	if err != nil {
+		return VERPToken{}, ErrInvalidToken
+	}
+	contactID, err := strconv.ParseUint(parts[1], 36, 32)
+	if err != nil {
+		return VERPToken{}, ErrInvalidToken
+	}
+	return VERPToken{JobID: uint(jobID), ContactID: uint(contactID)}, nil
+}
+
+func (v *verpAddresses) mac(payload string) []byte {
+	return v.signer.mac(verpTokenPurpose, payload)[:verpMACSize]
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/MdSadiqMd/Broadcast-API/internal/repositories"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/webhook"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWebhookEventPageSize = 50
+	maxWebhookEventPageSize     = 1000
+)
+
+var (
+	ErrWebhookProviderNotFound = errors.New("webhook provider not found")
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrInvalidWebhookPayload   = errors.New("invalid webhook payload")
+)
+
+// WebhookResult counts what happened to the events in one webhook request.
+type WebhookResult struct {
+	Processed int `json:"processed"`
+	Unmatched int `json:"unmatched"`
+	Unknown   int `json:"unknown"`
+	Failed    int `json:"failed"`
+}
+
+type WebhookEvents struct {
+	Events  []models.WebhookEvent `json:"events"`
+	Total   int64                 `json:"total"`
+	Page    int                   `json:"page"`
+	PerPage int                   `json:"per_page"`
+}
+
+// WebhookService applies delivery events reported by sending providers to
+// the jobs they refer to. Bounces and complaints go through DeliveryService,
+// so they suppress addresses exactly as bounces read from the mailbox do.
+// Events that cannot be applied are stored for inspection.
+type WebhookService struct {
+	db              *gorm.DB
+	jobRepo         *repositories.EmailJobRepository
+	contactRepo     *repositories.ContactRepository
+	eventRepo       *repositories.WebhookEventRepository
+	deliveryService *DeliveryService
+	providers       map[string]webhook.Provider
+}
+
+func NewWebhookService(db *gorm.DB, deliveryService *DeliveryService, providers map[string]webhook.Provider) *WebhookService {
+	return &WebhookService{
+		db:              db,
+		jobRepo:         repositories.NewEmailJobRepository(db),
+		contactRepo:     repositories.NewContactRepository(db),
+		eventRepo:       repositories.NewWebhookEventRepository(db),
+		deliveryService: deliveryService,
+		providers:       providers,
+	}
+}
+
+// HandleWebhook authenticates and applies one webhook request. An error is
+// only returned when the request itself is refused or events could not be
+// stored; the provider should then retry it. Events that fail individually
+// are stored with status failed instead, so one bad event does not make the
+// provider resend the whole batch. The events are applied and stored in one
+// transaction, so that a retried request does not record its bounces twice.
+func (s *WebhookService) HandleWebhook(provider string, header http.Header, body []byte) (*WebhookResult, error) {
+	provider = strings.ToLower(provider)
+	parser, ok := s.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookProviderNotFound, provider)
+	}
+	if err := parser.Verify(header, body); err != nil {
+		return nil, ErrInvalidWebhookSignature
+	}
+	events, err := parser.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookPayload, err)
+	}
+
+	result := &WebhookResult{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var stored []models.WebhookEvent
+		for _, event := range events {
+			// Each event has its own savepoint, so a failed one leaves the
+			// others applied.
+			var job *models.EmailJob
+			err := tx.Transaction(func(eventTx *gorm.DB) error {
+				service := s.withDB(eventTx)
+				var err error
+				if job, err = service.findJob(event.MessageIDs); err != nil {
+					return err
+				}
+				return service.apply(provider, event, job)
+			})
+
+			status := ""
+			switch {
+			case err != nil:
+				log.Printf("Error processing %s webhook event %s: %v", provider, event.ProviderType, err)
+				status = models.WebhookEventStatusFailed
+				result.Failed++
+			case event.Type == webhook.EventUnknown:
+				status = models.WebhookEventStatusUnknown
+				result.Unknown++
+			case job == nil:
+				status = models.WebhookEventStatusUnmatched
+				result.Unmatched++
+			default:
+				result.Processed++
+				continue
+			}
+			stored = append(stored, newWebhookEvent(provider, event, job, status, err))
+		}
+		return repositories.NewWebhookEventRepository(tx).CreateEvents(stored)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error storing webhook events: %w", err)
+	}
+	return result, nil
+}
+
+// withDB returns a copy of the service that works in tx.
+func (s *WebhookService) withDB(tx *gorm.DB) *WebhookService {
+	return &WebhookService{
+		db:              tx,
+		jobRepo:         repositories.NewEmailJobRepository(tx),
+		contactRepo:     repositories.NewContactRepository(tx),
+		eventRepo:       repositories.NewWebhookEventRepository(tx),
+		deliveryService: s.deliveryService.withDB(tx),
+		providers:       s.providers,
+	}
+}
+
+// apply updates the job and contact for one event. job is nil when the
+// message was not found; bounces and complaints are then still recorded
+// against the address.
+func (s *WebhookService) apply(provider string, event webhook.Event, job *models.EmailJob) error {
+	bounceEvent := BounceEvent{
+		Email:      event.Email,
+		Diagnostic: event.Reason,
+		Source:     provider,
+	}
+	if job != nil {
+		bounceEvent.JobID = job.ID
+		bounceEvent.MessageID = job.MessageID
+	}
+
+	switch event.Type {
+	case webhook.EventBounce:
+		bounceEvent.Type = event.BounceType
+		if _, err := s.deliveryService.RecordBounce(bounceEvent); err != nil && !ignorableBounceError(err, job) {
+			return err
+		}
+	case webhook.EventComplaint:
+		if _, err := s.deliveryService.RecordComplaint(bounceEvent); err != nil && !ignorableBounceError(err, job) {
+			return err
+		}
+	case webhook.EventDropped:
+		if job != nil {
+			message := "dropped by provider"
+			if event.Reason != "" {
+				message += ": " + event.Reason
+			}
+			return s.jobRepo.UpdateJobStatus(job.ID, models.EmailJobStatusRejected, truncate(message, 255))
+		}
+	case webhook.EventOpen:
+		if job != nil {
+			return s.jobRepo.MarkOpened(job.ID, event.OccurredAt)
+		}
+	case webhook.EventClick:
+		if job != nil {
+			return s.jobRepo.MarkClicked(job.ID, event.OccurredAt)
+		}
+	case webhook.EventUnsubscribe:
+		return s.unsubscribe(provider, event, job)
+	}
+	return nil
+}
+
+// ignorableBounceError reports whether a bounce failed only because an
+// unmatched event gave no usable address. Such events are stored as
+// unmatched rather than failed.
+func ignorableBounceError(err error, job *models.EmailJob) bool {
+	return job == nil && errors.Is(err, ErrInvalidBounce)
+}
+
+func (s *WebhookService) unsubscribe(provider string, event webhook.Event, job *models.EmailJob) error {
+	var contact *models.Contact
+	var err error
+	switch {
+	case job != nil && job.ContactID != nil:
+		contact, err = s.contactRepo.GetContactByID(*job.ContactID)
+	case event.Email != "":
+		contact, err = s.contactRepo.GetContactByEmail(strings.TrimSpace(event.Email))
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error loading contact: %w", err)
+	}
+	if contact.ID == 0 || contact.UnSubscribe {
+		return nil
+	}
+
+	changes := models.JSONMap{"provider": provider}
+	if job != nil {
+		changes["email_job_id"] = job.ID
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewContactRepository(tx).Unsubscribe(contact.ID); err != nil {
+			return err
+		}
+		return repositories.NewPreferenceAuditRepository(tx).CreateAudits([]models.PreferenceAudit{
+			newPreferenceAudit(contact.ID, models.PreferenceActionUnsubscribed, models.PreferenceSourceWebhook, changes, RequestInfo{}),
+		})
+	})
+}
+
+// findJob returns the job that sent a message, trying each ID the provider
+// gave, or nil when none matches.
+func (s *WebhookService) findJob(messageIDs []string) (*models.EmailJob, error) {
+	for _, messageID := range messageIDs {
+		job, err := s.jobRepo.GetJobByMessageID(messageID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading email job: %w", err)
+		}
+		if job.ID != 0 {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *WebhookService) GetEvents(provider, status string, page, perPage int) (*WebhookEvents, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultWebhookEventPageSize
+	}
+	perPage = min(perPage, maxWebhookEventPageSize)
+
+	events, total, err := s.eventRepo.GetEvents(strings.ToLower(provider), strings.ToLower(status), (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookEvents{
+		Events:  events,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}, nil
+}
+
+func newWebhookEvent(provider string, event webhook.Event, job *models.EmailJob, status string, err error) models.WebhookEvent {
+	stored := models.WebhookEvent{
+		Provider:     provider,
+		EventType:    event.Type,
+		ProviderType: truncate(event.ProviderType, 100),
+		Email:        truncate(event.Email, 255),
+		Status:       status,
+		Payload:      models.JSONMap(event.Payload),
+		OccurredAt:   event.OccurredAt,
+		CreatedAt:    time.Now(),
+	}
+	if len(event.MessageIDs) > 0 {
+		stored.MessageID = truncate(event.MessageIDs[0], 255)
+	}
+	if job != nil {
+		stored.EmailJobID = &job.ID
+	}
+	if err != nil {
+		stored.Error = truncate(err.Error(), 1000)
+	}
+	if stored.Payload == nil {
+		stored.Payload = models.JSONMap{}
+	}
+	return stored
+}
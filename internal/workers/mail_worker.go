@@ -1,40 +1,46 @@
 package workers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/MdSadiqMd/Broadcast-API/internal/models"
 	"github.com/MdSadiqMd/Broadcast-API/internal/services"
-	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
 	"gorm.io/gorm"
 )
 
 type MailWorker struct {
-	db          *gorm.DB
-	smtpClient  *email.SMTPClient
-	mailService *services.MailService
-	workerID    int
-	wg          *sync.WaitGroup
-	stopChan    chan struct{}
-	rateLimiter *RateLimiter
-	running     bool
-	mutex       sync.Mutex
+	db           *gorm.DB
+	mailService  *services.MailService
+	queueService *services.QueueService
+	workerID     int
+	minPriority  int
+	owner        string
+	wg           *sync.WaitGroup
+	stopChan     chan struct{}
+	wake         <-chan struct{}
+	running      bool
+	mutex        sync.Mutex
 }
 
-func NewMailWorker(db *gorm.DB, smtpClient *email.SMTPClient, workerID int, rateLimit int) *MailWorker {
-	mailService := services.NewMailService(db, smtpClient)
-
+// NewMailWorker creates a worker that sends queued jobs with at least
+// minPriority. It sleeps while there are none until wake receives, or for
+// the queue's poll interval.
+func NewMailWorker(db *gorm.DB, mailService *services.MailService, queueService *services.QueueService, wake <-chan struct{}, workerID int, minPriority int) *MailWorker {
 	return &MailWorker{
-		db:          db,
-		smtpClient:  smtpClient,
-		mailService: mailService,
-		workerID:    workerID,
-		wg:          &sync.WaitGroup{},
-		stopChan:    make(chan struct{}),
-		rateLimiter: NewRateLimiter(rateLimit),
-		running:     false,
+		db:           db,
+		mailService:  mailService,
+		queueService: queueService,
+		workerID:     workerID,
+		minPriority:  minPriority,
+		owner:        services.QueueOwner(fmt.Sprintf("worker-%d", workerID)),
+		wg:           &sync.WaitGroup{},
+		stopChan:     make(chan struct{}),
+		wake:         wake,
+		running:      false,
 	}
 }
 
@@ -77,89 +83,154 @@ func (w *MailWorker) processJobs() {
 		case <-w.stopChan:
 			return
 		default:
-			job, err := w.getNextJob()
+			jobs, err := w.queueService.ClaimJobs(w.owner, w.minPriority)
 			if err != nil {
-				time.Sleep(5 * time.Second)
+				log.Printf("Worker %d error claiming jobs: %v\n", w.workerID, err)
+			}
+			if len(jobs) == 0 {
+				w.idle()
 				continue
 			}
 
-			w.rateLimiter.Wait()
-			w.processJob(job)
+			w.processBatch(jobs)
 		}
 	}
 }
 
-func (w *MailWorker) getNextJob() (*models.EmailJob, error) {
-	var job models.EmailJob
-	err := w.db.Transaction(func(tx *gorm.DB) error {
-		result := tx.Where("status = ?", models.EmailJobStatusQueued).
-			Order("created_at asc").
-			First(&job)
-
-		if result.Error != nil {
-			return result.Error
+// processBatch sends a batch of claimed jobs in order while a heartbeat
+// keeps their leases alive. Each lease is renewed just before its job is
+// sent, and a job whose lease has been lost is skipped, since another
+// worker may already have it. Jobs not started when the worker stops are
+// released back to the queue.
+func (w *MailWorker) processBatch(jobs []models.EmailJob) {
+	held := newLeaseSet(jobs)
+	heartbeat := w.startHeartbeat(held)
+	defer heartbeat()
+
+	for i := range jobs {
+		job := &jobs[i]
+		select {
+		case <-w.stopChan:
+			if err := w.queueService.ReleaseJobs(w.owner, held.ids()); err != nil {
+				log.Printf("Worker %d error releasing jobs: %v\n", w.workerID, err)
+			}
+			return
+		default:
 		}
 
-		job.Status = models.EmailJobStatusSending
-		job.Attempts++
-		job.UpdatedAt = time.Now()
-
-		return tx.Save(&job).Error
-	})
+		renewed, err := w.queueService.RenewLeases(w.owner, []uint{job.ID})
+		if err != nil || renewed == 0 {
+			log.Printf("Worker %d lost its claim on job %d; skipping\n", w.workerID, job.ID)
+			held.remove(job.ID)
+			continue
+		}
+		w.processJob(job)
+		held.remove(job.ID)
+	}
+}
 
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, err
+// startHeartbeat renews the leases on held jobs well before they run out,
+// and returns a function that stops it.
+func (w *MailWorker) startHeartbeat(held *leaseSet) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.queueService.LeaseTimeout() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ids := held.ids()
+				renewed, err := w.queueService.RenewLeases(w.owner, ids)
+				if err != nil {
+					log.Printf("Worker %d error renewing leases: %v\n", w.workerID, err)
+				} else if renewed < int64(len(ids)) {
+					log.Printf("Worker %d lost %d of %d claimed jobs\n", w.workerID, int64(len(ids))-renewed, len(ids))
+				}
+			}
 		}
-		return nil, err
+	}()
+	return func() {
+		close(done)
+		<-stopped
 	}
+}
 
-	return &job, nil
+// idle waits until jobs are queued, the poll interval passes or the worker
+// is stopped.
+func (w *MailWorker) idle() {
+	timer := time.NewTimer(w.queueService.PollInterval())
+	defer timer.Stop()
+	select {
+	case <-w.stopChan:
+	case <-w.wake:
+	case <-timer.C:
+	}
 }
 
 func (w *MailWorker) processJob(job *models.EmailJob) {
 	log.Printf("Worker %d processing job %d\n", w.workerID, job.ID)
-	err := w.mailService.ProcessJob(job)
+	err := w.mailService.ProcessJob(w.owner, job)
 
+	var limited *services.RateLimitedError
+	if errors.As(err, &limited) {
+		log.Printf("Worker %d deferred job %d: %v\n", w.workerID, job.ID, limited)
+		if err = w.queueService.DeferJob(w.owner, job, limited); err != nil {
+			log.Printf("Worker %d error deferring job %d: %v\n", w.workerID, job.ID, err)
+		}
+		return
+	}
 	if err != nil {
 		log.Printf("Worker %d error processing job %d: %v\n", w.workerID, job.ID, err)
-		w.markJobAsFailed(job, err.Error())
+		w.markJobAsFailed(job, err)
 		return
 	}
 
 	log.Printf("Worker %d successfully processed job %d\n", w.workerID, job.ID)
 }
 
-func (w *MailWorker) markJobAsFailed(job *models.EmailJob, errorMessage string) {
-	if job.Attempts >= 3 {
-		job.Status = models.EmailJobStatusFailed
-	} else {
-		job.Status = models.EmailJobStatusQueued
-	}
-
-	job.StatusMessage = errorMessage
-	job.UpdatedAt = time.Now()
-
-	if err := w.db.Save(job).Error; err != nil {
+func (w *MailWorker) markJobAsFailed(job *models.EmailJob, cause error) {
+	next, err := w.queueService.FailJob(w.owner, job, cause)
+	if err != nil {
 		log.Printf("Worker %d error updating failed job status: %v\n", w.workerID, err)
+		return
+	}
+	if next == nil {
+		log.Printf("Worker %d moved job %d to the dead-letter queue\n", w.workerID, job.ID)
+		return
 	}
+	log.Printf("Worker %d will retry job %d at %s\n", w.workerID, job.ID, next.Format(time.RFC3339))
 }
 
-type RateLimiter struct {
-	ticker *time.Ticker
+// leaseSet is the claimed jobs a worker has not finished yet.
+type leaseSet struct {
+	mutex sync.Mutex
+	jobs  map[uint]struct{}
 }
 
-func NewRateLimiter(ratePerMinute int) *RateLimiter {
-	if ratePerMinute <= 0 {
-		ratePerMinute = 60
+func newLeaseSet(jobs []models.EmailJob) *leaseSet {
+	set := &leaseSet{jobs: make(map[uint]struct{}, len(jobs))}
+	for _, job := range jobs {
+		set.jobs[job.ID] = struct{}{}
 	}
+	return set
+}
 
-	interval := time.Minute / time.Duration(ratePerMinute)
-	return &RateLimiter{
-		ticker: time.NewTicker(interval),
-	}
+func (s *leaseSet) remove(id uint) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.jobs, id)
 }
 
-func (r *RateLimiter) Wait() {
-	<-r.ticker.C
+func (s *leaseSet) ids() []uint {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := make([]uint, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	return ids
 }
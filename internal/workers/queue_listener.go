@@ -0,0 +1,121 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const listenerRetryDelay = 5 * time.Second
+
+// QueueListener wakes idle workers as soon as jobs are queued. It holds one
+// connection per process that LISTENs for the notifications sent when a
+// job is queued, and passes each on to every subscribed worker. While the
+// connection is down it wakes them every few seconds instead, so that jobs
+// are still picked up promptly.
+type QueueListener struct {
+	dsn         string
+	subscribers []chan struct{}
+	mutex       sync.Mutex
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+func NewQueueListener(dsn string) *QueueListener {
+	return &QueueListener{
+		dsn: dsn,
+	}
+}
+
+// Subscribe returns a channel that receives a value when jobs may be
+// waiting. Wake-ups are not queued up: a worker that is busy receives at
+// most one once it is idle again.
+func (l *QueueListener) Subscribe() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	wake := make(chan struct{}, 1)
+	l.subscribers = append(l.subscribers, wake)
+	return wake
+}
+
+func (l *QueueListener) Start() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		l.run(ctx)
+	}()
+}
+
+func (l *QueueListener) Stop() {
+	l.mutex.Lock()
+	cancel, done := l.cancel, l.done
+	l.cancel = nil
+	l.mutex.Unlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (l *QueueListener) run(ctx context.Context) {
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Queue listener disconnected, reconnecting: %v\n", err)
+		l.wake()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenerRetryDelay):
+		}
+	}
+}
+
+func (l *QueueListener) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+models.EmailJobQueuedChannel); err != nil {
+		return err
+	}
+	// Jobs queued while not listening sent notifications no one heard.
+	l.wake()
+
+	for {
+		if _, err = conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		l.wake()
+	}
+}
+
+func (l *QueueListener) wake() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, wake := range l.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
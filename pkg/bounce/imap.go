@@ -0,0 +1,247 @@
+package bounce
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+)
+
+// imapTimeout bounds each command, so a stalled server cannot hang the
+// scheduler.
+const imapTimeout = 2 * time.Minute
+
+var (
+	imapLiteral = regexp.MustCompile(`\{(\d+)\}$`)
+	imapUID     = regexp.MustCompile(`\bUID (\d+)\b`)
+)
+
+// IMAPClient reads a mailbox over IMAP4rev1. Messages are fetched with
+// BODY.PEEK so reading them does not mark them seen; they are archived with
+// COPY and removed with \Deleted and EXPUNGE, which every server supports.
+type IMAPClient struct {
+	config MailboxConfig
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+func NewIMAPClient(config MailboxConfig) (*IMAPClient, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if config.Port == 0 {
+		config.Port = 143
+		if config.Security == email.SecurityTLS {
+			config.Port = 993
+		}
+	}
+	return &IMAPClient{config: config}, nil
+}
+
+// imapResponse is a server response line. Literals in the line are replaced
+// by their length marker in Text and collected in Literals.
+type imapResponse struct {
+	Text     string
+	Literals [][]byte
+}
+
+func (c *IMAPClient) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	conn, err := c.config.dial()
+	if err != nil {
+		return err
+	}
+	c.setConn(conn)
+
+	greeting, err := c.readResponse()
+	if err != nil {
+		return c.fail(fmt.Errorf("IMAP greeting error: %w", err))
+	}
+	if !strings.HasPrefix(greeting.Text, "* OK") {
+		return c.fail(fmt.Errorf("IMAP server refused connection: %s", greeting.Text))
+	}
+
+	if c.config.Security == email.SecurityStartTLS {
+		if _, err = c.command("STARTTLS"); err != nil {
+			return c.fail(err)
+		}
+		tlsConn, err := c.config.startTLS(conn)
+		if err != nil {
+			return c.fail(err)
+		}
+		c.setConn(tlsConn)
+	}
+
+	if _, err = c.command("LOGIN " + imapQuote(c.config.Username) + " " + imapQuote(c.config.Password)); err != nil {
+		return c.fail(err)
+	}
+	if _, err = c.command("SELECT " + imapQuote(c.config.Mailbox)); err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+func (c *IMAPClient) setConn(conn net.Conn) {
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+}
+
+func (c *IMAPClient) fail(err error) error {
+	c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Fetch returns up to limit messages, oldest first.
+func (c *IMAPClient) Fetch(limit int) ([]Message, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	responses, err := c.command("UID SEARCH ALL")
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, response := range responses {
+		if fields := strings.Fields(response.Text); len(fields) > 2 && fields[1] == "SEARCH" {
+			uids = append(uids, fields[2:]...)
+		}
+	}
+	if limit > 0 && len(uids) > limit {
+		uids = uids[:limit]
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	responses, err = c.command("UID FETCH " + strings.Join(uids, ",") + " (UID BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, len(uids))
+	for _, response := range responses {
+		match := imapUID.FindStringSubmatch(response.Text)
+		if match == nil || len(response.Literals) == 0 || !strings.Contains(response.Text, " FETCH ") {
+			continue
+		}
+		messages = append(messages, Message{ID: match[1], Raw: response.Literals[0]})
+	}
+	return messages, nil
+}
+
+// Remove archives the messages when configured to, then deletes them.
+func (c *IMAPClient) Remove(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	set := strings.Join(ids, ",")
+	if c.config.Action == ActionArchive {
+		_, err := c.command("UID COPY " + set + " " + imapQuote(c.config.ArchiveMailbox))
+		if err != nil && strings.Contains(err.Error(), "[TRYCREATE]") {
+			if _, err = c.command("CREATE " + imapQuote(c.config.ArchiveMailbox)); err == nil {
+				_, err = c.command("UID COPY " + set + " " + imapQuote(c.config.ArchiveMailbox))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.command("UID STORE " + set + ` +FLAGS.SILENT (\Deleted)`); err != nil {
+		return err
+	}
+	_, err := c.command("EXPUNGE")
+	return err
+}
+
+func (c *IMAPClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	_, err := c.command("LOGOUT")
+	c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// command sends a tagged command and returns the untagged responses that
+// came before its completion. A NO or BAD completion is returned as an error.
+func (c *IMAPClient) command(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := "A" + strconv.Itoa(c.tag)
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, fmt.Errorf("IMAP write error: %w", err)
+	}
+
+	var responses []imapResponse
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, fmt.Errorf("IMAP read error: %w", err)
+		}
+		if !strings.HasPrefix(response.Text, tag+" ") {
+			responses = append(responses, response)
+			continue
+		}
+
+		status := strings.TrimPrefix(response.Text, tag+" ")
+		if !strings.HasPrefix(status, "OK") {
+			// Keep the credentials out of errors and logs.
+			if strings.HasPrefix(command, "LOGIN ") {
+				command = "LOGIN"
+			}
+			return nil, fmt.Errorf("IMAP %s error: %s", command, status)
+		}
+		return responses, nil
+	}
+}
+
+// readResponse reads one response line, including any literals it carries.
+func (c *IMAPClient) readResponse() (imapResponse, error) {
+	var response imapResponse
+	var text strings.Builder
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return response, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		text.WriteString(line)
+
+		match := imapLiteral.FindStringSubmatch(line)
+		if match == nil {
+			response.Text = text.String()
+			return response, nil
+		}
+		size, err := strconv.Atoi(match[1])
+		if err != nil {
+			return response, fmt.Errorf("invalid literal size: %s", match[1])
+		}
+		literal := make([]byte, size)
+		if _, err = io.ReadFull(c.reader, literal); err != nil {
+			return response, err
+		}
+		response.Literals = append(response.Literals, literal)
+	}
+}
+
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
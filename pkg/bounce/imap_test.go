@@ -0,0 +1,152 @@
+package bounce
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// imapResponder answers the commands of IMAPClient like a server holding
+// the given messages, keyed by UID. The archive folder does not exist until
+// it is created.
+func imapResponder(messages map[string]string) func(line string) string {
+	archived := false
+	return func(line string) string {
+		tag, command, _ := strings.Cut(line, " ")
+		ok := tag + " OK done\r\n"
+		switch {
+		case strings.HasPrefix(command, "LOGIN "):
+			if command != `LOGIN "bounces" "secret"` {
+				return tag + " NO [AUTHENTICATIONFAILED] invalid credentials\r\n"
+			}
+			return ok
+		case command == `SELECT "INBOX"`:
+			return "* 2 EXISTS\r\n" + tag + " OK [READ-WRITE] SELECT completed\r\n"
+		case command == "UID SEARCH ALL":
+			return "* SEARCH 3 7\r\n" + ok
+		case strings.HasPrefix(command, "UID FETCH "):
+			var out strings.Builder
+			uids := strings.Fields(command)[2]
+			for i, uid := range strings.Split(uids, ",") {
+				message := messages[uid]
+				fmt.Fprintf(&out, "* %d FETCH (UID %s BODY[] {%d}\r\n%s)\r\n", i+1, uid, len(message), message)
+			}
+			out.WriteString("* 1 FETCH (FLAGS (\\Seen))\r\n")
+			return out.String() + ok
+		case strings.HasPrefix(command, "UID COPY "):
+			if !archived {
+				return tag + " NO [TRYCREATE] Mailbox does not exist\r\n"
+			}
+			return ok
+		case command == `CREATE "Bounces/Processed"`:
+			archived = true
+			return ok
+		case strings.HasPrefix(command, "UID STORE "):
+			return ok
+		case command == "EXPUNGE":
+			return "* 1 EXPUNGE\r\n* 1 EXPUNGE\r\n" + ok
+		case command == "LOGOUT":
+			return "* BYE logging out\r\n" + ok
+		}
+		return tag + " BAD unknown command\r\n"
+	}
+}
+
+func TestIMAPFetchAndRemove(t *testing.T) {
+	messages := map[string]string{
+		"3": "Subject: first\r\n\r\nbody one\r\n",
+		"7": "Subject: second\r\n\r\nbody ) with {5}\r\n",
+	}
+	server := newFakeServer(t, "* OK IMAP4rev1 ready\r\n", imapResponder(messages))
+
+	config := server.config()
+	config.Action = ActionArchive
+	config.ArchiveMailbox = "Bounces/Processed"
+	client, err := NewIMAPClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, err := client.Fetch(10)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(fetched) != 2 {
+		t.Fatalf("fetched %d messages, want 2", len(fetched))
+	}
+	for _, message := range fetched {
+		if string(message.Raw) != messages[message.ID] {
+			t.Errorf("message %s = %q, want %q", message.ID, message.Raw, messages[message.ID])
+		}
+	}
+
+	if err = client.Remove([]string{"3", "7"}); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err = client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	assertCommands(t, server.received(t), []string{
+		`A1 LOGIN "bounces" "secret"`,
+		`A2 SELECT "INBOX"`,
+		"A3 UID SEARCH ALL",
+		"A4 UID FETCH 3,7 (UID BODY.PEEK[])",
+		`A5 UID COPY 3,7 "Bounces/Processed"`,
+		`A6 CREATE "Bounces/Processed"`,
+		`A7 UID COPY 3,7 "Bounces/Processed"`,
+		`A8 UID STORE 3,7 +FLAGS.SILENT (\Deleted)`,
+		"A9 EXPUNGE",
+		"A10 LOGOUT",
+	})
+}
+
+func TestIMAPFetchLimit(t *testing.T) {
+	messages := map[string]string{"3": "Subject: first\r\n\r\nbody\r\n"}
+	server := newFakeServer(t, "* OK IMAP4rev1 ready\r\n", imapResponder(messages))
+
+	client, err := NewIMAPClient(server.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := client.Fetch(1)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(fetched) != 1 || fetched[0].ID != "3" {
+		t.Fatalf("fetched %+v, want message 3", fetched)
+	}
+	if err = client.Remove([]string{"3"}); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	client.Close()
+
+	commands := server.received(t)
+	if len(commands) < 4 || commands[3] != "A4 UID FETCH 3 (UID BODY.PEEK[])" {
+		t.Errorf("commands = %q", commands)
+	}
+	for _, command := range commands {
+		if strings.Contains(command, "COPY") {
+			t.Errorf("messages were archived with the delete action: %q", command)
+		}
+	}
+}
+
+func TestIMAPLoginFailure(t *testing.T) {
+	server := newFakeServer(t, "* OK IMAP4rev1 ready\r\n", imapResponder(nil))
+
+	config := server.config()
+	config.Password = "wrong"
+	client, err := NewIMAPClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Fetch(10)
+	if err == nil {
+		t.Fatal("Fetch succeeded with a rejected password")
+	}
+	if strings.Contains(err.Error(), "wrong") {
+		t.Errorf("the error reveals the password: %v", err)
+	}
+	server.received(t)
+}
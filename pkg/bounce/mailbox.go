@@ -0,0 +1,137 @@
+package bounce
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+)
+
+const (
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+)
+
+const (
+	ActionDelete  = "delete"
+	ActionArchive = "archive"
+)
+
+// Message is a message read from the bounce mailbox. ID is the IMAP UID or
+// the POP3 message number.
+type Message struct {
+	ID  string
+	Raw []byte
+}
+
+// Mailbox reads returned mail. Fetch connects on first use; Remove deletes
+// or archives messages, as configured, and Close ends the session. POP3
+// servers only apply deletions when the session is closed cleanly.
+type Mailbox interface {
+	Fetch(limit int) ([]Message, error)
+	Remove(ids []string) error
+	Close() error
+}
+
+type MailboxConfig struct {
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	Security       string
+	Mailbox        string
+	Action         string
+	ArchiveMailbox string
+	DialTimeout    time.Duration
+}
+
+func NewMailbox(cfg config.Config) (Mailbox, error) {
+	mailboxConfig := MailboxConfig{
+		Host:           cfg.Bounce.Host,
+		Port:           cfg.Bounce.Port,
+		Username:       cfg.Bounce.Username,
+		Password:       cfg.Bounce.Password,
+		Security:       cfg.Bounce.Security,
+		Mailbox:        cfg.Bounce.Mailbox,
+		Action:         cfg.Bounce.Action,
+		ArchiveMailbox: cfg.Bounce.ArchiveMailbox,
+		DialTimeout:    cfg.Bounce.DialTimeout,
+	}
+
+	switch strings.ToLower(cfg.Bounce.Protocol) {
+	case "", ProtocolIMAP:
+		return NewIMAPClient(mailboxConfig)
+	case ProtocolPOP3:
+		return NewPOP3Client(mailboxConfig)
+	default:
+		return nil, fmt.Errorf("unknown bounce mailbox protocol: %s", cfg.Bounce.Protocol)
+	}
+}
+
+func (c *MailboxConfig) validate() error {
+	if c.Host == "" {
+		return errors.New("bounce mailbox host is required")
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 10 * time.Second
+	}
+	if c.Mailbox == "" {
+		c.Mailbox = "INBOX"
+	}
+	c.Action = strings.ToLower(c.Action)
+	switch c.Action {
+	case "":
+		c.Action = ActionDelete
+	case ActionDelete:
+	case ActionArchive:
+		if c.ArchiveMailbox == "" {
+			return errors.New("bounce archive mailbox is required to archive messages")
+		}
+	default:
+		return fmt.Errorf("unknown bounce mailbox action: %s", c.Action)
+	}
+
+	switch strings.ToLower(c.Security) {
+	case "", email.SecurityPlain, email.SecurityTLS, email.SecurityStartTLS:
+		c.Security = strings.ToLower(c.Security)
+	default:
+		return fmt.Errorf("unknown bounce mailbox security: %s", c.Security)
+	}
+	return nil
+}
+
+func (c MailboxConfig) address() string {
+	return net.JoinHostPort(c.Host, fmt.Sprint(c.Port))
+}
+
+// dial connects to the server, wrapping the connection in TLS for implicit
+// TLS. STARTTLS is negotiated by the protocol client.
+func (c MailboxConfig) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.DialTimeout}
+	if c.Security == email.SecurityTLS {
+		conn, err := tls.DialWithDialer(dialer, "tcp", c.address(), &tls.Config{ServerName: c.Host})
+		if err != nil {
+			return nil, fmt.Errorf("bounce mailbox dial error: %w", err)
+		}
+		return conn, nil
+	}
+
+	conn, err := dialer.Dial("tcp", c.address())
+	if err != nil {
+		return nil, fmt.Errorf("bounce mailbox dial error: %w", err)
+	}
+	return conn, nil
+}
+
+func (c MailboxConfig) startTLS(conn net.Conn) (net.Conn, error) {
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: c.Host})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("bounce mailbox TLS handshake error: %w", err)
+	}
+	return tlsConn, nil
+}
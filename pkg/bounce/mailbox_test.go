@@ -0,0 +1,88 @@
+package bounce
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a mailbox server for one connection that answers each
+// command line with whatever respond returns, and records the commands.
+type fakeServer struct {
+	listener net.Listener
+	done     chan struct{}
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeServer(t *testing.T, greeting string, respond func(line string) string) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	s := &fakeServer{listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		defer close(s.done)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		conn.Write([]byte(greeting))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			s.mu.Lock()
+			s.commands = append(s.commands, line)
+			s.mu.Unlock()
+			if _, err = conn.Write([]byte(respond(line))); err != nil {
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) config() MailboxConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return MailboxConfig{
+		Host:        addr.IP.String(),
+		Port:        addr.Port,
+		Username:    "bounces",
+		Password:    "secret",
+		DialTimeout: time.Second,
+	}
+}
+
+// received waits for the client to hang up and returns its commands.
+func (s *fakeServer) received(t *testing.T) []string {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client did not close the connection")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+func assertCommands(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
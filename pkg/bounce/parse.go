@@ -0,0 +1,422 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+)
+
+const (
+	TypeHard      = "hard"
+	TypeSoft      = "soft"
+	TypeComplaint = "complaint"
+)
+
+const (
+	// maxPartDepth bounds nested multiparts in a report.
+	maxPartDepth = 5
+	// maxTextSize bounds the human-readable text kept from a report, which
+	// is all non-standard bounces can be recognised from.
+	maxTextSize = 64 << 10
+)
+
+var ErrNotBounce = errors.New("message is not a bounce")
+
+var (
+	statusPattern    = regexp.MustCompile(`\b[45]\.\d{1,3}\.\d{1,3}\b`)
+	smtpCodePattern  = regexp.MustCompile(`(?:^|[\s:(])([45]\d\d)[\s-]`)
+	messageIDPattern = regexp.MustCompile(`(?im)^\s*Message-ID:\s*<?([^>\s]+)>?`)
+	addressPattern   = regexp.MustCompile(`[A-Za-z0-9._%+'=-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	subjectPattern   = regexp.MustCompile(`(?i)undeliver|delivery status notification|delivery (has )?failed|delivery failure|failure notice|returned mail|mail delivery (failed|failure|system)|could not be delivered|non-?delivery`)
+	senderPattern    = regexp.MustCompile(`(?i)^(mailer-daemon|postmaster|mail delivery (subsystem|system))`)
+)
+
+// softKeywords and hardKeywords classify non-standard bounces that carry
+// no status code. Soft is checked first and is the default.
+var (
+	softKeywords = []string{"mailbox full", "quota", "insufficient", "temporar", "try again", "deferred", "delayed", "too many", "rate limit", "greylist"}
+	hardKeywords = []string{"user unknown", "unknown user", "no such user", "does not exist", "doesn't exist", "invalid recipient", "invalid address", "recipient address rejected", "mailbox unavailable", "no mailbox", "address rejected", "unrouteable", "no such domain", "host not found"}
+)
+
+// Bounce is a failed delivery, or a complaint, reported by returned mail.
+type Bounce struct {
+	Type       string
+	Recipient  string
+	Status     string
+	Diagnostic string
+	// MessageID and Headers come from the original message when the
+	// report returns its headers. Headers is nil otherwise.
+	MessageID string
+	Headers   textproto.MIMEHeader
+	// To holds the addresses the report was delivered to, which identify
+	// the message when the envelope sender encoded it.
+	To []string
+}
+
+// Parse reads the bounces reported by a message: RFC 3464 delivery status
+// notifications, one per failed recipient; RFC 5965 feedback reports, as a
+// complaint; and the free-text notices many servers still send. Delivery
+// delays and successes are not bounces. Messages that report nothing return
+// ErrNotBounce.
+func Parse(raw []byte) ([]Bounce, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	r := &report{}
+	header := textproto.MIMEHeader(msg.Header)
+	r.readPart(header, msg.Body, 0)
+
+	to := deliveredTo(header)
+	messageID := r.messageID()
+
+	var bounces []Bounce
+	switch {
+	case r.feedback != nil || r.reportType == "feedback-report":
+		bounces = []Bounce{r.complaint()}
+	case len(r.recipients) > 0:
+		bounces = r.deliveryFailures()
+	case looksLikeBounce(header):
+		bounces = r.textBounce(header)
+	}
+	if len(bounces) == 0 {
+		return nil, ErrNotBounce
+	}
+
+	for i := range bounces {
+		bounces[i].MessageID = messageID
+		bounces[i].Headers = r.original
+		bounces[i].To = to
+	}
+	return bounces, nil
+}
+
+// report collects the parts of a message that describe a bounce.
+type report struct {
+	reportType string
+	recipients []textproto.MIMEHeader
+	feedback   textproto.MIMEHeader
+	original   textproto.MIMEHeader
+	text       strings.Builder
+}
+
+func (r *report) readPart(header textproto.MIMEHeader, body io.Reader, depth int) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	body = decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxPartDepth {
+			return
+		}
+		if mediaType == "multipart/report" {
+			r.reportType = strings.ToLower(params["report-type"])
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			// Stop at the end or at a malformed part, keeping what was
+			// read; truncated reports are common.
+			part, err := reader.NextPart()
+			if err != nil {
+				return
+			}
+			r.readPart(part.Header, part, depth+1)
+		}
+	case mediaType == "message/delivery-status" || mediaType == "message/global-delivery-status":
+		r.readDeliveryStatus(body)
+	case mediaType == "message/feedback-report":
+		fields, _ := readHeaderBlock(textproto.NewReader(bufio.NewReader(body)))
+		r.feedback = fields
+	case mediaType == "message/rfc822" || mediaType == "message/global" ||
+		mediaType == "text/rfc822-headers" || mediaType == "message/rfc822-headers":
+		if r.original == nil {
+			fields, _ := readHeaderBlock(textproto.NewReader(bufio.NewReader(body)))
+			r.original = fields
+		}
+	case mediaType == "text/plain" || mediaType == "text/html":
+		remaining := maxTextSize - r.text.Len()
+		if remaining <= 0 {
+			return
+		}
+		content, _ := io.ReadAll(io.LimitReader(body, int64(remaining)))
+		if mediaType == "text/html" {
+			content = []byte(email.HTMLToText(string(content)))
+		}
+		r.text.Write(content)
+		r.text.WriteString("\n")
+	}
+}
+
+// readDeliveryStatus reads the per-message fields and then one block of
+// fields per recipient. Some servers leave out the per-message fields.
+func (r *report) readDeliveryStatus(body io.Reader) {
+	reader := textproto.NewReader(bufio.NewReader(body))
+	fields, err := readHeaderBlock(reader)
+	if fields.Get("Final-Recipient") != "" {
+		r.recipients = append(r.recipients, fields)
+	}
+	if err != nil {
+		return
+	}
+	for {
+		fields, err := readHeaderBlock(reader)
+		if len(fields) > 0 {
+			r.recipients = append(r.recipients, fields)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *report) deliveryFailures() []Bounce {
+	var bounces []Bounce
+	for _, fields := range r.recipients {
+		action := strings.ToLower(strings.TrimSpace(fields.Get("Action")))
+		status := firstField(fields.Get("Status"))
+		if action == "" && statusPattern.MatchString(status) {
+			action = "failed"
+		}
+		if action != "failed" {
+			continue
+		}
+
+		recipient := typedValue(fields.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = typedValue(fields.Get("Original-Recipient"))
+		}
+		diagnostic := typedValue(fields.Get("Diagnostic-Code"))
+		bounces = append(bounces, Bounce{
+			Type:       classify(status, diagnostic),
+			Recipient:  recipient,
+			Status:     status,
+			Diagnostic: diagnostic,
+		})
+	}
+	return bounces
+}
+
+func (r *report) complaint() Bounce {
+	recipient := ""
+	if r.feedback != nil {
+		recipient = typedValue(r.feedback.Get("Original-Rcpt-To"))
+	}
+	if recipient == "" && r.original != nil {
+		recipient = firstAddress(r.original.Get("To"))
+	}
+
+	diagnostic := "spam complaint"
+	if r.feedback != nil && r.feedback.Get("Feedback-Type") != "" {
+		diagnostic = r.feedback.Get("Feedback-Type") + " complaint"
+	}
+	if r.feedback != nil && r.feedback.Get("User-Agent") != "" {
+		diagnostic += " via " + r.feedback.Get("User-Agent")
+	}
+	return Bounce{
+		Type:       TypeComplaint,
+		Recipient:  recipient,
+		Diagnostic: diagnostic,
+	}
+}
+
+// textBounce reads a non-standard bounce. The recipient comes from the Exim
+// X-Failed-Recipients header or is the first address in the text that is
+// not the report's own sender or recipient.
+func (r *report) textBounce(header textproto.MIMEHeader) []Bounce {
+	text := r.text.String()
+
+	recipient := firstAddress(header.Get("X-Failed-Recipients"))
+	if recipient == "" {
+		own := map[string]bool{}
+		for _, field := range []string{"From", "To", "Reply-To", "Return-Path", "Delivered-To", "X-Original-To"} {
+			for _, address := range addressPattern.FindAllString(header.Get(field), -1) {
+				own[strings.ToLower(address)] = true
+			}
+		}
+		for _, address := range addressPattern.FindAllString(text, -1) {
+			if !own[strings.ToLower(address)] {
+				recipient = address
+				break
+			}
+		}
+	}
+
+	status := statusPattern.FindString(text)
+	diagnostic := diagnosticLine(text, status)
+	return []Bounce{{
+		Type:       classify(status, diagnostic),
+		Recipient:  recipient,
+		Status:     status,
+		Diagnostic: diagnostic,
+	}}
+}
+
+func (r *report) messageID() string {
+	if r.original != nil {
+		if id := strings.Trim(r.original.Get("Message-ID"), "<> \t"); id != "" {
+			return id
+		}
+	}
+	if match := messageIDPattern.FindStringSubmatch(r.text.String()); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// classify tells hard bounces, which will fail again, from soft ones. Full
+// mailboxes, oversized messages and policy rejections (5.7.x) are soft even
+// with a permanent status, as the address itself is valid.
+func classify(status, diagnostic string) string {
+	switch {
+	case status == "5.2.2" || status == "5.2.3" || status == "5.3.4" || strings.HasPrefix(status, "5.7."):
+		return TypeSoft
+	case strings.HasPrefix(status, "5."):
+		return TypeHard
+	case strings.HasPrefix(status, "4."):
+		return TypeSoft
+	}
+
+	lower := strings.ToLower(diagnostic)
+	for _, keyword := range softKeywords {
+		if strings.Contains(lower, keyword) {
+			return TypeSoft
+		}
+	}
+	if match := smtpCodePattern.FindStringSubmatch(diagnostic); match != nil {
+		if match[1][0] == '5' {
+			return TypeHard
+		}
+		return TypeSoft
+	}
+	for _, keyword := range hardKeywords {
+		if strings.Contains(lower, keyword) {
+			return TypeHard
+		}
+	}
+	return TypeSoft
+}
+
+func looksLikeBounce(header textproto.MIMEHeader) bool {
+	if header.Get("X-Failed-Recipients") != "" {
+		return true
+	}
+	if subjectPattern.MatchString(header.Get("Subject")) {
+		return true
+	}
+	from := header.Get("From")
+	if address, err := mail.ParseAddress(from); err == nil {
+		if senderPattern.MatchString(address.Address) || senderPattern.MatchString(address.Name) {
+			return true
+		}
+	}
+	return senderPattern.MatchString(strings.TrimSpace(from))
+}
+
+// diagnosticLine returns the line of text holding the status code, or the
+// first one holding an SMTP reply code, or else the first one holding a
+// keyword that classify knows.
+func diagnosticLine(text, status string) string {
+	var fallback, keywordLine string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if status != "" && strings.Contains(line, status) {
+			return line
+		}
+		if fallback == "" && smtpCodePattern.MatchString(" "+line) {
+			fallback = line
+		}
+		if keywordLine == "" && hasKeyword(line) {
+			keywordLine = line
+		}
+	}
+	if fallback == "" {
+		return keywordLine
+	}
+	return fallback
+}
+
+func hasKeyword(line string) bool {
+	lower := strings.ToLower(line)
+	for _, keywords := range [][]string{softKeywords, hardKeywords} {
+		for _, keyword := range keywords {
+			if strings.Contains(lower, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func deliveredTo(header textproto.MIMEHeader) []string {
+	var addresses []string
+	seen := map[string]bool{}
+	for _, field := range []string{"Delivered-To", "X-Original-To", "Envelope-To", "To"} {
+		for _, value := range header.Values(field) {
+			for _, address := range addressPattern.FindAllString(value, -1) {
+				address = strings.ToLower(address)
+				if !seen[address] {
+					seen[address] = true
+					addresses = append(addresses, address)
+				}
+			}
+		}
+	}
+	return addresses
+}
+
+// readHeaderBlock reads fields up to the next blank line, skipping blank
+// lines before them.
+func readHeaderBlock(reader *textproto.Reader) (textproto.MIMEHeader, error) {
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 || err != nil {
+			return fields, err
+		}
+	}
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// typedValue strips the type from a DSN field such as "rfc822; a@b.com".
+func typedValue(value string) string {
+	if _, after, ok := strings.Cut(value, ";"); ok {
+		value = after
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+func firstField(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func firstAddress(value string) string {
+	return addressPattern.FindString(value)
+}
@@ -0,0 +1,263 @@
+package bounce
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// dsnMessage builds an RFC 3464 delivery status notification for one
+// recipient.
+func dsnMessage(action, status, diagnostic string) string {
+	fields := []string{
+		"Final-Recipient: rfc822; rcpt@example.org",
+		"Action: " + action,
+		"Status: " + status,
+	}
+	if diagnostic != "" {
+		fields = append(fields, "Diagnostic-Code: smtp; "+diagnostic)
+	}
+
+	return "From: Mail Delivery System <MAILER-DAEMON@mx.example.org>\r\n" +
+		"To: bounces+42@example.com\r\n" +
+		"Subject: Undelivered Mail Returned to Sender\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=\"BOUNDARY\"\r\n" +
+		"\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Your message could not be delivered.\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: message/delivery-status\r\n" +
+		"\r\n" +
+		"Reporting-MTA: dns; mx.example.org\r\n" +
+		"\r\n" +
+		strings.Join(fields, "\r\n") + "\r\n" +
+		"\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/rfc822-headers\r\n" +
+		"\r\n" +
+		"Message-ID: <job-42@example.com>\r\n" +
+		"To: rcpt@example.org\r\n" +
+		"\r\n" +
+		"--BOUNDARY--\r\n"
+}
+
+// textMessage builds a free-text bounce notice such as Exim and older
+// servers send.
+func textMessage(header, body string) string {
+	return "From: Mail Delivery System <Mailer-Daemon@mx.example.org>\r\n" +
+		"To: bounces@example.com\r\n" +
+		"Subject: Mail delivery failed: returning message to sender\r\n" +
+		header +
+		"\r\n" +
+		"This message was created automatically by mail delivery software.\r\n" +
+		"\r\n" +
+		body + "\r\n"
+}
+
+func TestParseDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		status     string
+		diagnostic string
+		want       string
+	}{
+		{"unknown user", "failed", "5.1.1", "550 5.1.1 <rcpt@example.org>: Recipient address rejected", TypeHard},
+		{"bad domain", "failed", "5.1.2", "", TypeHard},
+		{"mailbox full", "failed", "5.2.2", "552 5.2.2 Mailbox full", TypeSoft},
+		{"message too big", "failed", "5.3.4", "552 5.3.4 Message size exceeds limit", TypeSoft},
+		{"policy rejection", "failed", "5.7.1", "550 5.7.1 Message rejected as spam", TypeSoft},
+		{"temporary failure", "failed", "4.4.1", "421 4.4.1 Connection timed out", TypeSoft},
+		{"status in the diagnostic only", "failed", "", "550 no such user", TypeHard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounces, err := Parse([]byte(dsnMessage(tt.action, tt.status, tt.diagnostic)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(bounces) != 1 {
+				t.Fatalf("got %d bounces, want 1", len(bounces))
+			}
+			b := bounces[0]
+			if b.Type != tt.want {
+				t.Errorf("Type = %q, want %q", b.Type, tt.want)
+			}
+			if b.Recipient != "rcpt@example.org" {
+				t.Errorf("Recipient = %q", b.Recipient)
+			}
+			if b.Status != tt.status {
+				t.Errorf("Status = %q, want %q", b.Status, tt.status)
+			}
+			if b.MessageID != "job-42@example.com" {
+				t.Errorf("MessageID = %q", b.MessageID)
+			}
+			if len(b.To) == 0 || b.To[0] != "bounces+42@example.com" {
+				t.Errorf("To = %v", b.To)
+			}
+		})
+	}
+}
+
+func TestParseIgnoresDelaysAndSuccesses(t *testing.T) {
+	for _, action := range []string{"delayed", "delivered", "relayed"} {
+		_, err := Parse([]byte(dsnMessage(action, "4.4.7", "")))
+		if !errors.Is(err, ErrNotBounce) {
+			t.Errorf("Parse of a %s report: err = %v, want ErrNotBounce", action, err)
+		}
+	}
+}
+
+func TestParseTextBounce(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		body      string
+		recipient string
+		status    string
+		want      string
+	}{
+		{
+			name:      "status code",
+			header:    "X-Failed-Recipients: gone@example.org\r\n",
+			body:      "  gone@example.org\r\n    host mx.example.org said: 550 5.1.1 User unknown",
+			recipient: "gone@example.org",
+			status:    "5.1.1",
+			want:      TypeHard,
+		},
+		{
+			name:      "reply code",
+			body:      "<gone@example.org>: host mx.example.org said: 550 Requested action not taken",
+			recipient: "gone@example.org",
+			want:      TypeHard,
+		},
+		{
+			name:      "temporary reply code",
+			body:      "<busy@example.org>: host mx.example.org said: 452 Too many recipients",
+			recipient: "busy@example.org",
+			want:      TypeSoft,
+		},
+		{
+			name:      "hard keyword",
+			body:      "Delivery to gone@example.org failed: no such user here",
+			recipient: "gone@example.org",
+			want:      TypeHard,
+		},
+		{
+			name:      "soft keyword wins over a permanent reply code",
+			body:      "<full@example.org>: 550 mailbox full, quota exceeded",
+			recipient: "full@example.org",
+			want:      TypeSoft,
+		},
+		{
+			name:      "no reason given",
+			body:      "Your message to someone@example.org could not be delivered.",
+			recipient: "someone@example.org",
+			want:      TypeSoft,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounces, err := Parse([]byte(textMessage(tt.header, tt.body)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(bounces) != 1 {
+				t.Fatalf("got %d bounces, want 1", len(bounces))
+			}
+			b := bounces[0]
+			if b.Type != tt.want {
+				t.Errorf("Type = %q, want %q (diagnostic %q)", b.Type, tt.want, b.Diagnostic)
+			}
+			if b.Recipient != tt.recipient {
+				t.Errorf("Recipient = %q, want %q", b.Recipient, tt.recipient)
+			}
+			if b.Status != tt.status {
+				t.Errorf("Status = %q, want %q", b.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestParseComplaint(t *testing.T) {
+	raw := "From: feedback@isp.example.net\r\n" +
+		"To: abuse@example.com\r\n" +
+		"Subject: Abuse report\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=feedback-report; boundary=\"B\"\r\n" +
+		"\r\n" +
+		"--B\r\n" +
+		"Content-Type: message/feedback-report\r\n" +
+		"\r\n" +
+		"Feedback-Type: abuse\r\n" +
+		"User-Agent: ISP-FBL/1.0\r\n" +
+		"Original-Rcpt-To: <rcpt@example.org>\r\n" +
+		"\r\n" +
+		"--B\r\n" +
+		"Content-Type: message/rfc822\r\n" +
+		"\r\n" +
+		"Message-ID: <job-7@example.com>\r\n" +
+		"To: rcpt@example.org\r\n" +
+		"\r\n" +
+		"body\r\n" +
+		"--B--\r\n"
+
+	bounces, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(bounces) != 1 {
+		t.Fatalf("got %d bounces, want 1", len(bounces))
+	}
+	b := bounces[0]
+	if b.Type != TypeComplaint || b.Recipient != "rcpt@example.org" || b.MessageID != "job-7@example.com" {
+		t.Errorf("got %+v", b)
+	}
+	if b.Diagnostic != "abuse complaint via ISP-FBL/1.0" {
+		t.Errorf("Diagnostic = %q", b.Diagnostic)
+	}
+}
+
+func TestParseNotBounce(t *testing.T) {
+	raw := "From: Someone <someone@example.org>\r\n" +
+		"To: bounces@example.com\r\n" +
+		"Subject: Re: your newsletter\r\n" +
+		"\r\n" +
+		"Thanks, 550 people liked it.\r\n"
+	if _, err := Parse([]byte(raw)); !errors.Is(err, ErrNotBounce) {
+		t.Errorf("err = %v, want ErrNotBounce", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		status     string
+		diagnostic string
+		want       string
+	}{
+		{"5.1.1", "", TypeHard},
+		{"5.0.0", "mailbox full", TypeHard},
+		{"5.2.2", "", TypeSoft},
+		{"5.2.3", "", TypeSoft},
+		{"5.3.4", "", TypeSoft},
+		{"5.7.26", "", TypeSoft},
+		{"4.2.2", "user unknown", TypeSoft},
+		{"", "550 user unknown", TypeHard},
+		{"", "smtp; 550-Mailbox unavailable", TypeHard},
+		{"", "421 try again later", TypeSoft},
+		{"", "450 greylisted", TypeSoft},
+		{"", "Quota exceeded (550)", TypeSoft},
+		{"", "The email account that you tried to reach does not exist", TypeHard},
+		{"", "Host not found", TypeHard},
+		{"", "", TypeSoft},
+		{"", "something went wrong", TypeSoft},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.status, tt.diagnostic); got != tt.want {
+			t.Errorf("classify(%q, %q) = %q, want %q", tt.status, tt.diagnostic, got, tt.want)
+		}
+	}
+}
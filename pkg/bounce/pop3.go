@@ -0,0 +1,169 @@
+package bounce
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/email"
+)
+
+const pop3Timeout = 2 * time.Minute
+
+// POP3Client reads a mailbox over POP3. POP3 has no folders, so messages can
+// only be deleted, and deletions take effect when Close ends the session.
+type POP3Client struct {
+	config MailboxConfig
+	conn   net.Conn
+	text   *textproto.Conn
+}
+
+func NewPOP3Client(config MailboxConfig) (*POP3Client, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if config.Action == ActionArchive {
+		return nil, errors.New("POP3 mailboxes cannot archive messages; use IMAP or the delete action")
+	}
+	if config.Port == 0 {
+		config.Port = 110
+		if config.Security == email.SecurityTLS {
+			config.Port = 995
+		}
+	}
+	return &POP3Client{config: config}, nil
+}
+
+func (c *POP3Client) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	conn, err := c.config.dial()
+	if err != nil {
+		return err
+	}
+	c.setConn(conn)
+
+	if _, err = c.readStatus(); err != nil {
+		return c.fail(fmt.Errorf("POP3 greeting error: %w", err))
+	}
+
+	if c.config.Security == email.SecurityStartTLS {
+		if _, err = c.command("STLS"); err != nil {
+			return c.fail(err)
+		}
+		tlsConn, err := c.config.startTLS(conn)
+		if err != nil {
+			return c.fail(err)
+		}
+		c.setConn(tlsConn)
+	}
+
+	if _, err = c.command("USER " + c.config.Username); err != nil {
+		return c.fail(err)
+	}
+	if _, err = c.command("PASS " + c.config.Password); err != nil {
+		return c.fail(errors.New("POP3 PASS error: authentication failed"))
+	}
+	return nil
+}
+
+func (c *POP3Client) setConn(conn net.Conn) {
+	c.conn = conn
+	c.text = textproto.NewConn(conn)
+}
+
+func (c *POP3Client) fail(err error) error {
+	c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Fetch returns up to limit messages, oldest first.
+func (c *POP3Client) Fetch(limit int) ([]Message, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.command("LIST"); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, fmt.Errorf("POP3 LIST error: %w", err)
+	}
+
+	var messages []Message
+	for _, line := range lines {
+		if limit > 0 && len(messages) >= limit {
+			break
+		}
+		number, _, _ := strings.Cut(line, " ")
+		if _, err := strconv.Atoi(number); err != nil {
+			continue
+		}
+
+		if _, err = c.command("RETR " + number); err != nil {
+			return nil, err
+		}
+		raw, err := io.ReadAll(c.text.DotReader())
+		if err != nil {
+			return nil, fmt.Errorf("POP3 RETR error: %w", err)
+		}
+		messages = append(messages, Message{ID: number, Raw: raw})
+	}
+	return messages, nil
+}
+
+// Remove marks the messages for deletion; the server deletes them on Close.
+func (c *POP3Client) Remove(ids []string) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := c.command("DELE " + id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *POP3Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	_, err := c.command("QUIT")
+	c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *POP3Client) command(command string) (string, error) {
+	c.conn.SetDeadline(time.Now().Add(pop3Timeout))
+	if err := c.text.PrintfLine("%s", command); err != nil {
+		return "", fmt.Errorf("POP3 write error: %w", err)
+	}
+	status, err := c.readStatus()
+	if err != nil {
+		verb, _, _ := strings.Cut(command, " ")
+		return "", fmt.Errorf("POP3 %s error: %w", verb, err)
+	}
+	return status, nil
+}
+
+func (c *POP3Client) readStatus() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if status, ok := strings.CutPrefix(line, "+OK"); ok {
+		return strings.TrimSpace(status), nil
+	}
+	return "", errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
@@ -0,0 +1,99 @@
+package bounce
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPOP3FetchAndRemove(t *testing.T) {
+	messages := map[string]string{
+		"1": "Subject: first\r\n\r\nbody one\r\n",
+		"2": "Subject: second\r\n\r\n.leading dot\r\n",
+	}
+	server := newFakeServer(t, "+OK POP3 ready\r\n", func(line string) string {
+		verb, arg, _ := strings.Cut(line, " ")
+		switch verb {
+		case "USER", "DELE", "QUIT":
+			return "+OK\r\n"
+		case "PASS":
+			if arg != "secret" {
+				return "-ERR invalid password\r\n"
+			}
+			return "+OK logged in\r\n"
+		case "LIST":
+			return "+OK 3 messages\r\n1 120\r\n2 80\r\n3 60\r\n.\r\n"
+		case "RETR":
+			if message, ok := messages[arg]; ok {
+				return "+OK\r\n" + strings.ReplaceAll(message, "\r\n.", "\r\n..") + ".\r\n"
+			}
+			return "-ERR no such message\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+
+	client, err := NewPOP3Client(server.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := client.Fetch(2)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(fetched) != 2 {
+		t.Fatalf("fetched %d messages, want 2", len(fetched))
+	}
+	for _, message := range fetched {
+		want := strings.ReplaceAll(messages[message.ID], "\r\n", "\n")
+		if string(message.Raw) != want {
+			t.Errorf("message %s = %q, want %q", message.ID, message.Raw, want)
+		}
+	}
+
+	if err = client.Remove([]string{"1", "2"}); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err = client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	assertCommands(t, server.received(t), []string{
+		"USER bounces",
+		"PASS secret",
+		"LIST",
+		"RETR 1",
+		"RETR 2",
+		"DELE 1",
+		"DELE 2",
+		"QUIT",
+	})
+}
+
+func TestPOP3AuthenticationFailure(t *testing.T) {
+	server := newFakeServer(t, "+OK POP3 ready\r\n", func(line string) string {
+		if strings.HasPrefix(line, "PASS ") {
+			return "-ERR [AUTH] invalid password secret\r\n"
+		}
+		return "+OK\r\n"
+	})
+
+	config := server.config()
+	client, err := NewPOP3Client(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Fetch(10)
+	if err == nil {
+		t.Fatal("Fetch succeeded with a rejected password")
+	}
+	if strings.Contains(err.Error(), config.Password) {
+		t.Errorf("the error reveals the password: %v", err)
+	}
+	server.received(t)
+}
+
+func TestPOP3CannotArchive(t *testing.T) {
+	_, err := NewPOP3Client(MailboxConfig{Host: "localhost", Action: ActionArchive, ArchiveMailbox: "Archive"})
+	if err == nil {
+		t.Error("NewPOP3Client accepted the archive action")
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -9,11 +10,41 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	SMTP     SMTPConfig
-	Queue    QueueConfig
+	App         AppConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	SMTP        SMTPConfig
+	Transport   TransportConfig
+	DKIM        DKIMConfig
+	Attachments AttachmentConfig
+	Imports     ImportConfig
+	Exports     ExportConfig
+	OptIn       OptInConfig
+	Bounce      BounceConfig
+	VERP        VERPConfig
+	Webhooks    WebhookConfig
+	Queue       QueueConfig
+}
+
+// AppConfig holds settings for links sent to contacts. BaseURL is the public
+// address of the API; Secret signs the tokens in those links and falls back
+// to the JWT secret when empty. Load refuses a configuration where neither
+// is set to a real secret, as anyone could then forge links.
+type AppConfig struct {
+	BaseURL string
+	Secret  string
+}
+
+// placeholderSecret is the default JWT secret, which must be changed.
+const placeholderSecret = "your-secret-key-change-me"
+
+// LinkSecret returns the secret that signs links sent to contacts.
+func (c Config) LinkSecret() string {
+	if c.App.Secret != "" {
+		return c.App.Secret
+	}
+	return c.JWT.Secret
 }
 
 type ServerConfig struct {
@@ -30,25 +61,200 @@ type DatabaseConfig struct {
 	URL      string
 }
 
+// DSN is URL, or a connection URL built from the other fields when it is
+// empty.
+func (c DatabaseConfig) DSN() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return fmt.Sprintf(
+		"postgresql://%s:%s@%s:%d/%s",
+		c.User,
+		c.Password,
+		c.Host,
+		c.Port,
+		c.Name,
+	)
+}
+
 type JWTConfig struct {
 	Secret         string
 	ExpirationTime time.Duration
 }
 
 type SMTPConfig struct {
-	Host     string
-	Port     int
+	Host         string
+	Port         int
+	Username     string
+	Password     string
+	FromName     string
+	FromAddr     string
+	UseTLS       bool
+	Security     string
+	MaxIdleConns int
+	IdleTimeout  time.Duration
+	DialTimeout  time.Duration
+}
+
+type TransportConfig struct {
+	Type string
+	File FileTransportConfig
+	HTTP HTTPTransportConfig
+}
+
+type FileTransportConfig struct {
+	Dir     string
+	Maildir bool
+}
+
+type HTTPTransportConfig struct {
+	URL     string
+	APIKey  string
+	Timeout time.Duration
+}
+
+type DKIMConfig struct {
+	Enabled bool
+	Headers []string
+	Keys    []DKIMKeyConfig
+}
+
+type DKIMKeyConfig struct {
+	Domain         string
+	Selector       string
+	PrivateKey     string
+	PrivateKeyFile string
+}
+
+type AttachmentConfig struct {
+	MaxSize      int64
+	MaxTotalSize int64
+	AllowedTypes []string
+	StorageDir   string
+}
+
+type ImportConfig struct {
+	Dir           string
+	MaxFileSize   int64
+	BatchSize     int
+	Concurrency   int
+	UploadTimeout time.Duration
+}
+
+type ExportConfig struct {
+	Dir         string
+	BatchSize   int
+	Concurrency int
+	Retention   time.Duration
+}
+
+// OptInConfig controls public signups. Unconfirmed signups and confirmation
+// links expire after Expiry. TemplateName is the confirmation email template;
+// a built-in one is used when no template has that name.
+type OptInConfig struct {
+	Expiry       time.Duration
+	TemplateName string
+	Subject      string
+}
+
+// BounceConfig is the mailbox that returned mail is delivered to. Protocol is
+// imap or pop3 and Security plain, tls or starttls. Processed messages are
+// deleted, or with Action archive moved to ArchiveMailbox (IMAP only). Every
+// message read is processed, so the mailbox should only receive bounces.
+type BounceConfig struct {
+	Enabled        bool
+	Protocol       string
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	Security       string
+	Mailbox        string
+	Action         string
+	ArchiveMailbox string
+	BatchSize      int
+	DialTimeout    time.Duration
+}
+
+// VERPConfig gives campaign and broadcast mail a per-message envelope
+// sender, Prefix+<signed job and contact IDs>@Domain, so returned mail
+// identifies the exact job. Domain defaults to the domain of SMTP.FromAddr
+// and must deliver to the bounce mailbox.
+type VERPConfig struct {
+	Enabled bool
+	Domain  string
+	Prefix  string
+}
+
+// WebhookConfig holds the credentials for provider event webhooks. Only
+// providers with credentials set accept events. Signed requests older than
+// MaxAge are refused.
+type WebhookConfig struct {
+	MaxAge   time.Duration
+	SendGrid SendGridWebhookConfig
+	Mailgun  MailgunWebhookConfig
+	Postmark PostmarkWebhookConfig
+	Generic  GenericWebhookConfig
+}
+
+// SendGridWebhookConfig is the Event Webhook verification key, base64 or PEM.
+type SendGridWebhookConfig struct {
+	PublicKey string
+}
+
+type MailgunWebhookConfig struct {
+	SigningKey string
+}
+
+// PostmarkWebhookConfig is the basic auth set on the webhook URL.
+type PostmarkWebhookConfig struct {
 	Username string
 	Password string
-	FromName string
-	FromAddr string
 }
 
+// GenericWebhookConfig is the HMAC secret for the generic webhook format.
+type GenericWebhookConfig struct {
+	Secret string
+}
+
+// QueueConfig controls the mail workers. Each worker claims up to
+// ClaimBatchSize jobs at a time, leased for LeaseTimeout and renewed while
+// it works through them; jobs of a worker that stops renewing are requeued.
+// A job that fails temporarily is retried up to MaxRetries times, after
+// RetryBackoff doubled for each earlier retry, up to MaxRetryBackoff. Idle
+// workers are woken by Postgres notifications as soon as jobs are queued,
+// and otherwise look for due jobs every PollInterval. Another
+// TransactionalWorkerCount workers send only transactional mail.
+//
+// RateLimit is the number of emails a minute sent by all workers in all
+// replicas together, with bursts of up to RateLimitBurst, which defaults to
+// one second's worth of the rate; 0 sets no global limit. RateLimits adds
+// limits per recipient domain or sender. These seed the stored limits when
+// they do not exist yet; after that the limits are changed through the API.
+// Transactional mail takes from the domain limits only, so that it never
+// waits behind bulk mail for the global limit.
 type QueueConfig struct {
-	WorkerCount  int
-	MaxRetries   int
-	RetryBackoff time.Duration
-	RateLimit    int
+	WorkerCount              int
+	TransactionalWorkerCount int
+	MaxRetries               int
+	RetryBackoff             time.Duration
+	MaxRetryBackoff          time.Duration
+	RateLimit                int
+	RateLimitBurst           int
+	RateLimits               []RateLimitConfig
+	ClaimBatchSize           int
+	LeaseTimeout             time.Duration
+	PollInterval             time.Duration
+}
+
+// RateLimitConfig limits mail to one recipient domain (Scope "domain") or
+// from one sender address (Scope "sender") to Rate emails a minute, with
+// bursts of up to Burst.
+type RateLimitConfig struct {
+	Scope string
+	Key   string
+	Rate  int
+	Burst int
 }
 
 func Load() (*Config, error) {
@@ -78,11 +284,17 @@ func Load() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
+	if secret := config.LinkSecret(); secret == "" || secret == placeholderSecret {
+		return nil, errors.New("app.secret is not set: links sent to contacts cannot be signed with an empty or default secret")
+	}
 
 	return &config, nil
 }
 
 func setDefaults() {
+	viper.SetDefault("app.baseURL", "http://localhost:3000")
+	viper.SetDefault("app.secret", "")
+
 	viper.SetDefault("server.port", 3000)
 	viper.SetDefault("server.timeout", "30s")
 
@@ -92,7 +304,7 @@ func setDefaults() {
 	viper.SetDefault("database.password", "postgres")
 	viper.SetDefault("database.name", "listmonk")
 
-	viper.SetDefault("jwt.secret", "your-secret-key-change-me")
+	viper.SetDefault("jwt.secret", placeholderSecret)
 	viper.SetDefault("jwt.expirationTime", "24h")
 
 	viper.SetDefault("smtp.host", "localhost")
@@ -101,9 +313,77 @@ func setDefaults() {
 	viper.SetDefault("smtp.password", "")
 	viper.SetDefault("smtp.fromName", "Listmonk Clone")
 	viper.SetDefault("smtp.fromAddr", "noreply@example.com")
+	viper.SetDefault("smtp.useTLS", false)
+	viper.SetDefault("smtp.security", "")
+	viper.SetDefault("smtp.maxIdleConns", 2)
+	viper.SetDefault("smtp.idleTimeout", "30s")
+	viper.SetDefault("smtp.dialTimeout", "10s")
+
+	viper.SetDefault("transport.type", "smtp")
+	viper.SetDefault("transport.file.dir", "tmp/mail")
+	viper.SetDefault("transport.file.maildir", false)
+	viper.SetDefault("transport.http.url", "")
+	viper.SetDefault("transport.http.apiKey", "")
+	viper.SetDefault("transport.http.timeout", "10s")
+
+	viper.SetDefault("dkim.enabled", false)
+
+	viper.SetDefault("attachments.maxSize", 10<<20)
+	viper.SetDefault("attachments.maxTotalSize", 20<<20)
+	viper.SetDefault("attachments.allowedTypes", []string{
+		"application/pdf",
+		"image/png",
+		"image/jpeg",
+		"image/gif",
+		"text/plain",
+		"text/csv",
+		"text/calendar",
+	})
+	viper.SetDefault("attachments.storageDir", "storage/attachments")
+
+	viper.SetDefault("imports.dir", "storage/imports")
+	viper.SetDefault("imports.maxFileSize", 1<<30)
+	viper.SetDefault("imports.batchSize", 500)
+	viper.SetDefault("imports.concurrency", 2)
+	viper.SetDefault("imports.uploadTimeout", "30m")
+
+	viper.SetDefault("exports.dir", "storage/exports")
+	viper.SetDefault("exports.batchSize", 1000)
+	viper.SetDefault("exports.concurrency", 1)
+	viper.SetDefault("exports.retention", "24h")
+
+	viper.SetDefault("optIn.expiry", "72h")
+	viper.SetDefault("optIn.templateName", "opt-in-confirmation")
+	viper.SetDefault("optIn.subject", "Confirm your subscription")
+
+	viper.SetDefault("bounce.enabled", false)
+	viper.SetDefault("bounce.protocol", "imap")
+	viper.SetDefault("bounce.port", 0)
+	viper.SetDefault("bounce.security", "tls")
+	viper.SetDefault("bounce.mailbox", "INBOX")
+	viper.SetDefault("bounce.action", "delete")
+	viper.SetDefault("bounce.archiveMailbox", "Processed")
+	viper.SetDefault("bounce.batchSize", 100)
+	viper.SetDefault("bounce.dialTimeout", "10s")
+
+	viper.SetDefault("verp.enabled", false)
+	viper.SetDefault("verp.domain", "")
+	viper.SetDefault("verp.prefix", "bounces")
+
+	viper.SetDefault("webhooks.maxAge", "10m")
+	viper.SetDefault("webhooks.sendGrid.publicKey", "")
+	viper.SetDefault("webhooks.mailgun.signingKey", "")
+	viper.SetDefault("webhooks.postmark.username", "")
+	viper.SetDefault("webhooks.postmark.password", "")
+	viper.SetDefault("webhooks.generic.secret", "")
 
 	viper.SetDefault("queue.workerCount", 5)
+	viper.SetDefault("queue.transactionalWorkerCount", 2)
 	viper.SetDefault("queue.maxRetries", 3)
 	viper.SetDefault("queue.retryBackoff", "5m")
+	viper.SetDefault("queue.maxRetryBackoff", "6h")
 	viper.SetDefault("queue.rateLimit", 10)
+	viper.SetDefault("queue.claimBatchSize", 10)
+	viper.SetDefault("queue.leaseTimeout", "5m")
+	viper.SetDefault("queue.pollInterval", "30s")
 }
@@ -0,0 +1,393 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+)
+
+const (
+	DKIMAlgorithmRSA     = "rsa-sha256"
+	DKIMAlgorithmEd25519 = "ed25519-sha256"
+)
+
+var defaultDKIMHeaders = []string{
+	"From",
+	"To",
+	"Subject",
+	"Date",
+	"Message-ID",
+	"MIME-Version",
+	"Content-Type",
+	"Reply-To",
+	"List-Unsubscribe",
+	"List-Unsubscribe-Post",
+}
+
+var (
+	dkimWhitespace = regexp.MustCompile(`[ \t]+`)
+	dkimSignature  = regexp.MustCompile(`((?:^|;)[ \t\r\n]*b[ \t\r\n]*=)[^;]*`)
+)
+
+type DKIMKey struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer
+}
+
+type DKIMSigner struct {
+	keys    map[string]DKIMKey
+	headers []string
+}
+
+func NewDKIMSigner(keys []DKIMKey, headers []string) *DKIMSigner {
+	if len(headers) == 0 {
+		headers = defaultDKIMHeaders
+	}
+
+	signer := &DKIMSigner{
+		keys:    make(map[string]DKIMKey, len(keys)),
+		headers: make([]string, len(headers)),
+	}
+	for i, header := range headers {
+		signer.headers[i] = strings.ToLower(strings.TrimSpace(header))
+	}
+	for _, key := range keys {
+		signer.keys[strings.ToLower(key.Domain)] = key
+	}
+	return signer
+}
+
+func NewDKIMSignerFromConfig(cfg config.DKIMConfig) (*DKIMSigner, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	keys := make([]DKIMKey, 0, len(cfg.Keys))
+	for _, keyCfg := range cfg.Keys {
+		if keyCfg.Domain == "" || keyCfg.Selector == "" {
+			return nil, errors.New("DKIM key requires a domain and selector")
+		}
+
+		keyData := []byte(keyCfg.PrivateKey)
+		if keyCfg.PrivateKeyFile != "" {
+			data, err := os.ReadFile(keyCfg.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading DKIM key for %s: %w", keyCfg.Domain, err)
+			}
+			keyData = data
+		}
+
+		signer, err := ParseDKIMPrivateKey(keyData)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing DKIM key for %s: %w", keyCfg.Domain, err)
+		}
+
+		keys = append(keys, DKIMKey{
+			Domain:   keyCfg.Domain,
+			Selector: keyCfg.Selector,
+			Signer:   signer,
+		})
+	}
+
+	return NewDKIMSigner(keys, cfg.Headers), nil
+}
+
+func ParseDKIMPrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+}
+
+// DKIMPublicKeyRecord returns the TXT record value to publish at
+// <selector>._domainkey.<domain> for the given key.
+func DKIMPublicKeyRecord(signer crypto.Signer) (string, error) {
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub), nil
+	default:
+		return "", fmt.Errorf("unsupported DKIM key type %T", pub)
+	}
+}
+
+// Sign prepends a DKIM-Signature header using the key configured for the
+// sender's domain. Messages from domains without a key are returned unchanged.
+func (s *DKIMSigner) Sign(fromEmail string, message []byte) ([]byte, error) {
+	key, ok := s.keys[strings.ToLower(addressDomain(fromEmail))]
+	if !ok {
+		return message, nil
+	}
+
+	algorithm, hashOpts, err := dkimAlgorithm(key.Signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	fields, body := splitDKIMMessage(message)
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	var signedNames []string
+	var hashed bytes.Buffer
+	used := make(map[string]int)
+	for _, name := range s.headers {
+		field, ok := nextDKIMHeader(fields, name, used)
+		if !ok {
+			continue
+		}
+		signedNames = append(signedNames, name)
+		hashed.WriteString(relaxedHeader(field))
+	}
+
+	header := fmt.Sprintf(
+		"DKIM-Signature: v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s;\r\n\tt=%d; h=%s;\r\n\tbh=%s;\r\n\tb=",
+		algorithm,
+		key.Domain,
+		key.Selector,
+		time.Now().Unix(),
+		strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+	hashed.WriteString(strings.TrimSuffix(relaxedHeader(header), "\r\n"))
+
+	digest := sha256.Sum256(hashed.Bytes())
+	signature, err := key.Signer.Sign(rand.Reader, digest[:], hashOpts)
+	if err != nil {
+		return nil, fmt.Errorf("DKIM signing error: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(header)
+	out.WriteString(foldDKIMValue(base64.StdEncoding.EncodeToString(signature)))
+	out.WriteString("\r\n")
+	out.Write(message)
+	return out.Bytes(), nil
+}
+
+// VerifyDKIM checks the first DKIM-Signature header of a message against a
+// known public key, without any DNS lookups.
+func VerifyDKIM(message []byte, publicKey crypto.PublicKey) error {
+	fields, body := splitDKIMMessage(message)
+
+	var sigField string
+	for _, field := range fields {
+		if dkimHeaderName(field) == "dkim-signature" {
+			sigField = field
+			break
+		}
+	}
+	if sigField == "" {
+		return errors.New("no DKIM-Signature header found")
+	}
+
+	tags := parseDKIMTags(sigField[strings.Index(sigField, ":")+1:])
+	if tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unsupported DKIM canonicalization %q", tags["c"])
+	}
+
+	algorithm, _, err := dkimAlgorithm(publicKey)
+	if err != nil {
+		return err
+	}
+	if tags["a"] != algorithm {
+		return fmt.Errorf("DKIM algorithm %q does not match key", tags["a"])
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		return errors.New("DKIM body hash mismatch")
+	}
+
+	var hashed bytes.Buffer
+	used := make(map[string]int)
+	for _, name := range strings.Split(tags["h"], ":") {
+		field, ok := nextDKIMHeader(fields, strings.ToLower(strings.TrimSpace(name)), used)
+		if ok {
+			hashed.WriteString(relaxedHeader(field))
+		}
+	}
+	unsigned := dkimSignature.ReplaceAllString(sigField, "${1}")
+	hashed.WriteString(strings.TrimSuffix(relaxedHeader(unsigned), "\r\n"))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fmt.Errorf("invalid DKIM signature encoding: %w", err)
+	}
+
+	digest := sha256.Sum256(hashed.Bytes())
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("DKIM signature mismatch: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest[:], signature) {
+			return errors.New("DKIM signature mismatch")
+		}
+	}
+	return nil
+}
+
+func dkimAlgorithm(publicKey crypto.PublicKey) (string, crypto.SignerOpts, error) {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return DKIMAlgorithmRSA, crypto.SHA256, nil
+	case ed25519.PublicKey:
+		return DKIMAlgorithmEd25519, crypto.Hash(0), nil
+	default:
+		return "", nil, fmt.Errorf("unsupported DKIM key type %T", publicKey)
+	}
+}
+
+func splitDKIMMessage(message []byte) ([]string, []byte) {
+	raw := string(message)
+	headerEnd := strings.Index(raw, "\r\n\r\n")
+	var headerBlock, body string
+	if headerEnd < 0 {
+		headerBlock = raw
+	} else {
+		headerBlock = raw[:headerEnd+2]
+		body = raw[headerEnd+4:]
+	}
+
+	var fields []string
+	for _, line := range strings.SplitAfter(headerBlock, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields, []byte(body)
+}
+
+func dkimHeaderName(field string) string {
+	idx := strings.Index(field, ":")
+	if idx < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(field[:idx]))
+}
+
+// nextDKIMHeader returns header instances from the bottom of the header block
+// upwards, as required when the same name is listed more than once in h=.
+func nextDKIMHeader(fields []string, name string, used map[string]int) (string, bool) {
+	skip := used[name]
+	for i := len(fields) - 1; i >= 0; i-- {
+		if dkimHeaderName(fields[i]) != name {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		used[name]++
+		return fields[i], true
+	}
+	return "", false
+}
+
+func relaxedHeader(field string) string {
+	idx := strings.Index(field, ":")
+	name := strings.ToLower(strings.TrimSpace(field[:idx]))
+	value := strings.NewReplacer("\r\n", "", "\n", "").Replace(field[idx+1:])
+	value = strings.TrimSpace(dkimWhitespace.ReplaceAllString(value, " "))
+	return name + ":" + value + "\r\n"
+}
+
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(dkimWhitespace.ReplaceAllString(line, " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func parseDKIMTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(value, ";") {
+		idx := strings.Index(part, "=")
+		if idx < 0 {
+			continue
+		}
+		name := strings.TrimSpace(part[:idx])
+		val := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, part[idx+1:])
+		if name != "h" {
+			tags[name] = val
+		} else {
+			tags[name] = strings.ToLower(val)
+		}
+	}
+	return tags
+}
+
+func foldDKIMValue(value string) string {
+	var b strings.Builder
+	for len(value) > 64 {
+		b.WriteString(value[:64])
+		b.WriteString("\r\n\t ")
+		value = value[64:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
+
+func addressDomain(address string) string {
+	idx := strings.LastIndex(address, "@")
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(address[idx+1:], ">")
+}
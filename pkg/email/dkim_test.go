@@ -0,0 +1,161 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+)
+
+const dkimTestMessage = "From: Sender <sender@example.com>\r\n" +
+	"To: rcpt@example.org\r\n" +
+	"Subject: Monthly update\r\n" +
+	"Date: Mon, 05 Oct 2026 10:00:00 +0000\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hello there,\r\n" +
+	"this is the body.\r\n"
+
+func dkimTestKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{
+		DKIMAlgorithmRSA:     rsaKey,
+		DKIMAlgorithmEd25519: edKey,
+	}
+}
+
+func signTestMessage(t *testing.T, key crypto.Signer, message string) []byte {
+	t.Helper()
+	signer := NewDKIMSigner([]DKIMKey{{Domain: "example.com", Selector: "mail", Signer: key}}, nil)
+	signed, err := signer.Sign("sender@example.com", []byte(message))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return signed
+}
+
+func TestDKIMSignVerify(t *testing.T) {
+	for algorithm, key := range dkimTestKeys(t) {
+		t.Run(algorithm, func(t *testing.T) {
+			signed := signTestMessage(t, key, dkimTestMessage)
+			if !bytes.HasPrefix(signed, []byte("DKIM-Signature: v=1; a="+algorithm+";")) {
+				t.Fatalf("signed message does not start with a %s signature:\n%s", algorithm, signed)
+			}
+			if err := VerifyDKIM(signed, key.Public()); err != nil {
+				t.Errorf("VerifyDKIM: %v", err)
+			}
+
+			tamperedBody := bytes.Replace(signed, []byte("the body"), []byte("a body"), 1)
+			if err := VerifyDKIM(tamperedBody, key.Public()); err == nil {
+				t.Error("VerifyDKIM accepted a changed body")
+			}
+			tamperedHeader := bytes.Replace(signed, []byte("Monthly update"), []byte("Weekly update"), 1)
+			if err := VerifyDKIM(tamperedHeader, key.Public()); err == nil {
+				t.Error("VerifyDKIM accepted a changed subject")
+			}
+		})
+	}
+}
+
+func TestDKIMVerifyRejectsOtherKey(t *testing.T) {
+	keys := dkimTestKeys(t)
+	signed := signTestMessage(t, keys[DKIMAlgorithmEd25519], dkimTestMessage)
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyDKIM(signed, other.Public()); err == nil {
+		t.Error("VerifyDKIM accepted a signature made with another key")
+	}
+	if err = VerifyDKIM(signed, keys[DKIMAlgorithmRSA].Public()); err == nil {
+		t.Error("VerifyDKIM accepted an ed25519 signature for an RSA key")
+	}
+}
+
+func TestDKIMSignUnknownDomain(t *testing.T) {
+	keys := dkimTestKeys(t)
+	signer := NewDKIMSigner([]DKIMKey{{Domain: "example.com", Selector: "mail", Signer: keys[DKIMAlgorithmRSA]}}, nil)
+	message := []byte(dkimTestMessage)
+	signed, err := signer.Sign("sender@example.net", message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !bytes.Equal(signed, message) {
+		t.Error("a message from a domain without a key was changed")
+	}
+}
+
+// Relaxed canonicalization lets relays refold headers, change runs of
+// whitespace and strip trailing whitespace and blank lines without breaking
+// the signature.
+func TestDKIMRelaxedCanonicalization(t *testing.T) {
+	original := strings.Replace(dkimTestMessage, "Hello there,\r\n", "Hello  there,  \r\n", 1) + "\r\n\r\n"
+
+	relayed := strings.NewReplacer(
+		"Subject: Monthly update\r\n", "Subject:   Monthly\r\n\t  update  \r\n",
+		"To: rcpt@example.org\r\n", "to:rcpt@example.org\r\n",
+		"Hello  there,  \r\n", "Hello \tthere,\r\n",
+		"this is the body.\r\n", "this is the body.\t\r\n",
+	).Replace(original)
+	relayed = strings.TrimSuffix(relayed, "\r\n\r\n")
+
+	for algorithm, key := range dkimTestKeys(t) {
+		t.Run(algorithm, func(t *testing.T) {
+			signed := signTestMessage(t, key, original)
+			signature := signed[:len(signed)-len(original)]
+			if err := VerifyDKIM(append(signature, relayed...), key.Public()); err != nil {
+				t.Errorf("VerifyDKIM after relaxed changes: %v", err)
+			}
+		})
+	}
+}
+
+func TestRelaxedHeader(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"Subject: Monthly update\r\n", "subject:Monthly update\r\n"},
+		{"SUBJECT :  Monthly \t update \r\n", "subject:Monthly update\r\n"},
+		{"Subject: Monthly\r\n\t update\r\n", "subject:Monthly update\r\n"},
+		{"X-Empty:\r\n", "x-empty:\r\n"},
+	}
+	for _, tt := range tests {
+		if got := relaxedHeader(tt.field); got != tt.want {
+			t.Errorf("relaxedHeader(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestRelaxedBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"", ""},
+		{"\r\n\r\n", ""},
+		{"line\r\n", "line\r\n"},
+		{"line", "line\r\n"},
+		{"a  \t b \t\r\n\r\n\r\n", "a b\r\n"},
+		{"a\r\n\r\nb\r\n", "a\r\n\r\nb\r\n"},
+	}
+	for _, tt := range tests {
+		if got := string(relaxedBody([]byte(tt.body))); got != tt.want {
+			t.Errorf("relaxedBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
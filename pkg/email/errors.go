@@ -0,0 +1,51 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+)
+
+// PermanentError is a failure that sending the message again cannot fix,
+// such as a message with no recipient.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is a send refused by an HTTP API provider.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP send error: status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsPermanentError reports whether a send failed for good: an SMTP 5xx
+// reply, an HTTP 4xx response other than a timeout or rate limit, or a
+// PermanentError. Other failures, such as 4xx SMTP replies and network
+// errors, may succeed when retried.
+func IsPermanentError(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500 && protoErr.Code < 600
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+			statusErr.StatusCode != http.StatusRequestTimeout &&
+			statusErr.StatusCode != http.StatusTooManyRequests
+	}
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
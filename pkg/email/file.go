@@ -0,0 +1,116 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type FileConfig struct {
+	Dir      string
+	Maildir  bool
+	FromName string
+	FromAddr string
+	DKIM     *DKIMSigner
+}
+
+type FileTransport struct {
+	config  FileConfig
+	counter atomic.Uint64
+}
+
+func NewFileTransport(config FileConfig) (*FileTransport, error) {
+	if config.Dir == "" {
+		return nil, errors.New("file transport directory is required")
+	}
+
+	dirs := []string{config.Dir}
+	if config.Maildir {
+		dirs = []string{
+			filepath.Join(config.Dir, "tmp"),
+			filepath.Join(config.Dir, "new"),
+			filepath.Join(config.Dir, "cur"),
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating mail directory: %w", err)
+		}
+	}
+
+	return &FileTransport{
+		config: config,
+	}, nil
+}
+
+func (t *FileTransport) Send(message Message) (string, error) {
+	if err := prepareMessage(&message, t.config.FromName, t.config.FromAddr); err != nil {
+		return "", err
+	}
+
+	messageID := generateMessageID(message.FromEmail)
+	emailContent, err := composeMessage(message, messageID, t.config.DKIM)
+	if err != nil {
+		return "", err
+	}
+	// Record the envelope sender the way a delivering MTA would.
+	emailContent = append([]byte("Return-Path: <"+message.envelopeSender()+">\r\n"), emailContent...)
+
+	if t.config.Maildir {
+		err = t.writeMaildir(emailContent)
+	} else {
+		err = t.writeEML(messageID, emailContent)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return messageID, nil
+}
+
+func (t *FileTransport) Close() error {
+	return nil
+}
+
+func (t *FileTransport) writeEML(messageID string, content []byte) error {
+	name := strings.NewReplacer("@", "_", "/", "_", "<", "", ">", "").Replace(messageID) + ".eml"
+	path := filepath.Join(t.config.Dir, name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("error writing eml file: %w", err)
+	}
+	return nil
+}
+
+// Maildir delivery writes to tmp/ first and renames into new/ so readers
+// never observe a partially written message.
+func (t *FileTransport) writeMaildir(content []byte) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	hostname = strings.NewReplacer("/", "\\057", ":", "\\072").Replace(hostname)
+
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s",
+		time.Now().Unix(),
+		time.Now().Nanosecond()/1000,
+		os.Getpid(),
+		t.counter.Add(1),
+		hostname,
+	)
+
+	tmpPath := filepath.Join(t.config.Dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return fmt.Errorf("error writing maildir file: %w", err)
+	}
+
+	newPath := filepath.Join(t.config.Dir, "new", name)
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error delivering maildir file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	paragraphTags = map[string]bool{
+		"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"table": true, "blockquote": true, "pre": true, "dl": true, "ul": true, "ol": true,
+	}
+	lineTags = map[string]bool{
+		"div": true, "tr": true, "li": true, "dt": true, "dd": true, "section": true,
+		"article": true, "header": true, "footer": true, "main": true, "nav": true,
+		"aside": true, "center": true, "address": true, "figure": true, "figcaption": true,
+		"form": true, "caption": true, "tbody": true, "thead": true, "tfoot": true,
+	}
+	skipTags = map[string]bool{
+		"head": true, "title": true, "script": true, "style": true, "noscript": true, "template": true,
+	}
+)
+
+// HTMLToText renders an HTML body as readable plain text: block elements
+// become line breaks, headings are underlined, lists get bullets or numbers,
+// table cells are separated by pipes and links are listed as footnotes.
+func HTMLToText(source string) string {
+	w := &textWriter{atLineStart: true}
+
+	for i := 0; i < len(source); {
+		if source[i] != '<' {
+			next := strings.IndexByte(source[i:], '<')
+			if next < 0 {
+				next = len(source) - i
+			}
+			w.text(source[i : i+next])
+			i += next
+			continue
+		}
+
+		if strings.HasPrefix(source[i:], "<!--") {
+			end := strings.Index(source[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+
+		end := tagEnd(source, i+1)
+		if end < 0 {
+			w.text(source[i:])
+			break
+		}
+		name, closing := w.tag(source[i+1 : end])
+		i = end + 1
+
+		// Skip raw text elements wholesale so markup-like content inside
+		// scripts and styles is never interpreted as tags.
+		if !closing && skipTags[name] && (name == "script" || name == "style") {
+			closeTag := strings.Index(strings.ToLower(source[i:]), "</"+name)
+			if closeTag < 0 {
+				break
+			}
+			i += closeTag
+		}
+	}
+
+	return w.String()
+}
+
+type textList struct {
+	ordered bool
+	index   int
+}
+
+type textWriter struct {
+	out          strings.Builder
+	links        []string
+	linkStarts   []int
+	linkHrefs    []string
+	lists        []textList
+	headingStart int
+	headingLevel int
+	pre          int
+	skip         int
+	quote        int
+	cell         int
+	newlines     int
+	atLineStart  bool
+	pendingSpace bool
+}
+
+func (w *textWriter) write(s string) {
+	for _, r := range s {
+		if w.atLineStart && r != '\n' && w.quote > 0 {
+			w.out.WriteString(strings.Repeat("> ", w.quote))
+		}
+		w.out.WriteRune(r)
+		if r == '\n' {
+			w.newlines++
+			w.atLineStart = true
+		} else {
+			w.newlines = 0
+			w.atLineStart = false
+		}
+	}
+}
+
+func (w *textWriter) text(raw string) {
+	if w.skip > 0 {
+		return
+	}
+
+	s := html.UnescapeString(raw)
+	if w.pre > 0 {
+		w.write(s)
+		return
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			w.pendingSpace = true
+			continue
+		}
+		if w.pendingSpace && !w.atLineStart {
+			w.write(" ")
+		}
+		w.pendingSpace = false
+		w.write(string(r))
+	}
+}
+
+func (w *textWriter) breakLines(n int) {
+	w.pendingSpace = false
+	if w.out.Len() == 0 {
+		return
+	}
+	for w.newlines < n {
+		w.write("\n")
+	}
+}
+
+func (w *textWriter) tag(content string) (string, bool) {
+	closing := strings.HasPrefix(content, "/")
+	content = strings.TrimPrefix(content, "/")
+	nameEnd := strings.IndexFunc(content, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '/'
+	})
+	if nameEnd < 0 {
+		nameEnd = len(content)
+	}
+	name := strings.ToLower(content[:nameEnd])
+	attrs := content[nameEnd:]
+
+	if skipTags[name] {
+		if closing {
+			if w.skip > 0 {
+				w.skip--
+			}
+		} else if !strings.HasSuffix(attrs, "/") {
+			w.skip++
+		}
+		return name, closing
+	}
+	if w.skip > 0 {
+		return name, closing
+	}
+
+	switch {
+	case name == "br":
+		w.pendingSpace = false
+		w.write("\n")
+	case name == "hr":
+		w.breakLines(2)
+		w.write(strings.Repeat("-", 40))
+		w.breakLines(2)
+	case name == "img":
+		if alt := tagAttribute(attrs, "alt"); alt != "" {
+			w.text(alt)
+		}
+	case name == "a":
+		w.link(closing, attrs)
+	case name == "td" || name == "th":
+		if !closing {
+			if w.cell > 0 {
+				w.write(" | ")
+			}
+			w.cell++
+		}
+	case name == "ul" || name == "ol":
+		w.list(closing, name == "ol")
+	case name == "li":
+		w.breakLines(1)
+		if !closing && len(w.lists) > 0 {
+			list := &w.lists[len(w.lists)-1]
+			list.index++
+			w.write(strings.Repeat("  ", len(w.lists)-1))
+			if list.ordered {
+				w.write(strconv.Itoa(list.index) + ". ")
+			} else {
+				w.write("* ")
+			}
+		}
+	case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+		w.heading(closing, int(name[1]-'0'))
+	case paragraphTags[name]:
+		w.breakLines(2)
+		switch {
+		case name == "pre" && closing && w.pre > 0:
+			w.pre--
+		case name == "pre":
+			w.pre++
+		case name == "blockquote" && closing && w.quote > 0:
+			w.quote--
+		case name == "blockquote":
+			w.quote++
+		}
+	case lineTags[name]:
+		w.breakLines(1)
+		if name == "tr" {
+			w.cell = 0
+		}
+	}
+
+	return name, closing
+}
+
+func (w *textWriter) list(closing, ordered bool) {
+	if closing {
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+	} else {
+		w.lists = append(w.lists, textList{ordered: ordered})
+	}
+
+	if len(w.lists) == 0 {
+		w.breakLines(2)
+	} else {
+		w.breakLines(1)
+	}
+}
+
+func (w *textWriter) heading(closing bool, level int) {
+	if !closing {
+		w.breakLines(2)
+		w.headingStart = w.out.Len()
+		w.headingLevel = level
+		return
+	}
+	if w.headingLevel == 0 {
+		return
+	}
+
+	title := strings.TrimSpace(w.out.String()[w.headingStart:])
+	if idx := strings.LastIndex(title, "\n"); idx >= 0 {
+		title = title[idx+1:]
+	}
+	underline := "-"
+	if w.headingLevel == 1 {
+		underline = "="
+	}
+	if length := utf8.RuneCountInString(title); length > 0 {
+		w.breakLines(1)
+		w.write(strings.Repeat(underline, length))
+	}
+	w.headingLevel = 0
+	w.breakLines(2)
+}
+
+func (w *textWriter) link(closing bool, attrs string) {
+	if !closing {
+		w.linkHrefs = append(w.linkHrefs, strings.TrimSpace(html.UnescapeString(tagAttribute(attrs, "href"))))
+		w.linkStarts = append(w.linkStarts, w.out.Len())
+		return
+	}
+	if len(w.linkHrefs) == 0 {
+		return
+	}
+
+	href := w.linkHrefs[len(w.linkHrefs)-1]
+	start := w.linkStarts[len(w.linkStarts)-1]
+	w.linkHrefs = w.linkHrefs[:len(w.linkHrefs)-1]
+	w.linkStarts = w.linkStarts[:len(w.linkStarts)-1]
+
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:") {
+		return
+	}
+
+	label := strings.TrimSpace(w.out.String()[start:])
+	if label == href || "mailto:"+label == href {
+		return
+	}
+	if label == "" {
+		w.text(href)
+		return
+	}
+
+	number := 0
+	for i, existing := range w.links {
+		if existing == href {
+			number = i + 1
+			break
+		}
+	}
+	if number == 0 {
+		w.links = append(w.links, href)
+		number = len(w.links)
+	}
+	w.write(fmt.Sprintf(" [%d]", number))
+}
+
+func (w *textWriter) String() string {
+	lines := strings.Split(w.out.String(), "\n")
+	cleaned := make([]string, 0, len(lines))
+	blank := true
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			if !blank {
+				cleaned = append(cleaned, "")
+			}
+			blank = true
+			continue
+		}
+		cleaned = append(cleaned, line)
+		blank = false
+	}
+	text := strings.TrimSpace(strings.Join(cleaned, "\n"))
+
+	if len(w.links) > 0 {
+		var b strings.Builder
+		b.WriteString(text)
+		b.WriteString("\n\nLinks:\n")
+		for i, link := range w.links {
+			fmt.Fprintf(&b, "[%d] %s\n", i+1, link)
+		}
+		text = strings.TrimSuffix(b.String(), "\n")
+	}
+	return text
+}
+
+// tagEnd finds the closing '>' of a tag, ignoring any inside quoted
+// attribute values.
+func tagEnd(source string, start int) int {
+	var quote byte
+	for i := start; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+func tagAttribute(attrs, name string) string {
+	for i := 0; i < len(attrs); {
+		for i < len(attrs) && (unicode.IsSpace(rune(attrs[i])) || attrs[i] == '/') {
+			i++
+		}
+		start := i
+		for i < len(attrs) && attrs[i] != '=' && !unicode.IsSpace(rune(attrs[i])) && attrs[i] != '/' {
+			i++
+		}
+		key := strings.ToLower(attrs[start:i])
+		for i < len(attrs) && unicode.IsSpace(rune(attrs[i])) {
+			i++
+		}
+
+		value := ""
+		if i < len(attrs) && attrs[i] == '=' {
+			i++
+			for i < len(attrs) && unicode.IsSpace(rune(attrs[i])) {
+				i++
+			}
+			if i < len(attrs) && (attrs[i] == '"' || attrs[i] == '\'') {
+				quote := attrs[i]
+				i++
+				end := strings.IndexByte(attrs[i:], quote)
+				if end < 0 {
+					end = len(attrs) - i
+				}
+				value = attrs[i : i+end]
+				i += end + 1
+			} else {
+				start := i
+				for i < len(attrs) && !unicode.IsSpace(rune(attrs[i])) {
+					i++
+				}
+				value = attrs[start:i]
+			}
+		}
+
+		if key == name {
+			return html.UnescapeString(value)
+		}
+		if key == "" && i == start {
+			i++
+		}
+	}
+	return ""
+}
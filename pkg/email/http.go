@@ -0,0 +1,152 @@
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type HTTPConfig struct {
+	URL      string
+	APIKey   string
+	Timeout  time.Duration
+	FromName string
+	FromAddr string
+}
+
+type HTTPTransport struct {
+	config HTTPConfig
+	client *http.Client
+}
+
+type httpPayload struct {
+	MessageID   string            `json:"message_id"`
+	FromEmail   string            `json:"from_email"`
+	FromName    string            `json:"from_name"`
+	ReturnPath  string            `json:"return_path,omitempty"`
+	To          string            `json:"to"`
+	ToName      string            `json:"to_name,omitempty"`
+	Subject     string            `json:"subject"`
+	HTML        string            `json:"html,omitempty"`
+	Text        string            `json:"text,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Attachments []httpAttachment  `json:"attachments,omitempty"`
+}
+
+type httpAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+	Inline      bool   `json:"inline,omitempty"`
+	Content     []byte `json:"content"`
+}
+
+type httpResponse struct {
+	MessageID string `json:"message_id"`
+	ID        string `json:"id"`
+}
+
+func NewHTTPTransport(config HTTPConfig) (*HTTPTransport, error) {
+	if config.URL == "" {
+		return nil, errors.New("http transport URL is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &HTTPTransport{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+func (t *HTTPTransport) Send(message Message) (string, error) {
+	if err := prepareMessage(&message, t.config.FromName, t.config.FromAddr); err != nil {
+		return "", err
+	}
+
+	messageID := generateMessageID(message.FromEmail)
+	body, err := json.Marshal(httpPayload{
+		MessageID:   messageID,
+		FromEmail:   message.FromEmail,
+		FromName:    message.FromName,
+		ReturnPath:  message.ReturnPath,
+		To:          message.To,
+		ToName:      message.ToName,
+		Subject:     message.Subject,
+		HTML:        message.HTML,
+		Text:        message.Text,
+		Headers:     message.Headers,
+		Attachments: httpAttachments(message),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding HTTP payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.config.APIKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP send error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("HTTP response read error: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(respBody))}
+	}
+
+	var parsed httpResponse
+	if len(respBody) > 0 && json.Unmarshal(respBody, &parsed) == nil {
+		if parsed.MessageID != "" {
+			return parsed.MessageID, nil
+		}
+		if parsed.ID != "" {
+			return parsed.ID, nil
+		}
+	}
+
+	return messageID, nil
+}
+
+func httpAttachments(message Message) []httpAttachment {
+	attachments := make([]httpAttachment, 0, len(message.Attachments)+len(message.Inline))
+	for _, attachment := range message.Attachments {
+		attachments = append(attachments, httpAttachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			ContentID:   attachment.ContentID,
+			Content:     attachment.Content,
+		})
+	}
+	for _, inline := range message.Inline {
+		attachments = append(attachments, httpAttachment{
+			Filename:    inline.Filename,
+			ContentType: inline.ContentType,
+			ContentID:   inline.ContentID,
+			Inline:      true,
+			Content:     inline.Content,
+		})
+	}
+	return attachments
+}
+
+func (t *HTTPTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+)
+
+const (
+	TransportSMTP = "smtp"
+	TransportFile = "file"
+	TransportHTTP = "http"
+)
+
+type Mailer interface {
+	Send(message Message) (string, error)
+	Close() error
+}
+
+func NewMailer(cfg config.Config) (Mailer, error) {
+	dkim, err := NewDKIMSignerFromConfig(cfg.DKIM)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(cfg.Transport.Type) {
+	case "", TransportSMTP:
+		return NewSMTPClient(SMTPConfig{
+			Host:         cfg.SMTP.Host,
+			Port:         cfg.SMTP.Port,
+			Username:     cfg.SMTP.Username,
+			Password:     cfg.SMTP.Password,
+			FromName:     cfg.SMTP.FromName,
+			FromAddr:     cfg.SMTP.FromAddr,
+			UseTLS:       cfg.SMTP.UseTLS,
+			Security:     cfg.SMTP.Security,
+			MaxIdleConns: cfg.SMTP.MaxIdleConns,
+			IdleTimeout:  cfg.SMTP.IdleTimeout,
+			DialTimeout:  cfg.SMTP.DialTimeout,
+			DKIM:         dkim,
+		}), nil
+	case TransportFile:
+		return NewFileTransport(FileConfig{
+			Dir:      cfg.Transport.File.Dir,
+			Maildir:  cfg.Transport.File.Maildir,
+			FromName: cfg.SMTP.FromName,
+			FromAddr: cfg.SMTP.FromAddr,
+			DKIM:     dkim,
+		})
+	case TransportHTTP:
+		return NewHTTPTransport(HTTPConfig{
+			URL:      cfg.Transport.HTTP.URL,
+			APIKey:   cfg.Transport.HTTP.APIKey,
+			Timeout:  cfg.Transport.HTTP.Timeout,
+			FromName: cfg.SMTP.FromName,
+			FromAddr: cfg.SMTP.FromAddr,
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail transport: %s", cfg.Transport.Type)
+	}
+}
@@ -0,0 +1,353 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxHeaderLineLength = 76
+
+// ReturnPath is the envelope sender that bounces are returned to; it
+// defaults to FromEmail.
+type Message struct {
+	FromEmail   string
+	FromName    string
+	ReturnPath  string
+	To          string
+	ToName      string
+	Subject     string
+	HTML        string
+	Text        string
+	Headers     map[string]string
+	Attachments []Attachment
+	Inline      []Attachment
+}
+
+// Attachment is sent as multipart/mixed, or as multipart/related when it is
+// listed in Message.Inline and referenced from the HTML as cid:<ContentID>.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Content     []byte
+}
+
+func prepareMessage(message *Message, fromName, fromAddr string) error {
+	if message.To == "" {
+		return &PermanentError{Err: errors.New("recipient email is required")}
+	}
+	if message.HTML == "" && message.Text == "" {
+		return &PermanentError{Err: errors.New("either HTML or text content is required")}
+	}
+
+	if message.FromEmail == "" {
+		message.FromEmail = fromAddr
+	}
+	if message.FromName == "" {
+		message.FromName = fromName
+	}
+
+	for _, inline := range message.Inline {
+		if inline.ContentID == "" {
+			return &PermanentError{Err: errors.New("inline attachments require a content ID")}
+		}
+	}
+	return nil
+}
+
+func (m Message) envelopeSender() string {
+	if m.ReturnPath != "" {
+		return m.ReturnPath
+	}
+	return m.FromEmail
+}
+
+func composeMessage(message Message, messageID string, signer *DKIMSigner) ([]byte, error) {
+	content, err := buildMIMEMessage(message, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if signer == nil {
+		return content, nil
+	}
+	return signer.Sign(message.FromEmail, content)
+}
+
+func buildMIMEMessage(message Message, messageID string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "From", formatAddress(message.FromName, message.FromEmail))
+	writeHeader(&buf, "To", formatAddress(message.ToName, message.To))
+	writeHeader(&buf, "Subject", encodeHeader(message.Subject))
+	writeHeader(&buf, "Message-ID", "<"+messageID+">")
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	names := make([]string, 0, len(message.Headers))
+	for name := range message.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := message.Headers[name]
+		if addressHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+			writeHeader(&buf, name, encodeAddressHeader(value))
+		} else {
+			writeHeader(&buf, name, encodeHeader(value))
+		}
+	}
+
+	root := buildMIMETree(message)
+	for _, field := range root.fields() {
+		writeHeader(&buf, field[0], field[1])
+	}
+	buf.WriteString("\r\n")
+
+	if err := root.writeContent(&buf); err != nil {
+		return nil, fmt.Errorf("MIME encoding error: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// buildMIMETree nests the parts as mixed(related(alternative(text, html),
+// inline...), attachments...), collapsing every level that has one child.
+func buildMIMETree(message Message) *mimePart {
+	var bodies []*mimePart
+	if message.Text != "" {
+		bodies = append(bodies, textPart("text/plain", message.Text))
+	}
+	if message.HTML != "" {
+		bodies = append(bodies, textPart("text/html", message.HTML))
+	}
+	root := multipartOf("alternative", bodies)
+
+	if len(message.Inline) > 0 {
+		parts := []*mimePart{root}
+		for _, inline := range message.Inline {
+			parts = append(parts, attachmentPart(inline, "inline"))
+		}
+		root = multipartOf("related", parts)
+	}
+
+	if len(message.Attachments) > 0 {
+		parts := []*mimePart{root}
+		for _, attachment := range message.Attachments {
+			parts = append(parts, attachmentPart(attachment, "attachment"))
+		}
+		root = multipartOf("mixed", parts)
+	}
+
+	return root
+}
+
+type mimePart struct {
+	contentType string
+	headers     [][2]string
+	encoding    string
+	content     []byte
+	boundary    string
+	children    []*mimePart
+}
+
+func textPart(contentType, content string) *mimePart {
+	return &mimePart{
+		contentType: contentType + "; charset=UTF-8",
+		encoding:    "quoted-printable",
+		content:     []byte(content),
+	}
+}
+
+func attachmentPart(attachment Attachment, disposition string) *mimePart {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(attachment.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	part := &mimePart{
+		contentType: contentType,
+		encoding:    "base64",
+		content:     attachment.Content,
+	}
+	if attachment.Filename != "" {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			params["name"] = attachment.Filename
+			part.contentType = mime.FormatMediaType(mediaType, params)
+		}
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename})
+	}
+	part.headers = append(part.headers, [2]string{"Content-Disposition", disposition})
+	if attachment.ContentID != "" {
+		part.headers = append(part.headers, [2]string{"Content-ID", "<" + strings.Trim(attachment.ContentID, "<>") + ">"})
+	}
+	return part
+}
+
+func multipartOf(subtype string, parts []*mimePart) *mimePart {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return &mimePart{
+		contentType: "multipart/" + subtype,
+		boundary:    randomBoundary(),
+		children:    parts,
+	}
+}
+
+func (p *mimePart) fields() [][2]string {
+	if p.boundary != "" {
+		return [][2]string{{"Content-Type", mime.FormatMediaType(p.contentType, map[string]string{"boundary": p.boundary})}}
+	}
+
+	fields := [][2]string{{"Content-Type", p.contentType}}
+	fields = append(fields, p.headers...)
+	return append(fields, [2]string{"Content-Transfer-Encoding", p.encoding})
+}
+
+func (p *mimePart) writeContent(w io.Writer) error {
+	if p.boundary != "" {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(p.boundary); err != nil {
+			return err
+		}
+		for _, child := range p.children {
+			header := make(textproto.MIMEHeader)
+			for _, field := range child.fields() {
+				header.Set(field[0], field[1])
+			}
+			pw, err := mw.CreatePart(header)
+			if err != nil {
+				return err
+			}
+			if err = child.writeContent(pw); err != nil {
+				return err
+			}
+		}
+		return mw.Close()
+	}
+
+	if p.encoding == "base64" {
+		return writeBase64(w, p.content)
+	}
+
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write(p.content); err != nil {
+		return err
+	}
+	if err := qw.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+func writeBase64(w io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 0 {
+		n := min(len(encoded), maxHeaderLineLength)
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// writeHeader folds long values at whitespace; CR and LF are stripped so
+// caller-supplied values cannot inject extra headers.
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+
+	lineLength := len(name) + 1
+	buf.WriteString(name)
+	buf.WriteString(":")
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && lineLength+1+len(word) > maxHeaderLineLength {
+			buf.WriteString("\r\n")
+			lineLength = 0
+		}
+		buf.WriteString(" ")
+		buf.WriteString(word)
+		lineLength += 1 + len(word)
+	}
+	buf.WriteString("\r\n")
+}
+
+func encodeHeader(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return mime.QEncoding.Encode("UTF-8", value)
+		}
+	}
+	return value
+}
+
+// addressHeaders are the custom headers that hold address lists, whose
+// display names are encoded one by one.
+var addressHeaders = map[string]bool{
+	"Reply-To": true,
+	"Cc":       true,
+	"Sender":   true,
+}
+
+// encodeAddressHeader encodes only the display names in an address list, as
+// encoding the whole value would hide the addresses. A value that does not
+// parse is encoded as text.
+func encodeAddressHeader(value string) string {
+	addresses, err := mail.ParseAddressList(value)
+	if err != nil {
+		return encodeHeader(value)
+	}
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		formatted[i] = formatAddress(address.Name, address.Address)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func formatAddress(name, address string) string {
+	if name == "" {
+		return address
+	}
+	return (&mail.Address{Name: name, Address: address}).String()
+}
+
+func randomBoundary() string {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func generateMessageID(sender string) string {
+	domain := addressDomain(sender)
+	if domain == "" {
+		domain = "localhost"
+	}
+
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(b[:]), domain)
+}
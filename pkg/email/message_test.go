@@ -0,0 +1,74 @@
+package email
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildMIMEMessageAddressHeaders(t *testing.T) {
+	message := Message{
+		FromEmail: "news@example.com",
+		FromName:  "Café News",
+		To:        "rcpt@example.org",
+		Subject:   "Hello",
+		Text:      "Hi",
+		Headers: map[string]string{
+			"Reply-To": "Zoë Support <support@example.com>",
+			"cc":       "Ann Ågren <ann@example.org>, bob@example.org",
+			"Sender":   "plain@example.com",
+			"X-Note":   "crème brûlée",
+			"X-Broken": "Zoë <not an address",
+		},
+	}
+	raw, err := buildMIMEMessage(message, "1@example.com")
+	if err != nil {
+		t.Fatalf("buildMIMEMessage: %v", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v\n%s", err, raw)
+	}
+
+	tests := []struct {
+		header string
+		want   []mail.Address
+	}{
+		{"From", []mail.Address{{Name: "Café News", Address: "news@example.com"}}},
+		{"Reply-To", []mail.Address{{Name: "Zoë Support", Address: "support@example.com"}}},
+		{"Cc", []mail.Address{{Name: "Ann Ågren", Address: "ann@example.org"}, {Address: "bob@example.org"}}},
+		{"Sender", []mail.Address{{Address: "plain@example.com"}}},
+	}
+	for _, tt := range tests {
+		addresses, err := parsed.Header.AddressList(tt.header)
+		if err != nil {
+			t.Errorf("%s: %v (%q)", tt.header, err, parsed.Header.Get(tt.header))
+			continue
+		}
+		if len(addresses) != len(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.header, addresses, tt.want)
+			continue
+		}
+		for i, address := range addresses {
+			if *address != tt.want[i] {
+				t.Errorf("%s[%d] = %+v, want %+v", tt.header, i, *address, tt.want[i])
+			}
+		}
+		// The address itself is never inside an encoded word.
+		for _, want := range tt.want {
+			if !strings.Contains(parsed.Header.Get(tt.header), want.Address) {
+				t.Errorf("%s = %q does not show %s", tt.header, parsed.Header.Get(tt.header), want.Address)
+			}
+		}
+	}
+
+	decoder := new(mime.WordDecoder)
+	for header, want := range map[string]string{"X-Note": "crème brûlée", "X-Broken": "Zoë <not an address"} {
+		got, err := decoder.DecodeHeader(parsed.Header.Get(header))
+		if err != nil || got != want {
+			t.Errorf("%s = %q (%v), want %q", header, got, err, want)
+		}
+	}
+}
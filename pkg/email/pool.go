@@ -0,0 +1,134 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type smtpPool struct {
+	config SMTPConfig
+	mutex  sync.Mutex
+	idle   []*smtpConn
+	closed bool
+}
+
+func newSMTPPool(config SMTPConfig) *smtpPool {
+	return &smtpPool{
+		config: config,
+		idle:   make([]*smtpConn, 0, config.MaxIdleConns),
+	}
+}
+
+func (p *smtpPool) get() (*smtpConn, error) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil, errors.New("SMTP pool is closed")
+	}
+
+	var conn *smtpConn
+	for len(p.idle) > 0 {
+		last := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if time.Since(last.lastUsed) < p.config.IdleTimeout {
+			conn = last
+			break
+		}
+		go last.close()
+	}
+	p.mutex.Unlock()
+
+	if conn != nil {
+		return conn, nil
+	}
+	return p.dial()
+}
+
+func (p *smtpPool) put(conn *smtpConn) {
+	conn.lastUsed = time.Now()
+
+	p.mutex.Lock()
+	if p.closed || len(p.idle) >= p.config.MaxIdleConns {
+		p.mutex.Unlock()
+		conn.close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+	p.mutex.Unlock()
+}
+
+func (p *smtpPool) close() {
+	p.mutex.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mutex.Unlock()
+
+	for _, conn := range idle {
+		conn.close()
+	}
+}
+
+func (p *smtpPool) dial() (*smtpConn, error) {
+	addr := net.JoinHostPort(p.config.Host, strconv.Itoa(p.config.Port))
+	tlsConfig := &tls.Config{
+		ServerName: p.config.Host,
+	}
+	dialer := &net.Dialer{Timeout: p.config.DialTimeout}
+	security := p.config.security()
+
+	var conn net.Conn
+	var err error
+	if security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("TLS connection error: %w", err)
+		}
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("SMTP connection error: %w", err)
+		}
+	}
+
+	client, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SMTP client creation error: %w", err)
+	}
+
+	if security != SecurityTLS {
+		ok, _ := client.Extension("STARTTLS")
+		if !ok && security == SecurityStartTLS {
+			client.Close()
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("SMTP STARTTLS error: %w", err)
+			}
+		}
+	}
+
+	if p.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host)
+			if err = client.Auth(auth); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("SMTP authentication error: %w", err)
+			}
+		}
+	}
+
+	return &smtpConn{
+		client:   client,
+		lastUsed: time.Now(),
+	}, nil
+}
@@ -1,8 +1,6 @@
 package email
 
 import (
-	"bytes"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/smtp"
@@ -10,161 +8,174 @@ import (
 	"time"
 )
 
+const (
+	SecurityPlain    = "plain"
+	SecurityTLS      = "tls"
+	SecurityStartTLS = "starttls"
+)
+
 type SMTPConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-	FromName string
-	FromAddr string
-	UseTLS   bool
+	Host         string
+	Port         int
+	Username     string
+	Password     string
+	FromName     string
+	FromAddr     string
+	UseTLS       bool
+	Security     string
+	MaxIdleConns int
+	IdleTimeout  time.Duration
+	DialTimeout  time.Duration
+	DKIM         *DKIMSigner
 }
 
-type SMTPClient struct {
-	config SMTPConfig
+// Plain connections still upgrade via STARTTLS when the server offers it,
+// matching net/smtp.SendMail; starttls makes the upgrade mandatory.
+func (c SMTPConfig) security() string {
+	switch strings.ToLower(c.Security) {
+	case SecurityTLS:
+		return SecurityTLS
+	case SecurityStartTLS:
+		return SecurityStartTLS
+	case SecurityPlain:
+		return SecurityPlain
+	}
+	if c.UseTLS {
+		return SecurityTLS
+	}
+	return SecurityPlain
 }
 
-type Message struct {
-	FromEmail string
-	FromName  string
-	To        string
-	Subject   string
-	HTML      string
-	Text      string
-	Headers   map[string]string
+type SMTPClient struct {
+	config SMTPConfig
+	pool   *smtpPool
 }
 
 func NewSMTPClient(config SMTPConfig) *SMTPClient {
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 2
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 30 * time.Second
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 10 * time.Second
+	}
+
 	return &SMTPClient{
 		config: config,
+		pool:   newSMTPPool(config),
 	}
 }
 
 func (c *SMTPClient) Send(message Message) (string, error) {
-	if message.To == "" {
-		return "", errors.New("recipient email is required")
-	}
-	if message.HTML == "" && message.Text == "" {
-		return "", errors.New("either HTML or text content is required")
-	}
-
-	if message.FromEmail == "" {
-		message.FromEmail = c.config.FromAddr
-	}
-	if message.FromName == "" {
-		message.FromName = c.config.FromName
+	if err := prepareMessage(&message, c.config.FromName, c.config.FromAddr); err != nil {
+		return "", err
 	}
 
-	messageID := generateMessageID(message.To)
-	emailContent, err := c.buildMIMEMessage(message, messageID)
+	messageID := generateMessageID(message.FromEmail)
+	emailContent, err := composeMessage(message, messageID, c.config.DKIM)
 	if err != nil {
 		return "", err
 	}
 
-	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
-	auth := smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
-
-	if c.config.UseTLS {
-		tlsConfig := &tls.Config{
-			ServerName: c.config.Host,
-		}
-
-		conn, err := tls.Dial("tcp", addr, tlsConfig)
-		if err != nil {
-			return "", fmt.Errorf("TLS connection error: %w", err)
-		}
-		defer conn.Close()
-
-		client, err := smtp.NewClient(conn, c.config.Host)
-		if err != nil {
-			return "", fmt.Errorf("SMTP client creation error: %w", err)
-		}
-		defer client.Close()
-
-		if err = client.Auth(auth); err != nil {
-			return "", fmt.Errorf("SMTP authentication error: %w", err)
-		}
+	if err = c.deliver(message.envelopeSender(), message.To, emailContent); err != nil {
+		return "", err
+	}
 
-		if err = client.Mail(message.FromEmail); err != nil {
-			return "", fmt.Errorf("SMTP FROM error: %w", err)
-		}
+	return messageID, nil
+}
 
-		if err = client.Rcpt(message.To); err != nil {
-			return "", fmt.Errorf("SMTP RCPT error: %w", err)
-		}
+func (c *SMTPClient) Close() error {
+	c.pool.close()
+	return nil
+}
 
-		w, err := client.Data()
+// A failure on the first attempt is retried once on a fresh connection unless
+// the server rejected the message permanently, which covers pooled sessions
+// the server has silently dropped as well as 421 shutdowns. A failure once
+// the message data has been sent is not retried, since the server may have
+// accepted the message and a retry would deliver it twice.
+func (c *SMTPClient) deliver(from, to string, data []byte) error {
+	for attempt := 0; ; attempt++ {
+		conn, err := c.pool.get()
 		if err != nil {
-			return "", fmt.Errorf("SMTP DATA error: %w", err)
+			return err
 		}
 
-		_, err = w.Write([]byte(emailContent))
-		if err != nil {
-			return "", fmt.Errorf("SMTP write error: %w", err)
+		err = conn.send(from, to, data)
+		if err == nil {
+			c.pool.put(conn)
+			return nil
 		}
 
-		err = w.Close()
-		if err != nil {
-			return "", fmt.Errorf("SMTP data close error: %w", err)
+		if IsPermanentError(err) {
+			c.pool.put(conn)
+			return err
 		}
 
-		client.Quit()
-	} else {
-		err = smtp.SendMail(
-			addr,
-			auth,
-			message.FromEmail,
-			[]string{message.To},
-			[]byte(emailContent),
-		)
-		if err != nil {
-			return "", fmt.Errorf("SMTP send error: %w", err)
+		conn.close()
+		var sentErr *dataSentError
+		if attempt > 0 || errors.As(err, &sentErr) {
+			return err
 		}
 	}
+}
 
-	return messageID, nil
+type smtpConn struct {
+	client   *smtp.Client
+	lastUsed time.Time
+	used     bool
 }
 
-func (c *SMTPClient) buildMIMEMessage(message Message, messageID string) (string, error) {
-	var buf bytes.Buffer
+func (c *smtpConn) send(from, to string, data []byte) error {
+	if c.used {
+		if err := c.client.Reset(); err != nil {
+			return fmt.Errorf("SMTP RSET error: %w", err)
+		}
+	}
+	c.used = true
 
-	buf.WriteString(fmt.Sprintf("From: %s <%s>\r\n", message.FromName, message.FromEmail))
-	buf.WriteString(fmt.Sprintf("To: %s\r\n", message.To))
-	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", message.Subject))
-	buf.WriteString(fmt.Sprintf("Message-ID: <%s>\r\n", messageID))
-	buf.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
-	buf.WriteString("MIME-Version: 1.0\r\n")
+	if err := c.client.Mail(from); err != nil {
+		return fmt.Errorf("SMTP FROM error: %w", err)
+	}
 
-	for name, value := range message.Headers {
-		buf.WriteString(fmt.Sprintf("%s: %s\r\n", name, value))
+	if err := c.client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP RCPT error: %w", err)
 	}
 
-	boundary := "boundary_" + messageID[:8]
-	buf.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary))
+	w, err := c.client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA error: %w", err)
+	}
 
-	if message.Text != "" {
-		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
-		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
-		buf.WriteString(message.Text)
-		buf.WriteString("\r\n\r\n")
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("SMTP write error: %w", err)
 	}
 
-	if message.HTML != "" {
-		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
-		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
-		buf.WriteString(message.HTML)
-		buf.WriteString("\r\n\r\n")
+	if err = w.Close(); err != nil {
+		return &dataSentError{Err: fmt.Errorf("SMTP data close error: %w", err)}
 	}
 
-	buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	return nil
+}
+
+// dataSentError is a failure to finish the DATA command, after which it is
+// unknown whether the server accepted the message.
+type dataSentError struct {
+	Err error
+}
 
-	return buf.String(), nil
+func (e *dataSentError) Error() string {
+	return e.Err.Error()
 }
 
-func generateMessageID(recipient string) string {
-	domain := strings.Split(recipient, "@")[1]
-	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%d.%d@%s", timestamp, time.Now().Unix(), domain)
+func (e *dataSentError) Unwrap() error {
+	return e.Err
+}
+
+func (c *smtpConn) close() {
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
 }
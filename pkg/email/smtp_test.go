@@ -0,0 +1,202 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts any number of connections. hangUp decides, for the
+// nth connection, at which command ("MAIL", "RCPT" or "." ending the data)
+// the server drops it without replying; reject gives a reply to send
+// instead of accepting the command.
+type fakeSMTPServer struct {
+	listener net.Listener
+	hangUp   func(conn int, command string) bool
+	reject   func(conn int, command string) string
+
+	mu          sync.Mutex
+	connections int
+	messages    int
+}
+
+func newFakeSMTPServer(t *testing.T, hangUp func(int, string) bool, reject func(int, string) string) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	s := &fakeSMTPServer{listener: listener, hangUp: hangUp, reject: reject}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.connections++
+			n := s.connections
+			s.mu.Unlock()
+			go s.serve(conn, n)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn, n int) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("220 fake ESMTP\r\n"))
+
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if inData && line != "." {
+			continue
+		}
+
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		if strings.HasPrefix(command, "MAIL") {
+			command = "MAIL"
+		} else if strings.HasPrefix(command, "RCPT") {
+			command = "RCPT"
+		}
+		if command == "." {
+			inData = false
+			s.mu.Lock()
+			s.messages++
+			s.mu.Unlock()
+		}
+		if s.hangUp != nil && s.hangUp(n, command) {
+			return
+		}
+		if s.reject != nil {
+			if reply := s.reject(n, command); reply != "" {
+				conn.Write([]byte(reply + "\r\n"))
+				continue
+			}
+		}
+
+		var reply string
+		switch command {
+		case "EHLO":
+			reply = "250-fake\r\n250 8BITMIME"
+		case "DATA":
+			inData = true
+			reply = "354 go ahead"
+		case "QUIT":
+			conn.Write([]byte("221 bye\r\n"))
+			return
+		default:
+			reply = "250 ok"
+		}
+		conn.Write([]byte(reply + "\r\n"))
+	}
+}
+
+func (s *fakeSMTPServer) client() *SMTPClient {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return NewSMTPClient(SMTPConfig{
+		Host:        addr.IP.String(),
+		Port:        addr.Port,
+		Security:    SecurityPlain,
+		DialTimeout: time.Second,
+	})
+}
+
+func (s *fakeSMTPServer) counts() (connections, messages int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections, s.messages
+}
+
+// replyAt rejects command with reply on connection conn, or on every
+// connection when conn is 0.
+func replyAt(conn int, command, reply string) func(int, string) string {
+	return func(n int, got string) string {
+		if (conn == 0 || n == conn) && got == command {
+			return reply
+		}
+		return ""
+	}
+}
+
+func TestSMTPDeliverRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		hangUp      func(int, string) bool
+		reject      func(int, string) string
+		wantErr     bool
+		connections int
+		messages    int
+	}{
+		{
+			name:        "delivered",
+			connections: 1,
+			messages:    1,
+		},
+		{
+			name:        "dropped before the data is retried",
+			hangUp:      func(conn int, command string) bool { return conn == 1 && command == "RCPT" },
+			connections: 2,
+			messages:    1,
+		},
+		{
+			name:        "temporary rejection is retried",
+			reject:      replyAt(1, "MAIL", "421 shutting down"),
+			connections: 2,
+			messages:    1,
+		},
+		{
+			name:        "permanent rejection is not retried",
+			reject:      replyAt(0, "RCPT", "550 no such user"),
+			wantErr:     true,
+			connections: 1,
+			messages:    0,
+		},
+		{
+			name:        "dropped after the data is not retried",
+			hangUp:      func(conn int, command string) bool { return command == "." },
+			wantErr:     true,
+			connections: 1,
+			messages:    1,
+		},
+		{
+			name:        "temporary failure after the data is not retried",
+			reject:      replyAt(0, ".", "451 try later"),
+			wantErr:     true,
+			connections: 1,
+			messages:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, tt.hangUp, tt.reject)
+			client := server.client()
+			defer client.Close()
+
+			err := client.deliver("sender@example.com", "rcpt@example.org", []byte("Subject: hi\r\n\r\nhello\r\n"))
+			if tt.wantErr && err == nil {
+				t.Error("deliver succeeded, want an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("deliver: %v", err)
+			}
+			connections, messages := server.counts()
+			if connections != tt.connections || messages != tt.messages {
+				t.Errorf("server saw %d connections and %d messages, want %d and %d",
+					connections, messages, tt.connections, tt.messages)
+			}
+		})
+	}
+}
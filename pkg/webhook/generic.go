@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Generic accepts events in this service's own format, for relays and
+// providers without a dedicated parser. Requests are signed with
+//
+//	X-Webhook-Timestamp: <unix seconds>
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256(secret, timestamp + "." + body)>
+//
+// and the body is one event or an array of them:
+//
+//	{"event": "bounce", "message_id": "...", "email": "...", "bounce_type": "hard",
+//	 "reason": "...", "url": "...", "timestamp": 1700000000}
+//
+// event is one of delivered, deferred, bounce, dropped, complaint, open,
+// click or unsubscribe.
+type Generic struct {
+	secret []byte
+	maxAge time.Duration
+}
+
+type genericEvent struct {
+	Event      string  `json:"event"`
+	MessageID  string  `json:"message_id"`
+	Email      string  `json:"email"`
+	BounceType string  `json:"bounce_type"`
+	Reason     string  `json:"reason"`
+	URL        string  `json:"url"`
+	Timestamp  float64 `json:"timestamp"`
+}
+
+func NewGeneric(secret string, maxAge time.Duration) *Generic {
+	return &Generic{secret: []byte(secret), maxAge: maxAge}
+}
+
+func (p *Generic) Verify(header http.Header, body []byte) error {
+	timestamp := header.Get("X-Webhook-Timestamp")
+	signature, ok := strings.CutPrefix(header.Get("X-Webhook-Signature"), "sha256=")
+	if !ok || timestamp == "" {
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if err = checkTimestamp(timestamp, p.maxAge); err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *Generic) Parse(body []byte) ([]Event, error) {
+	events, payloads, err := decodeEvents[genericEvent](body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Event, len(events))
+	for i, e := range events {
+		event := Event{
+			Type:         strings.ToLower(e.Event),
+			ProviderType: e.Event,
+			MessageIDs:   messageIDs(e.MessageID),
+			Email:        e.Email,
+			Reason:       e.Reason,
+			URL:          e.URL,
+			OccurredAt:   unixTime(e.Timestamp),
+			Payload:      payloads[i],
+		}
+
+		switch event.Type {
+		case EventBounce:
+			event.BounceType = BounceHard
+			if strings.EqualFold(e.BounceType, BounceSoft) {
+				event.BounceType = BounceSoft
+			}
+		case EventDelivered, EventDeferred, EventDropped, EventComplaint, EventOpen, EventClick, EventUnsubscribe:
+		default:
+			event.Type = EventUnknown
+		}
+		result[i] = event
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const genericSecret = "generic-secret"
+
+func signGeneric(secret string, timestamp time.Time, body string) http.Header {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + body))
+	header := http.Header{}
+	header.Set("X-Webhook-Timestamp", ts)
+	header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return header
+}
+
+func TestGenericVerify(t *testing.T) {
+	provider := NewGeneric(genericSecret, 10*time.Minute)
+	body := `{"event":"bounce","email":"gone@example.org"}`
+
+	unprefixed := signGeneric(genericSecret, time.Now(), body)
+	unprefixed.Set("X-Webhook-Signature", unprefixed.Get("X-Webhook-Signature")[len("sha256="):])
+	notHex := signGeneric(genericSecret, time.Now(), body)
+	notHex.Set("X-Webhook-Signature", "sha256=zz")
+	retimed := signGeneric(genericSecret, time.Now(), body)
+	retimed.Set("X-Webhook-Timestamp", strconv.FormatInt(time.Now().Unix()-5, 10))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		valid  bool
+	}{
+		{"valid", signGeneric(genericSecret, time.Now(), body), body, true},
+		{"tampered body", signGeneric(genericSecret, time.Now(), body), `{"event":"bounce","email":"other@example.org"}`, false},
+		{"changed timestamp", retimed, body, false},
+		{"other secret", signGeneric("another-secret", time.Now(), body), body, false},
+		{"stale timestamp", signGeneric(genericSecret, time.Now().Add(-time.Hour), body), body, false},
+		{"future timestamp", signGeneric(genericSecret, time.Now().Add(time.Hour), body), body, false},
+		{"no prefix", unprefixed, body, false},
+		{"not hex", notHex, body, false},
+		{"unsigned", http.Header{}, body, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.Verify(tt.header, []byte(tt.body))
+			if tt.valid && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify: err = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestGenericParse(t *testing.T) {
+	provider := NewGeneric(genericSecret, time.Minute)
+	body := `[
+		{"event":"bounce","message_id":"<job-1@example.com>","email":"gone@example.org","reason":"550 User unknown","timestamp":1700000000},
+		{"event":"Bounce","email":"busy@example.org","bounce_type":"SOFT"},
+		{"event":"click","email":"reader@example.org","url":"https://example.com"},
+		{"event":"teleported","email":"reader@example.org"}
+	]`
+	events, err := provider.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+
+	first := events[0]
+	if first.Type != EventBounce || first.BounceType != BounceHard || first.Reason != "550 User unknown" {
+		t.Errorf("first event: %+v", first)
+	}
+	if len(first.MessageIDs) != 1 || first.MessageIDs[0] != "job-1@example.com" {
+		t.Errorf("MessageIDs = %v", first.MessageIDs)
+	}
+	if !first.OccurredAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("OccurredAt = %v", first.OccurredAt)
+	}
+	if events[1].Type != EventBounce || events[1].BounceType != BounceSoft || events[1].ProviderType != "Bounce" {
+		t.Errorf("second event: %+v", events[1])
+	}
+	if events[2].Type != EventClick || events[2].URL != "https://example.com" {
+		t.Errorf("third event: %+v", events[2])
+	}
+	if events[3].Type != EventUnknown || events[3].Payload["event"] != "teleported" {
+		t.Errorf("fourth event: %+v", events[3])
+	}
+
+	single, err := provider.Parse([]byte(`{"event":"open","email":"reader@example.org"}`))
+	if err != nil || len(single) != 1 || single[0].Type != EventOpen {
+		t.Errorf("Parse of a single event = %+v, %v", single, err)
+	}
+
+	for _, invalid := range []string{`[{"event":`, `"bounce"`, `[1, 2]`} {
+		if _, err := provider.Parse([]byte(invalid)); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Parse(%s): err = %v, want ErrInvalidPayload", invalid, err)
+		}
+	}
+}
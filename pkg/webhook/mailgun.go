@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Mailgun verifies the signature Mailgun includes in each webhook body, an
+// HMAC of its timestamp and token under the webhook signing key.
+type Mailgun struct {
+	signingKey []byte
+	maxAge     time.Duration
+}
+
+type mailgunPayload struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData json.RawMessage `json:"event-data"`
+}
+
+type mailgunEvent struct {
+	Event     string  `json:"event"`
+	ID        string  `json:"id"`
+	Timestamp float64 `json:"timestamp"`
+	Severity  string  `json:"severity"`
+	Reason    string  `json:"reason"`
+	Recipient string  `json:"recipient"`
+	URL       string  `json:"url"`
+	Message   struct {
+		Headers struct {
+			MessageID string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+	DeliveryStatus struct {
+		Code        int    `json:"code"`
+		Message     string `json:"message"`
+		Description string `json:"description"`
+	} `json:"delivery-status"`
+}
+
+func NewMailgun(signingKey string, maxAge time.Duration) *Mailgun {
+	return &Mailgun{signingKey: []byte(signingKey), maxAge: maxAge}
+}
+
+func (p *Mailgun) Verify(header http.Header, body []byte) error {
+	var payload mailgunPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ErrInvalidSignature
+	}
+	signature := payload.Signature
+	expected, err := hex.DecodeString(signature.Signature)
+	if err != nil || signature.Token == "" {
+		return ErrInvalidSignature
+	}
+	if err = checkTimestamp(signature.Timestamp, p.maxAge); err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, p.signingKey)
+	mac.Write([]byte(signature.Timestamp + signature.Token))
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *Mailgun) Parse(body []byte) ([]Event, error) {
+	var payload mailgunPayload
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.EventData) == 0 {
+		return nil, ErrInvalidPayload
+	}
+	var e mailgunEvent
+	if err := json.Unmarshal(payload.EventData, &e); err != nil {
+		return nil, ErrInvalidPayload
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload.EventData, &raw); err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	status := e.DeliveryStatus
+	reason := firstNonEmpty(status.Description, status.Message, e.Reason)
+	if status.Code != 0 && reason != "" {
+		reason = strconv.Itoa(status.Code) + " " + reason
+	}
+	event := Event{
+		ProviderType: e.Event,
+		MessageIDs:   messageIDs(e.Message.Headers.MessageID),
+		Email:        e.Recipient,
+		Reason:       reason,
+		URL:          e.URL,
+		OccurredAt:   unixTime(e.Timestamp),
+		Payload:      raw,
+	}
+
+	switch e.Event {
+	case "delivered":
+		event.Type = EventDelivered
+	case "failed":
+		// Temporary failures are retried by Mailgun; only report the
+		// final outcome.
+		if e.Severity == "temporary" {
+			event.Type = EventDeferred
+			break
+		}
+		if e.Reason == "suppress-bounce" || e.Reason == "suppress-complaint" || e.Reason == "suppress-unsubscribe" {
+			event.Type = EventDropped
+			break
+		}
+		event.Type = EventBounce
+		event.BounceType = BounceHard
+	case "rejected":
+		event.Type = EventDropped
+	case "complained":
+		event.Type = EventComplaint
+	case "opened":
+		event.Type = EventOpen
+	case "clicked":
+		event.Type = EventClick
+	case "unsubscribed":
+		event.Type = EventUnsubscribe
+	default:
+		event.Type = EventUnknown
+	}
+	return []Event{event}, nil
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mailgunSigningKey = "key-0123456789abcdef"
+
+// mailgunBody builds a webhook body around eventData, signed with key at
+// timestamp.
+func mailgunBody(key string, timestamp time.Time, token, eventData string) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(ts + token))
+	return fmt.Sprintf(`{"signature":{"timestamp":%q,"token":%q,"signature":%q},"event-data":%s}`,
+		ts, token, hex.EncodeToString(mac.Sum(nil)), eventData)
+}
+
+func TestMailgunVerify(t *testing.T) {
+	provider := NewMailgun(mailgunSigningKey, 10*time.Minute)
+	eventData := `{"event":"delivered","recipient":"rcpt@example.org"}`
+	valid := mailgunBody(mailgunSigningKey, time.Now(), "token-1", eventData)
+
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{"valid", valid, true},
+		{"other key", mailgunBody("another-key", time.Now(), "token-1", eventData), false},
+		{"tampered token", strings.Replace(valid, `"token":"token-1"`, `"token":"token-2"`, 1), false},
+		{"stale timestamp", mailgunBody(mailgunSigningKey, time.Now().Add(-time.Hour), "token-1", eventData), false},
+		{"future timestamp", mailgunBody(mailgunSigningKey, time.Now().Add(time.Hour), "token-1", eventData), false},
+		{"unsigned", `{"event-data":` + eventData + `}`, false},
+		{"not json", "token-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.Verify(nil, []byte(tt.body))
+			if tt.valid && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify: err = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestMailgunParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventData  string
+		typ        string
+		bounceType string
+		reason     string
+	}{
+		{
+			name: "permanent failure",
+			eventData: `{"event":"failed","severity":"permanent","reason":"bounce","recipient":"gone@example.org",
+				"message":{"headers":{"message-id":"job-1@example.com"}},
+				"delivery-status":{"code":550,"message":"5.1.1 User unknown"}}`,
+			typ:        EventBounce,
+			bounceType: BounceHard,
+			reason:     "550 5.1.1 User unknown",
+		},
+		{
+			name:      "temporary failure",
+			eventData: `{"event":"failed","severity":"temporary","recipient":"busy@example.org","delivery-status":{"code":452,"description":"Mailbox full"}}`,
+			typ:       EventDeferred,
+			reason:    "452 Mailbox full",
+		},
+		{
+			name:      "suppressed",
+			eventData: `{"event":"failed","severity":"permanent","reason":"suppress-bounce","recipient":"gone@example.org"}`,
+			typ:       EventDropped,
+			reason:    "suppress-bounce",
+		},
+		{"complaint", `{"event":"complained","recipient":"angry@example.org"}`, EventComplaint, "", ""},
+		{"open", `{"event":"opened","recipient":"reader@example.org"}`, EventOpen, "", ""},
+		{"unsubscribe", `{"event":"unsubscribed","recipient":"leaver@example.org"}`, EventUnsubscribe, "", ""},
+		{"other", `{"event":"accepted","recipient":"reader@example.org"}`, EventUnknown, "", ""},
+	}
+	provider := NewMailgun(mailgunSigningKey, time.Minute)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := provider.Parse([]byte(mailgunBody(mailgunSigningKey, time.Now(), "t", tt.eventData)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			e := events[0]
+			if e.Type != tt.typ || e.BounceType != tt.bounceType || e.Reason != tt.reason {
+				t.Errorf("got %s/%s %q, want %s/%s %q", e.Type, e.BounceType, e.Reason, tt.typ, tt.bounceType, tt.reason)
+			}
+			if e.Email == "" || e.Payload["recipient"] != e.Email {
+				t.Errorf("Email = %q, Payload = %v", e.Email, e.Payload)
+			}
+		})
+	}
+
+	events, _ := provider.Parse([]byte(mailgunBody(mailgunSigningKey, time.Now(), "t", tests[0].eventData)))
+	if len(events[0].MessageIDs) != 1 || events[0].MessageIDs[0] != "job-1@example.com" {
+		t.Errorf("MessageIDs = %v", events[0].MessageIDs)
+	}
+
+	for _, body := range []string{`{"signature":{}}`, `not json`, `{"event-data":"text"}`} {
+		if _, err := provider.Parse([]byte(body)); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("Parse(%s): err = %v, want ErrInvalidPayload", body, err)
+		}
+	}
+}
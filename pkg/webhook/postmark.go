@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+// Postmark does not sign webhooks; the webhook URL is configured with basic
+// auth credentials, which are checked instead.
+type Postmark struct {
+	username string
+	password string
+}
+
+type postmarkEvent struct {
+	RecordType      string `json:"RecordType"`
+	MessageID       string `json:"MessageID"`
+	Type            string `json:"Type"`
+	Email           string `json:"Email"`
+	Recipient       string `json:"Recipient"`
+	Description     string `json:"Description"`
+	Details         string `json:"Details"`
+	OriginalLink    string `json:"OriginalLink"`
+	SuppressSending bool   `json:"SuppressSending"`
+	BouncedAt       string `json:"BouncedAt"`
+	DeliveredAt     string `json:"DeliveredAt"`
+	ReceivedAt      string `json:"ReceivedAt"`
+	ChangedAt       string `json:"ChangedAt"`
+}
+
+func NewPostmark(username, password string) *Postmark {
+	return &Postmark{username: username, password: password}
+}
+
+func (p *Postmark) Verify(header http.Header, body []byte) error {
+	request := http.Request{Header: header}
+	username, password, ok := request.BasicAuth()
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(username), []byte(p.username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(p.password)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *Postmark) Parse(body []byte) ([]Event, error) {
+	events, payloads, err := decodeEvents[postmarkEvent](body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Event, len(events))
+	for i, e := range events {
+		event := Event{
+			ProviderType: e.RecordType,
+			MessageIDs:   messageIDs(e.MessageID),
+			Email:        firstNonEmpty(e.Email, e.Recipient),
+			Reason:       firstNonEmpty(e.Details, e.Description),
+			URL:          e.OriginalLink,
+			OccurredAt:   postmarkTime(firstNonEmpty(e.BouncedAt, e.DeliveredAt, e.ReceivedAt, e.ChangedAt)),
+			Payload:      payloads[i],
+		}
+
+		switch e.RecordType {
+		case "Delivery":
+			event.Type = EventDelivered
+		case "Bounce":
+			event.Type = EventBounce
+			event.ProviderType = e.RecordType + "/" + e.Type
+			switch e.Type {
+			case "HardBounce", "BadEmailAddress", "ManuallyDeactivated", "Unknown":
+				event.BounceType = BounceHard
+			case "SpamComplaint", "SpamNotification":
+				event.Type = EventComplaint
+			default:
+				// Transient failures, blocks and auto-replies say nothing
+				// lasting about the address.
+				event.BounceType = BounceSoft
+			}
+		case "SpamComplaint":
+			event.Type = EventComplaint
+		case "Open":
+			event.Type = EventOpen
+		case "Click":
+			event.Type = EventClick
+		case "SubscriptionChange":
+			// Reactivations are recorded but change nothing here.
+			event.Type = EventUnknown
+			if e.SuppressSending {
+				event.Type = EventUnsubscribe
+			}
+		default:
+			event.Type = EventUnknown
+		}
+		result[i] = event
+	}
+	return result, nil
+}
+
+func postmarkTime(value string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t
+	}
+	return time.Now()
+}
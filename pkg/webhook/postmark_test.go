@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Postmark does not sign or date its requests, so only the credentials are
+// checked.
+func TestPostmarkVerify(t *testing.T) {
+	provider := NewPostmark("hooks", "s3cret")
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		auth     bool
+		valid    bool
+	}{
+		{"valid", "hooks", "s3cret", true, true},
+		{"wrong password", "hooks", "s3cret!", true, false},
+		{"wrong username", "other", "s3cret", true, false},
+		{"empty credentials", "", "", true, false},
+		{"no credentials", "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodPost, "/webhooks/postmark", nil)
+			if tt.auth {
+				request.SetBasicAuth(tt.username, tt.password)
+			}
+			err := provider.Verify(request.Header, []byte(`{}`))
+			if tt.valid && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify: err = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestPostmarkParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		typ        string
+		bounceType string
+	}{
+		{"delivery", `{"RecordType":"Delivery","MessageID":"m-1","Recipient":"rcpt@example.org","DeliveredAt":"2026-10-05T10:00:00Z"}`, EventDelivered, ""},
+		{"hard bounce", `{"RecordType":"Bounce","Type":"HardBounce","MessageID":"m-1","Email":"gone@example.org","Details":"550 User unknown","BouncedAt":"2026-10-05T10:00:00Z"}`, EventBounce, BounceHard},
+		{"bad address", `{"RecordType":"Bounce","Type":"BadEmailAddress","Email":"gone@example.org"}`, EventBounce, BounceHard},
+		{"soft bounce", `{"RecordType":"Bounce","Type":"SoftBounce","Email":"busy@example.org"}`, EventBounce, BounceSoft},
+		{"auto reply", `{"RecordType":"Bounce","Type":"AutoResponder","Email":"away@example.org"}`, EventBounce, BounceSoft},
+		{"complaint bounce", `{"RecordType":"Bounce","Type":"SpamComplaint","Email":"angry@example.org"}`, EventComplaint, ""},
+		{"complaint", `{"RecordType":"SpamComplaint","Email":"angry@example.org"}`, EventComplaint, ""},
+		{"open", `{"RecordType":"Open","Recipient":"reader@example.org"}`, EventOpen, ""},
+		{"click", `{"RecordType":"Click","Recipient":"reader@example.org","OriginalLink":"https://example.com"}`, EventClick, ""},
+		{"suppressed", `{"RecordType":"SubscriptionChange","Recipient":"leaver@example.org","SuppressSending":true}`, EventUnsubscribe, ""},
+		{"reactivated", `{"RecordType":"SubscriptionChange","Recipient":"back@example.org","SuppressSending":false}`, EventUnknown, ""},
+		{"other", `{"RecordType":"Inbound"}`, EventUnknown, ""},
+	}
+	provider := NewPostmark("hooks", "s3cret")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := provider.Parse([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			if e := events[0]; e.Type != tt.typ || e.BounceType != tt.bounceType {
+				t.Errorf("got %s/%s, want %s/%s", e.Type, e.BounceType, tt.typ, tt.bounceType)
+			}
+		})
+	}
+
+	events, err := provider.Parse([]byte(tests[1].body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := events[0]
+	if e.ProviderType != "Bounce/HardBounce" || e.Email != "gone@example.org" || e.Reason != "550 User unknown" {
+		t.Errorf("got %+v", e)
+	}
+	if len(e.MessageIDs) != 1 || e.MessageIDs[0] != "m-1" {
+		t.Errorf("MessageIDs = %v", e.MessageIDs)
+	}
+	if want := time.Date(2026, 10, 5, 10, 0, 0, 0, time.UTC); !e.OccurredAt.Equal(want) {
+		t.Errorf("OccurredAt = %v, want %v", e.OccurredAt, want)
+	}
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SendGrid verifies the Event Webhook's ECDSA signature with the
+// verification key shown in SendGrid's mail settings.
+type SendGrid struct {
+	publicKey *ecdsa.PublicKey
+	maxAge    time.Duration
+}
+
+type sendGridEvent struct {
+	Event       string  `json:"event"`
+	Email       string  `json:"email"`
+	Timestamp   float64 `json:"timestamp"`
+	SMTPID      string  `json:"smtp-id"`
+	SGMessageID string  `json:"sg_message_id"`
+	Type        string  `json:"type"`
+	Reason      string  `json:"reason"`
+	Response    string  `json:"response"`
+	Status      string  `json:"status"`
+	URL         string  `json:"url"`
+}
+
+// NewSendGrid accepts the key as base64 DER, as SendGrid shows it, or PEM.
+func NewSendGrid(publicKey string, maxAge time.Duration) (*SendGrid, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(publicKey)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+		if err != nil {
+			return nil, errors.New("invalid SendGrid webhook public key")
+		}
+		der = decoded
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.New("invalid SendGrid webhook public key")
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("SendGrid webhook public key is not an ECDSA key")
+	}
+	return &SendGrid{publicKey: ecdsaKey, maxAge: maxAge}, nil
+}
+
+func (p *SendGrid) Verify(header http.Header, body []byte) error {
+	timestamp := header.Get("X-Twilio-Email-Event-Webhook-Timestamp")
+	signature, err := base64.StdEncoding.DecodeString(header.Get("X-Twilio-Email-Event-Webhook-Signature"))
+	if err != nil || timestamp == "" {
+		return ErrInvalidSignature
+	}
+	if err = checkTimestamp(timestamp, p.maxAge); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(timestamp))
+	hash.Write(body)
+	if !ecdsa.VerifyASN1(p.publicKey, hash.Sum(nil), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *SendGrid) Parse(body []byte) ([]Event, error) {
+	events, payloads, err := decodeEvents[sendGridEvent](body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Event, len(events))
+	for i, e := range events {
+		// sg_message_id is the API message ID followed by a filter suffix.
+		sgMessageID, _, _ := strings.Cut(e.SGMessageID, ".filter")
+		event := Event{
+			ProviderType: e.Event,
+			MessageIDs:   messageIDs(e.SMTPID, sgMessageID),
+			Email:        e.Email,
+			Reason:       firstNonEmpty(e.Reason, e.Response),
+			URL:          e.URL,
+			OccurredAt:   unixTime(e.Timestamp),
+			Payload:      payloads[i],
+		}
+
+		switch e.Event {
+		case "delivered":
+			event.Type = EventDelivered
+		case "deferred":
+			event.Type = EventDeferred
+		case "bounce":
+			// Blocks are refusals of this message, not of the address.
+			event.Type = EventBounce
+			event.BounceType = BounceHard
+			if e.Type == "blocked" || strings.HasPrefix(e.Status, "4") {
+				event.BounceType = BounceSoft
+			}
+		case "dropped":
+			event.Type = EventDropped
+		case "spamreport":
+			event.Type = EventComplaint
+		case "open":
+			event.Type = EventOpen
+		case "click":
+			event.Type = EventClick
+		case "unsubscribe", "group_unsubscribe":
+			event.Type = EventUnsubscribe
+		default:
+			event.Type = EventUnknown
+		}
+		result[i] = event
+	}
+	return result, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
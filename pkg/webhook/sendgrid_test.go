@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const sendGridBody = `[
+	{"event":"bounce","email":"gone@example.org","timestamp":1700000000,"smtp-id":"<job-1@example.com>","sg_message_id":"abc123.filter0001.16648.5515E0B88.0","type":"bounce","status":"5.1.1","reason":"550 5.1.1 User unknown"},
+	{"event":"bounce","email":"busy@example.org","timestamp":1700000001,"sg_message_id":"def456.filter0002","type":"blocked","status":"5.7.1","reason":"blocked by policy"},
+	{"event":"bounce","email":"later@example.org","timestamp":1700000002,"sg_message_id":"ghi789","status":"4.2.2","response":"452 mailbox full"},
+	{"event":"dropped","email":"supp@example.org","timestamp":1700000003,"reason":"Bounced Address"},
+	{"event":"spamreport","email":"angry@example.org","timestamp":1700000004},
+	{"event":"click","email":"reader@example.org","timestamp":1700000005.5,"url":"https://example.com/offer"},
+	{"event":"group_unsubscribe","email":"leaver@example.org","timestamp":1700000006},
+	{"event":"processed","email":"reader@example.org","timestamp":1700000007}
+]`
+
+func newSendGridKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+func signSendGrid(t *testing.T, key *ecdsa.PrivateKey, timestamp time.Time, body string) http.Header {
+	t.Helper()
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	hash := sha256.Sum256([]byte(ts + body))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", ts)
+	header.Set("X-Twilio-Email-Event-Webhook-Signature", base64.StdEncoding.EncodeToString(signature))
+	return header
+}
+
+func TestSendGridVerify(t *testing.T) {
+	key, publicKey := newSendGridKey(t)
+	provider, err := NewSendGrid(publicKey, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("NewSendGrid: %v", err)
+	}
+	otherKey, _ := newSendGridKey(t)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		valid  bool
+	}{
+		{"valid", signSendGrid(t, key, time.Now(), sendGridBody), sendGridBody, true},
+		{"tampered body", signSendGrid(t, key, time.Now(), sendGridBody), sendGridBody + " ", false},
+		{"other key", signSendGrid(t, otherKey, time.Now(), sendGridBody), sendGridBody, false},
+		{"stale timestamp", signSendGrid(t, key, time.Now().Add(-time.Hour), sendGridBody), sendGridBody, false},
+		{"future timestamp", signSendGrid(t, key, time.Now().Add(time.Hour), sendGridBody), sendGridBody, false},
+		{"unsigned", http.Header{}, sendGridBody, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.Verify(tt.header, []byte(tt.body))
+			if tt.valid && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify: err = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+
+	// A signature for another timestamp does not verify either.
+	header := signSendGrid(t, key, time.Now(), sendGridBody)
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", strconv.FormatInt(time.Now().Unix()-5, 10))
+	if err = provider.Verify(header, []byte(sendGridBody)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with a changed timestamp: err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestNewSendGridKeyFormats(t *testing.T) {
+	key, publicKey := newSendGridKey(t)
+	der, _ := base64.StdEncoding.DecodeString(publicKey)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	provider, err := NewSendGrid(pemKey, time.Minute)
+	if err != nil {
+		t.Fatalf("NewSendGrid with a PEM key: %v", err)
+	}
+	if err = provider.Verify(signSendGrid(t, key, time.Now(), "[]"), []byte("[]")); err != nil {
+		t.Errorf("Verify with a PEM key: %v", err)
+	}
+
+	if _, err = NewSendGrid("not a key", time.Minute); err == nil {
+		t.Error("NewSendGrid accepted an invalid key")
+	}
+}
+
+func TestSendGridParse(t *testing.T) {
+	provider := &SendGrid{}
+	events, err := provider.Parse([]byte(sendGridBody))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := []struct {
+		typ        string
+		bounceType string
+		messageIDs []string
+		reason     string
+	}{
+		{EventBounce, BounceHard, []string{"job-1@example.com", "abc123"}, "550 5.1.1 User unknown"},
+		{EventBounce, BounceSoft, []string{"def456"}, "blocked by policy"},
+		{EventBounce, BounceSoft, []string{"ghi789"}, "452 mailbox full"},
+		{EventDropped, "", nil, "Bounced Address"},
+		{EventComplaint, "", nil, ""},
+		{EventClick, "", nil, ""},
+		{EventUnsubscribe, "", nil, ""},
+		{EventUnknown, "", nil, ""},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.typ || e.BounceType != w.bounceType || e.Reason != w.reason {
+			t.Errorf("event %d: got %s/%s %q, want %s/%s %q", i, e.Type, e.BounceType, e.Reason, w.typ, w.bounceType, w.reason)
+		}
+		if len(e.MessageIDs) != len(w.messageIDs) || (len(w.messageIDs) > 0 && e.MessageIDs[0] != w.messageIDs[0]) {
+			t.Errorf("event %d: MessageIDs = %v, want %v", i, e.MessageIDs, w.messageIDs)
+		}
+		if e.Payload["email"] != e.Email {
+			t.Errorf("event %d: Payload = %v", i, e.Payload)
+		}
+	}
+	if events[5].URL != "https://example.com/offer" {
+		t.Errorf("URL = %q", events[5].URL)
+	}
+	if want := time.Unix(1700000005, 5e8); !events[5].OccurredAt.Equal(want) {
+		t.Errorf("OccurredAt = %v, want %v", events[5].OccurredAt, want)
+	}
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MdSadiqMd/Broadcast-API/pkg/config"
+)
+
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderMailgun  = "mailgun"
+	ProviderPostmark = "postmark"
+	ProviderGeneric  = "generic"
+)
+
+// Event types, normalised across providers.
+const (
+	EventDelivered   = "delivered"
+	EventDeferred    = "deferred"
+	EventBounce      = "bounce"
+	EventDropped     = "dropped"
+	EventComplaint   = "complaint"
+	EventOpen        = "open"
+	EventClick       = "click"
+	EventUnsubscribe = "unsubscribe"
+	EventUnknown     = "unknown"
+)
+
+const (
+	BounceHard = "hard"
+	BounceSoft = "soft"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+)
+
+// Event is one delivery event reported by a provider. MessageIDs lists the
+// identifiers the provider gave for the message, best match first; Payload
+// is the provider's event as received.
+type Event struct {
+	Type         string
+	ProviderType string
+	BounceType   string
+	MessageIDs   []string
+	Email        string
+	Reason       string
+	URL          string
+	OccurredAt   time.Time
+	Payload      map[string]interface{}
+}
+
+// Provider authenticates and parses one provider's webhook requests.
+type Provider interface {
+	Verify(header http.Header, body []byte) error
+	Parse(body []byte) ([]Event, error)
+}
+
+// NewProviders returns the providers that have credentials configured.
+// Requests for any other provider are refused, so that no event is accepted
+// without being authenticated.
+func NewProviders(cfg config.Config) (map[string]Provider, error) {
+	webhooks := cfg.Webhooks
+	maxAge := webhooks.MaxAge
+	if maxAge <= 0 {
+		maxAge = 10 * time.Minute
+	}
+
+	providers := make(map[string]Provider)
+	if webhooks.SendGrid.PublicKey != "" {
+		provider, err := NewSendGrid(webhooks.SendGrid.PublicKey, maxAge)
+		if err != nil {
+			return nil, err
+		}
+		providers[ProviderSendGrid] = provider
+	}
+	if webhooks.Mailgun.SigningKey != "" {
+		providers[ProviderMailgun] = NewMailgun(webhooks.Mailgun.SigningKey, maxAge)
+	}
+	if webhooks.Postmark.Username != "" && webhooks.Postmark.Password != "" {
+		providers[ProviderPostmark] = NewPostmark(webhooks.Postmark.Username, webhooks.Postmark.Password)
+	}
+	if webhooks.Generic.Secret != "" {
+		providers[ProviderGeneric] = NewGeneric(webhooks.Generic.Secret, maxAge)
+	}
+	return providers, nil
+}
+
+// checkTimestamp rejects signed requests older than maxAge, so a captured
+// request cannot be replayed later.
+func checkTimestamp(timestamp string, maxAge time.Duration) error {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(timestamp), 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(int64(seconds), 0))
+	if age > maxAge || age < -maxAge {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// decodeEvents reads a JSON array of events, or a single event, keeping
+// each one raw as well as decoded into T.
+func decodeEvents[T any](body []byte) ([]T, []map[string]interface{}, error) {
+	trimmed := strings.TrimSpace(string(body))
+	var raws []json.RawMessage
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(body, &raws); err != nil {
+			return nil, nil, ErrInvalidPayload
+		}
+	} else {
+		raws = []json.RawMessage{body}
+	}
+
+	events := make([]T, len(raws))
+	payloads := make([]map[string]interface{}, len(raws))
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &events[i]); err != nil {
+			return nil, nil, ErrInvalidPayload
+		}
+		if err := json.Unmarshal(raw, &payloads[i]); err != nil {
+			return nil, nil, ErrInvalidPayload
+		}
+	}
+	return events, payloads, nil
+}
+
+// unixTime converts a Unix timestamp in seconds, falling back to now.
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Now()
+	}
+	return time.Unix(int64(seconds), int64((seconds-float64(int64(seconds)))*1e9))
+}
+
+func messageIDs(ids ...string) []string {
+	var result []string
+	for _, id := range ids {
+		id = strings.Trim(strings.TrimSpace(id), "<>")
+		if id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}